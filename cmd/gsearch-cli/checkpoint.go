@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultCheckpointInterval is how many records are written between
+// checkpoint updates
+const defaultCheckpointInterval = 1000
+
+// checkpoint records how far an export has progressed so that an interrupted
+// run can resume where it stopped. Offset is the size of the output file at
+// the last checkpoint; anything written after it is discarded on resume, which
+// guarantees each record appears exactly once.
+type checkpoint struct {
+	Key     string `json:"key"`
+	Written int    `json:"written"`
+	Offset  int64  `json:"offset"`
+	// Done is set once the export, footer included, is complete, so a
+	// run that stopped before removing the checkpoint does not append the
+	// footer again
+	Done bool `json:"done,omitempty"`
+
+	path  string
+	every int
+}
+
+// checkpointKey fingerprints the command-line arguments and the database file
+// so that a checkpoint is only resumed by an identical run. Resuming against a
+// different result set would silently skip or duplicate records.
+func checkpointKey(args []string, dbPath string) (string, error) {
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d", strings.Join(args, "\x00"), info.Size(), info.ModTime().UnixNano())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadCheckpoint reads the checkpoint at path. A missing file yields a fresh
+// checkpoint; an existing one written for a different key is an error.
+func loadCheckpoint(path, key string) (*checkpoint, error) {
+	cp := &checkpoint{Key: key, path: path, every: defaultCheckpointInterval}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if saved.Key != key {
+		return nil, fmt.Errorf("checkpoint %s was written by a different command or database; remove it to start over", path)
+	}
+	cp.Written = saved.Written
+	cp.Offset = saved.Offset
+	cp.Done = saved.Done
+	return cp, nil
+}
+
+// save atomically writes the checkpoint to disk
+func (cp *checkpoint) save() error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+// openCheckpointOutput opens the export file for writing. When resuming, the
+// file is truncated back to the last checkpointed offset and positioned at its
+// end; otherwise it is truncated to zero.
+func openCheckpointOutput(path string, cp *checkpoint) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(cp.Offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// countingWriter tracks the number of bytes successfully written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// exportCheckpointed writes entries to w in text or CSV format, skipping the
+// records already covered by cp and saving progress every cp.every records.
+// Only line-oriented formats can be resumed by appending. The output is the
+// same as printResults writes for the same entries.
+func exportCheckpointed(w io.Writer, entries []resultEntry, format outputFormat, opts outputOptions, cp *checkpoint) error {
+	cw := &countingWriter{w: w, n: cp.Offset}
+
+	var writeHeader func() error
+	var writeRecord func(resultEntry) error
+	var writeFooter func() error
+	var flush func() error
+
+	switch format {
+	case outputFormatCSV:
+		csvw := csv.NewWriter(cw)
+		writeHeader = func() error { return csvw.Write(csvHeader(opts)) }
+		writeRecord = func(e resultEntry) error { return csvw.Write(csvRecord(e, opts)) }
+		flush = func() error {
+			csvw.Flush()
+			return csvw.Error()
+		}
+	case outputFormatText:
+		bw := bufio.NewWriter(cw)
+		writeHeader = func() error {
+			if len(entries) == 0 && !opts.alwaysCount {
+				_, err := fmt.Fprintln(bw, "No results found.")
+				return err
+			}
+			_, err := fmt.Fprintf(bw, "Found %d result(s):\n\n", len(entries))
+			return err
+		}
+		writeRecord = func(e resultEntry) error {
+			_, err := fmt.Fprintln(bw, textLine(e, opts.style))
+			return err
+		}
+		writeFooter = func() error {
+			files, size := totalFileSize(entries)
+			return printTextFooter(bw, files, size, opts)
+		}
+		flush = bw.Flush
+	default:
+		return fmt.Errorf("checkpointing is not supported for %s output", format)
+	}
+
+	commit := func(written int) error {
+		if err := flush(); err != nil {
+			return err
+		}
+		cp.Written = written
+		cp.Offset = cw.n
+		return cp.save()
+	}
+
+	if cp.Done {
+		return nil
+	}
+	if cp.Written == 0 {
+		if err := writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	every := cp.every
+	if every <= 0 {
+		every = defaultCheckpointInterval
+	}
+	for i := cp.Written; i < len(entries); i++ {
+		if err := writeRecord(entries[i]); err != nil {
+			return err
+		}
+		if (i+1)%every == 0 {
+			if err := commit(i + 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if writeFooter != nil {
+		if err := writeFooter(); err != nil {
+			return err
+		}
+	}
+	cp.Done = true
+	return commit(len(entries))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// failingWriter passes through the first n writes and fails every write after
+type failingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("simulated interruption")
+	}
+	f.n--
+	return f.w.Write(p)
+}
+
+func checkpointTestEntries(n int) []resultEntry {
+	entries := make([]resultEntry, n)
+	for i := range entries {
+		entries[i] = resultEntry{
+			Name:  fmt.Sprintf("file%02d.txt", i),
+			Path:  fmt.Sprintf("/data/file%02d.txt", i),
+			Type:  "file",
+			Size:  int64(i * 100),
+			MTime: "2024-01-03T12:00:00Z",
+		}
+	}
+	return entries
+}
+
+func TestExportCheckpointedResume(t *testing.T) {
+	for _, format := range []outputFormat{outputFormatCSV, outputFormatText} {
+		t.Run(string(format), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			outPath := filepath.Join(tmpDir, "export.out")
+			cpPath := filepath.Join(tmpDir, "export.checkpoint")
+			entries := checkpointTestEntries(10)
+
+			// Reference: an uninterrupted export
+			refPath := filepath.Join(tmpDir, "reference.out")
+			ref := &checkpoint{Key: "k", path: filepath.Join(tmpDir, "ref.checkpoint"), every: 3}
+			refFile, err := openCheckpointOutput(refPath, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := exportCheckpointed(refFile, entries, format, outputOptions{}, ref); err != nil {
+				t.Fatalf("Reference export failed: %v", err)
+			}
+			refFile.Close()
+
+			// First run: interrupted after two successful flushes
+			cp, err := loadCheckpoint(cpPath, "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp.every = 3
+			f, err := openCheckpointOutput(outPath, cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = exportCheckpointed(&failingWriter{w: f, n: 2}, entries, format, outputOptions{}, cp)
+			f.Close()
+			if err == nil {
+				t.Fatal("Expected simulated interruption error")
+			}
+
+			// Leave a partially written record past the checkpoint, as a crash
+			// between flush and save would; resume must discard it.
+			partial, err := os.OpenFile(outPath, os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			partial.WriteString("garbage-partial-rec")
+			partial.Close()
+
+			// Second run: resumes from the checkpoint
+			cp, err = loadCheckpoint(cpPath, "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cp.Written != 6 {
+				t.Errorf("Expected checkpoint at 6 records, got %d", cp.Written)
+			}
+			cp.every = 3
+			f, err = openCheckpointOutput(outPath, cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := exportCheckpointed(f, entries, format, outputOptions{}, cp); err != nil {
+				t.Fatalf("Resumed export failed: %v", err)
+			}
+			f.Close()
+
+			got, _ := os.ReadFile(outPath)
+			want, _ := os.ReadFile(refPath)
+			if string(got) != string(want) {
+				t.Errorf("Resumed output differs from uninterrupted export.\nGot:\n%s\nWant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestExportCheckpointedMatchesPlainExport(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	data := &db.Folder{Entry: db.Entry{Name: "data", Parent: root, Type: db.EntryTypeFolder}}
+	mtime := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	result := &db.SearchResult{
+		Folders: []*db.Folder{data},
+		Files: []*db.Entry{
+			{Name: "a.txt", Size: 1024, MTime: mtime, Parent: data, Type: db.EntryTypeFile},
+			{Name: "b.txt", Size: 2048, MTime: mtime, Parent: data, Type: db.EntryTypeFile},
+		},
+	}
+
+	for _, format := range []outputFormat{outputFormatCSV, outputFormatText} {
+		for name, r := range map[string]*db.SearchResult{"results": result, "empty": {}} {
+			t.Run(string(format)+"/"+name, func(t *testing.T) {
+				var want strings.Builder
+				printResults(&want, r, format, outputOptions{})
+
+				dir := t.TempDir()
+				cp := &checkpoint{Key: "k", path: filepath.Join(dir, "export.checkpoint"), every: 1}
+				outPath := filepath.Join(dir, "export.out")
+				f, err := openCheckpointOutput(outPath, cp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := exportCheckpointed(f, collectEntries(r, outputOptions{}), format, outputOptions{}, cp); err != nil {
+					t.Fatalf("Export failed: %v", err)
+				}
+				f.Close()
+
+				// A run that stopped after the last save resumes to the
+				// same content
+				cp, err = loadCheckpoint(cp.path, "k")
+				if err != nil {
+					t.Fatal(err)
+				}
+				f, err = openCheckpointOutput(outPath, cp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := exportCheckpointed(f, collectEntries(r, outputOptions{}), format, outputOptions{}, cp); err != nil {
+					t.Fatalf("Resumed export failed: %v", err)
+				}
+				f.Close()
+
+				got, _ := os.ReadFile(outPath)
+				if string(got) != want.String() {
+					t.Errorf("Checkpointed export differs from plain export.\nGot:\n%s\nWant:\n%s", got, want.String())
+				}
+			})
+		}
+	}
+}
+
+func TestLoadCheckpointKeyMismatch(t *testing.T) {
+	cpPath := filepath.Join(t.TempDir(), "export.checkpoint")
+	cp := &checkpoint{Key: "first", Written: 5, path: cpPath}
+	if err := cp.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCheckpoint(cpPath, "second"); err == nil {
+		t.Error("Expected error when resuming with different arguments")
+	}
+
+	resumed, err := loadCheckpoint(cpPath, "first")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resumed.Written != 5 {
+		t.Errorf("Expected 5 written records, got %d", resumed.Written)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// colorMode selects when text output is colored
+type colorMode string
+
+const (
+	colorAuto   colorMode = "auto"   // when stdout is a terminal
+	colorAlways colorMode = "always" // even into a pipe or file
+	colorNever  colorMode = "never"
+)
+
+// colorModes lists the accepted -color values
+var colorModes = []colorMode{colorAuto, colorAlways, colorNever}
+
+// ANSI escapes used by colored text output
+const (
+	ansiReset  = "\x1b[0m"
+	ansiFolder = "\x1b[34m"   // blue
+	ansiDim    = "\x1b[2m"    // faint, for sizes
+	ansiMatch  = "\x1b[1;31m" // bold red, as grep highlights
+)
+
+// textStyle is how text output decorates each line
+type textStyle struct {
+	// color marks folders in blue, dims sizes, and highlights highlight
+	color bool
+
+	// noEmoji drops the folder and file icons; folder paths end in / so
+	// they can still be told apart
+	noEmoji bool
+
+	// highlight is the query text to highlight in names, or "" for none.
+	// It is compared ignoring case unless caseSensitive.
+	highlight     string
+	caseSensitive bool
+
+	// units is how sizes are written
+	units sizeUnits
+}
+
+// highlightQuery returns the text of opts.Query that colored output can
+// highlight in names: the query itself when it is matched as plain text,
+// and "" for wildcard patterns, regular expressions, and the other modes
+// whose hits are not a copy of the query
+func highlightQuery(opts db.SearchOptions) string {
+	if opts.UseRegex || opts.Fuzzy || opts.Boolean || opts.FoldAccents || strings.ContainsAny(opts.Query, "*?[{") {
+		return ""
+	}
+	return opts.Query
+}
+
+// decorate returns the path of entry as a line of text output shows it:
+// with the name at its end highlighted, and as a whole in the color outside
+// unless that is "". Every occurrence of highlight is marked, or failing
+// that the range the search reported matching.
+func (s textStyle) decorate(entry resultEntry, outside string) string {
+	path, name := entry.Path, entry.Name
+	if !s.color {
+		return path
+	}
+	if name != "" && strings.HasSuffix(path, name) {
+		dir := path[:len(path)-len(name)]
+		switch {
+		case s.highlight != "":
+			path = dir + highlightMatches(name, s.highlight, s.caseSensitive, outside)
+		case entry.MatchStart != nil && *entry.MatchStart < *entry.MatchEnd && *entry.MatchEnd <= len(name):
+			path = dir + highlightRange(name, *entry.MatchStart, *entry.MatchEnd, outside)
+		}
+	}
+	if outside == "" {
+		return path
+	}
+	return outside + path + ansiReset
+}
+
+// icon returns the emoji that starts a line of text output, or "" with
+// noEmoji
+func (s textStyle) icon(emoji string) string {
+	if s.noEmoji {
+		return ""
+	}
+	return emoji + " "
+}
+
+// highlightMatches wraps each non-overlapping occurrence of query in text
+// in the match color, resuming outside, the color of the text around it
+func highlightMatches(text, query string, caseSensitive bool, outside string) string {
+	var b strings.Builder
+	for {
+		start, end := db.IndexFold(text, query, caseSensitive)
+		if start < 0 {
+			break
+		}
+		b.WriteString(text[:start])
+		b.WriteString(ansiMatch + text[start:end] + ansiReset)
+		text = text[end:]
+		if text != "" {
+			b.WriteString(outside)
+		}
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// highlightRange wraps text[start:end] in the match color, resuming
+// outside after it
+func highlightRange(text string, start, end int, outside string) string {
+	rest := text[end:]
+	if rest != "" {
+		rest = outside + rest
+	}
+	return text[:start] + ansiMatch + text[start:end] + ansiReset + rest
+}
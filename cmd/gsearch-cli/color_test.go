@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestTextLineStyle(t *testing.T) {
+	numFiles, numFolders := uint32(2), uint32(0)
+	folder := resultEntry{Name: "notes", Path: "/home/notes", Type: "folder", NumFiles: &numFiles, NumFolders: &numFolders}
+	file := resultEntry{Name: "notes.txt", Path: "/notes/notes.txt", Type: "file", Size: 2048}
+
+	tests := []struct {
+		name  string
+		entry resultEntry
+		style textStyle
+		want  string
+	}{
+		{"plain folder", folder, textStyle{}, "📁 /home/notes (2 files, 0 folders)"},
+		{"plain file", file, textStyle{}, "📄 /notes/notes.txt (2.0 KiB)"},
+		{"no emoji folder", folder, textStyle{noEmoji: true}, "/home/notes/ (2 files, 0 folders)"},
+		{"no emoji file", file, textStyle{noEmoji: true}, "/notes/notes.txt (2.0 KiB)"},
+		{"color folder", folder, textStyle{color: true},
+			"📁 \x1b[34m/home/notes\x1b[0m (2 files, 0 folders)"},
+		{"color file", file, textStyle{color: true},
+			"📄 /notes/notes.txt \x1b[2m(2.0 KiB)\x1b[0m"},
+		// Only the name is highlighted, not the same text in the folders
+		{"highlight file", file, textStyle{color: true, highlight: "NOTES"},
+			"📄 /notes/\x1b[1;31mnotes\x1b[0m.txt \x1b[2m(2.0 KiB)\x1b[0m"},
+		{"highlight folder", folder, textStyle{color: true, highlight: "ot"},
+			"📁 \x1b[34m/home/n\x1b[1;31mot\x1b[0m\x1b[34mes\x1b[0m (2 files, 0 folders)"},
+		{"highlight needs color", file, textStyle{highlight: "notes"}, "📄 /notes/notes.txt (2.0 KiB)"},
+		// Without a query to highlight, the range the search matched is
+		{"match range", withRange(file, 5, 9), textStyle{color: true},
+			"📄 /notes/notes\x1b[1;31m.txt\x1b[0m \x1b[2m(2.0 KiB)\x1b[0m"},
+		{"match range in folder", withRange(folder, 0, 2), textStyle{color: true},
+			"📁 \x1b[34m/home/\x1b[1;31mno\x1b[0m\x1b[34mtes\x1b[0m (2 files, 0 folders)"},
+		{"highlight over match range", withRange(file, 5, 9), textStyle{color: true, highlight: "note"},
+			"📄 /notes/\x1b[1;31mnote\x1b[0ms.txt \x1b[2m(2.0 KiB)\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := textLine(tt.entry, tt.style); got != tt.want {
+			t.Errorf("%s: textLine = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// withRange returns entry with the match range [start, end)
+func withRange(entry resultEntry, start, end int) resultEntry {
+	entry.setMatchRange(db.MatchRange{Start: start, End: end})
+	return entry
+}
+
+func TestHighlightQuery(t *testing.T) {
+	tests := []struct {
+		opts db.SearchOptions
+		want string
+	}{
+		{db.SearchOptions{Query: "report"}, "report"},
+		{db.SearchOptions{Query: "report", MatchWholeWord: true}, "report"},
+		{db.SearchOptions{Query: "*.pdf"}, ""},
+		{db.SearchOptions{Query: "file[0-9]"}, ""},
+		{db.SearchOptions{Query: "*.{jpg,png}"}, ""},
+		{db.SearchOptions{Query: "rep", UseRegex: true}, ""},
+		{db.SearchOptions{Query: "rpt", Fuzzy: true}, ""},
+		{db.SearchOptions{Query: "a AND b", Boolean: true}, ""},
+	}
+	for _, tt := range tests {
+		if got := highlightQuery(tt.opts); got != tt.want {
+			t.Errorf("highlightQuery(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// dedupeKey selects what -dedupe considers a duplicate
+type dedupeKey string
+
+const (
+	dedupeNone     dedupeKey = ""
+	dedupeName     dedupeKey = "name"      // same basename
+	dedupeNameSize dedupeKey = "name+size" // same basename and size
+	dedupePath     dedupeKey = "path"      // same full path, as in merged databases
+)
+
+// dedupeKeys lists the accepted -dedupe-by values
+var dedupeKeys = []dedupeKey{dedupeName, dedupeNameSize, dedupePath}
+
+// dedupeResults keeps only the first of the entries of result that share a
+// key, in the order they would be listed. Files and folders are compared
+// among themselves, so a folder never hides a file of the same name.
+func dedupeResults(result *db.SearchResult, key dedupeKey) {
+	keyOf := func(e *db.Entry) string {
+		switch key {
+		case dedupeNameSize:
+			return e.Name + "\x00" + strconv.FormatInt(e.Size, 10)
+		case dedupePath:
+			return result.FullPath(e)
+		}
+		return e.Name
+	}
+
+	seen := make(map[string]bool, len(result.Folders))
+	folders := result.Folders[:0]
+	for _, folder := range result.Folders {
+		if k := keyOf(&folder.Entry); !seen[k] {
+			seen[k] = true
+			folders = append(folders, folder)
+		}
+	}
+	result.Folders = folders
+
+	seen = make(map[string]bool, len(result.Files))
+	files := result.Files[:0]
+	for _, file := range result.Files {
+		if k := keyOf(file); !seen[k] {
+			seen[k] = true
+			files = append(files, file)
+		}
+	}
+	result.Files = files
+}
+
+// showDuplicates prints the groups of matched files that share a size,
+// largest size first, and returns how many groups were found. A shared size
+// only makes files candidate duplicates; their contents are not compared.
+func showDuplicates(w io.Writer, result *db.SearchResult, opts outputOptions) int {
+	groups := result.DuplicatesBySize()
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "No matched files share a size.")
+		return 0
+	}
+
+	sizes := make([]int64, 0, len(groups))
+	for size := range groups {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	fmt.Fprintf(w, "Found %d group(s) of files with the same size (candidate duplicates):\n", len(groups))
+	for _, size := range sizes {
+		group := groups[size]
+		fmt.Fprintf(w, "\n%s (%d bytes), %d files:\n", formatSize(size, opts.style.units), size, len(group))
+		for _, e := range group {
+			fmt.Fprintf(w, "📄 %s\n", opts.displayPath(result.FullPath(e)))
+		}
+	}
+	return len(groups)
+}
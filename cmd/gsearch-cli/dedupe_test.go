@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestDedupeResults(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Type: db.EntryTypeFolder}}
+	backup := &db.Folder{Entry: db.Entry{Name: "backup", Parent: root, Type: db.EntryTypeFolder}}
+	home := &db.Folder{Entry: db.Entry{Name: "home", Parent: root, Type: db.EntryTypeFolder}}
+	file := func(name string, size int64, parent *db.Folder) *db.Entry {
+		return &db.Entry{Name: name, Size: size, Parent: parent, Type: db.EntryTypeFile}
+	}
+	notes := file("notes.txt", 10, home)
+	notesCopy := file("notes.txt", 10, backup)
+	notesOld := file("notes.txt", 8, backup)
+	notesAgain := file("notes.txt", 10, home)
+	homeFolder := &db.Folder{Entry: db.Entry{Name: "notes.txt", Parent: home, Type: db.EntryTypeFolder}}
+
+	tests := []struct {
+		key  dedupeKey
+		want []*db.Entry
+	}{
+		{dedupeName, []*db.Entry{notes}},
+		{dedupeNameSize, []*db.Entry{notes, notesOld}},
+		{dedupePath, []*db.Entry{notes, notesCopy}},
+	}
+	for _, tt := range tests {
+		result := &db.SearchResult{
+			Folders: []*db.Folder{homeFolder},
+			Files:   []*db.Entry{notes, notesCopy, notesOld, notesAgain},
+		}
+		dedupeResults(result, tt.key)
+		if len(result.Folders) != 1 {
+			t.Errorf("%s: a file hid the folder of the same name", tt.key)
+		}
+		if !sameEntries(result.Files, tt.want) {
+			t.Errorf("%s: kept %d files, want %d", tt.key, len(result.Files), len(tt.want))
+		}
+	}
+}
+
+func sameEntries(a, b []*db.Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestShowDuplicates(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Type: db.EntryTypeFolder}}
+	file := func(name string, size int64) *db.Entry {
+		return &db.Entry{Name: name, Size: size, Parent: root, Type: db.EntryTypeFile}
+	}
+	result := &db.SearchResult{Files: []*db.Entry{
+		file("a.iso", 2048), file("b.txt", 10), file("c.iso", 2048), file("d.txt", 10), file("e.txt", 5),
+	}}
+
+	var out strings.Builder
+	if n := showDuplicates(&out, result, outputOptions{}); n != 2 {
+		t.Errorf("Expected 2 groups, got %d", n)
+	}
+	want := `Found 2 group(s) of files with the same size (candidate duplicates):
+
+2.0 KiB (2048 bytes), 2 files:
+📄 /a.iso
+📄 /c.iso
+
+10 B (10 bytes), 2 files:
+📄 /b.txt
+📄 /d.txt
+`
+	if out.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	result.Files = result.Files[:2]
+	if n := showDuplicates(&out, result, outputOptions{}); n != 0 || !strings.Contains(out.String(), "No matched files") {
+		t.Errorf("Expected no groups, got %d: %q", n, out.String())
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// dbCandidate is a *.db file found by -auto. Err is set when the file is
+// not a readable FSearch database.
+type dbCandidate struct {
+	Path    string
+	ModTime time.Time
+	Err     error
+}
+
+// discoveryDirs returns the directories -auto searches, in order: the
+// fsearch directory under $XDG_DATA_HOME (~/.local/share by default), under
+// each of $XDG_DATA_DIRS (/usr/local/share and /usr/share by default), and
+// then the extra roots. Repeated directories are listed once.
+func discoveryDirs(home string, getenv func(string) string, roots []string) []string {
+	dataHome := getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dataDirs := getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if dir == "" {
+			return
+		}
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	if dataHome != "" {
+		add(filepath.Join(dataHome, "fsearch"))
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if dir != "" {
+			add(filepath.Join(dir, "fsearch"))
+		}
+	}
+	for _, root := range roots {
+		add(root)
+	}
+	return dirs
+}
+
+// discoverDatabases lists the *.db files directly inside dirs, checking
+// each one's header with LoadMetadata. Directories that do not exist are
+// skipped.
+func discoverDatabases(dirs []string) []dbCandidate {
+	var candidates []dbCandidate
+	for _, dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.db"))
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			c := dbCandidate{Path: path, ModTime: info.ModTime()}
+			if _, err := db.LoadMetadata(path); err != nil {
+				c.Err = err
+			}
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// newestDatabase picks the most recently modified valid candidate. Ties go
+// to the one found first.
+func newestDatabase(candidates []dbCandidate) (dbCandidate, bool) {
+	var best dbCandidate
+	found := false
+	for _, c := range candidates {
+		if c.Err == nil && (!found || c.ModTime.After(best.ModTime)) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// printCandidates writes one line per candidate for -auto -list, marking
+// the one -auto would pick
+func printCandidates(w io.Writer, dirs []string, candidates []dbCandidate) {
+	if len(candidates) == 0 {
+		fmt.Fprintf(w, "No databases found in: %s\n", strings.Join(dirs, ", "))
+		return
+	}
+	best, _ := newestDatabase(candidates)
+	for _, c := range candidates {
+		mark := " "
+		if c.Err == nil && c.Path == best.Path {
+			mark = "*"
+		}
+		status := "ok"
+		if c.Err != nil {
+			status = "invalid: " + c.Err.Error()
+		}
+		fmt.Fprintf(w, "%s %s  %s  %s\n", mark, c.ModTime.Format("2006-01-02 15:04:05"), c.Path, status)
+	}
+}
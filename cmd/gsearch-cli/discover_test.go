@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestDiscoveryDirs(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+
+	got := discoveryDirs("/home/u", getenv, []string{"/srv/index", "/home/u/.local/share/fsearch/"})
+	want := []string{"/home/u/.local/share/fsearch", "/usr/local/share/fsearch", "/usr/share/fsearch", "/srv/index"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Default dirs = %v, want %v", got, want)
+	}
+
+	env["XDG_DATA_HOME"] = "/data"
+	env["XDG_DATA_DIRS"] = "/opt/share::/usr/share"
+	got = discoveryDirs("/home/u", getenv, nil)
+	want = []string{"/data/fsearch", "/opt/share/fsearch", "/usr/share/fsearch"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("XDG dirs = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverNewestValid(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	write := func(path string, valid bool, age time.Duration) {
+		t.Helper()
+		if valid {
+			if err := db.CreateTestDatabase(path); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dirA, "old.db"), true, 48*time.Hour)
+	write(filepath.Join(dirB, "new.db"), true, time.Hour)
+	// Newest of all, but not a database, so never picked
+	write(filepath.Join(dirA, "broken.db"), false, 0)
+	// Not named *.db
+	write(filepath.Join(dirB, "newer.sqlite"), true, 0)
+
+	candidates := discoverDatabases([]string{dirA, dirB, filepath.Join(dirA, "missing")})
+	if len(candidates) != 3 {
+		t.Fatalf("Expected 3 candidates, got %d: %v", len(candidates), candidates)
+	}
+
+	best, ok := newestDatabase(candidates)
+	if !ok {
+		t.Fatal("Expected a valid candidate")
+	}
+	if want := filepath.Join(dirB, "new.db"); best.Path != want {
+		t.Errorf("Picked %s, want %s", best.Path, want)
+	}
+
+	var out strings.Builder
+	printCandidates(&out, nil, candidates)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got:\n%s", out.String())
+	}
+	for _, line := range lines {
+		picked := strings.HasPrefix(line, "*")
+		if picked != strings.Contains(line, "new.db") {
+			t.Errorf("Wrong mark on %q", line)
+		}
+		if strings.Contains(line, "broken.db") && !strings.Contains(line, "invalid") {
+			t.Errorf("Expected broken.db to be marked invalid: %q", line)
+		}
+	}
+}
+
+func TestDiscoverNone(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.db"), []byte("FSD"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newestDatabase(discoverDatabases([]string{dir})); ok {
+		t.Error("Expected no valid database")
+	}
+
+	var out strings.Builder
+	printCandidates(&out, []string{dir}, nil)
+	if !strings.Contains(out.String(), "No databases found in: "+dir) {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
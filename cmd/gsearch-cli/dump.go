@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// printDBInfo writes the format details declared by a database's header,
+// for the -db-info mode
+func printDBInfo(w io.Writer, meta *db.Metadata) {
+	fmt.Fprintf(w, "Database Format:\n")
+	fmt.Fprintf(w, "  Magic: %s\n", meta.Magic)
+	fmt.Fprintf(w, "  Version: %d.%d\n", meta.MajorVersion, meta.MinorVersion)
+	fmt.Fprintf(w, "  Index flags: %s (0x%x)\n", meta.IndexFlags, uint64(meta.IndexFlags))
+	fmt.Fprintf(w, "  Folder block: %d bytes\n", meta.FolderBlockSize)
+	fmt.Fprintf(w, "  File block: %d bytes\n", meta.FileBlockSize)
+}
+
+// printRawRecord writes a hex dump of an on-disk record followed by its
+// decoded fields, for the developer-only -dump-entry mode
+func printRawRecord(w io.Writer, rec *db.RawRecord) {
+	kind := "file"
+	if rec.Type == db.EntryTypeFolder {
+		kind = "folder"
+	}
+	fmt.Fprintf(w, "%s %d at offset %d (0x%x), %d bytes:\n\n", kind, rec.Index, rec.Offset, rec.Offset, len(rec.Bytes))
+	fmt.Fprint(w, hex.Dump(rec.Bytes))
+	fmt.Fprintln(w)
+
+	if rec.Type == db.EntryTypeFolder {
+		fmt.Fprintf(w, "  db_index:    %d\n", rec.DBIndex)
+	}
+	fmt.Fprintf(w, "  name offset: %d\n", rec.NameOffset)
+	fmt.Fprintf(w, "  name length: %d\n", rec.NameLen)
+	fmt.Fprintf(w, "  name:        %q\n", rec.Name)
+	if rec.Flags&db.IndexFlagSize != 0 {
+		fmt.Fprintf(w, "  size:        %d\n", rec.Size)
+	}
+	if rec.Flags&db.IndexFlagModificationTime != 0 {
+		fmt.Fprintf(w, "  mtime:       %d (%s)\n", rec.MTime, time.Unix(rec.MTime, 0).UTC().Format(time.RFC3339))
+	}
+	for _, t := range []struct {
+		flag  db.IndexFlags
+		label string
+		value int64
+	}{
+		{db.IndexFlagAccessTime, "atime:", rec.ATime},
+		{db.IndexFlagCreationTime, "ctime:", rec.CTime},
+		{db.IndexFlagStatusChangeTime, "status time:", rec.StatusChangeTime},
+	} {
+		if rec.Flags&t.flag != 0 {
+			fmt.Fprintf(w, "  %-12s %d (%s)\n", t.label, t.value, time.Unix(t.value, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	parent := fmt.Sprintf("%d", rec.Parent)
+	if rec.Type == db.EntryTypeFolder && rec.Parent == rec.Index {
+		parent += " (self, root folder)"
+	}
+	fmt.Fprintf(w, "  parent:      %s\n", parent)
+}
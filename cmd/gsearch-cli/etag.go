@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// computeETag returns a quoted HTTP entity tag for the output of a run with
+// args against the database at dbPath. It changes whenever the database file
+// is replaced or modified (path, size, or mtime) or the arguments differ, so
+// a caching layer can answer 304 Not Modified while it stays the same. The
+// -etag flag itself is ignored.
+func computeETag(args []string, dbPath string) (string, error) {
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		return "", err
+	}
+	var kept []string
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "etag" && strings.HasPrefix(arg, "-") {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	key, err := checkpointKey(append([]string{abs}, kept...), dbPath)
+	if err != nil {
+		return "", err
+	}
+	return `"` + key[:32] + `"`, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestComputeETag(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := db.CreateTestDatabase(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-db", dbPath, "-q", "test", "-output", "json"}
+
+	first, err := computeETag(args, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := computeETag(args, dbPath)
+	if first != again {
+		t.Errorf("Identical inputs gave %s and %s", first, again)
+	}
+	if first[0] != '"' || first[len(first)-1] != '"' {
+		t.Errorf("ETag %s is not a quoted string", first)
+	}
+
+	// The -etag flag itself does not change the tag
+	withFlag, _ := computeETag(append([]string{"-etag"}, args...), dbPath)
+	if withFlag != first {
+		t.Errorf("-etag changed the tag: %s vs %s", withFlag, first)
+	}
+
+	// A different query does
+	other, _ := computeETag([]string{"-db", dbPath, "-q", "other", "-output", "json"}, dbPath)
+	if other == first {
+		t.Error("Different query gave the same ETag")
+	}
+
+	// So does a modified database
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(dbPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := computeETag(args, dbPath)
+	if changed == first {
+		t.Error("Changed database gave the same ETag")
+	}
+}
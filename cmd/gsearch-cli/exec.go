@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// openCommand returns the argument vector that runs cmdline on path. The
+// command line is split on whitespace, so "open -a Preview" works, and path
+// is appended as a single argument whatever characters it contains.
+func openCommand(cmdline, path string) ([]string, error) {
+	argv := strings.Fields(cmdline)
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return append(argv, path), nil
+}
+
+// runCommand runs argv directly, without a shell, so nothing in the
+// arguments is interpreted
+func runCommand(argv []string, stdout, stderr io.Writer) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// shellQuote quotes s for a POSIX shell. Strings made only of characters
+// that are never special are left as they are; anything else is wrapped in
+// single quotes, inside which only the single quote itself needs escaping.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, shellSafe) == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+const shellSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-"
+
+// shellJoin quotes each argument and joins them into a command line that a
+// POSIX shell splits back into exactly argv
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// execSpec is a command given with -exec, in the style of find(1):
+//
+//	-exec cmd args {} \;   runs cmd once per result, {} replaced by its path
+//	-exec cmd args {} +    runs cmd with as many paths as fit in place of {}
+//
+// Without a terminator the command is batched and, if it has no {}, the
+// paths are appended, so "-first -exec xdg-open" opens the first result.
+type execSpec struct {
+	argv  []string
+	batch bool
+}
+
+// maxBatchBytes bounds the combined length of the paths passed to a single
+// batched command, well below the smallest common ARG_MAX
+const maxBatchBytes = 128 * 1024
+
+// splitExecArgs removes an -exec command from args, which the flag package
+// cannot parse because it spans several arguments. It returns the remaining
+// arguments and the command, or nil without -exec. A bare -exec with no
+// command, as in "-open-cmd xdg-open -exec", yields an empty spec.
+func splitExecArgs(args []string) ([]string, *execSpec, error) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg != "-exec" && arg != "--exec" {
+			continue
+		}
+
+		spec := &execSpec{batch: true}
+		end := len(args)
+		for j := i + 1; j < len(args); j++ {
+			if args[j] == ";" {
+				spec.batch = false
+				end = j + 1
+				break
+			}
+			if args[j] == "+" && j > i+1 && args[j-1] == "{}" {
+				end = j + 1
+				break
+			}
+			spec.argv = append(spec.argv, args[j])
+		}
+		if end == len(args) && len(spec.argv) > 0 && strings.HasPrefix(spec.argv[0], "-") {
+			// "-exec -q foo": a flag, not a command; treat -exec as bare
+			spec.argv = nil
+			end = i + 1
+		}
+
+		rest := append(append([]string{}, args[:i]...), args[end:]...)
+		if spec.batch && countPlaceholders(spec.argv) > 1 {
+			return nil, nil, errors.New("-exec ... + allows only one {}")
+		}
+		return rest, spec, nil
+	}
+	return args, nil, nil
+}
+
+func countPlaceholders(argv []string) int {
+	n := 0
+	for _, arg := range argv {
+		if arg == "{}" {
+			n++
+		}
+	}
+	return n
+}
+
+// commands expands the spec into the argument vectors to run for paths
+func (s *execSpec) commands(paths []string) [][]string {
+	var cmds [][]string
+	if !s.batch {
+		for _, path := range paths {
+			argv := make([]string, len(s.argv))
+			for i, arg := range s.argv {
+				argv[i] = strings.ReplaceAll(arg, "{}", path)
+			}
+			cmds = append(cmds, argv)
+		}
+		return cmds
+	}
+
+	for start := 0; start < len(paths); {
+		end, size := start, 0
+		for end < len(paths) && (end == start || size+len(paths[end])+1 <= maxBatchBytes) {
+			size += len(paths[end]) + 1
+			end++
+		}
+		cmds = append(cmds, s.withPaths(paths[start:end]))
+		start = end
+	}
+	return cmds
+}
+
+// withPaths returns the batched command with paths in place of {}, or
+// appended if the command has no {}
+func (s *execSpec) withPaths(paths []string) []string {
+	argv := make([]string, 0, len(s.argv)+len(paths))
+	substituted := false
+	for _, arg := range s.argv {
+		if arg == "{}" {
+			argv = append(argv, paths...)
+			substituted = true
+			continue
+		}
+		argv = append(argv, arg)
+	}
+	if !substituted {
+		argv = append(argv, paths...)
+	}
+	return argv
+}
+
+// printCommands writes each command as a shell-quoted line that, pasted into
+// a POSIX shell, runs exactly what runCommands would
+func printCommands(w io.Writer, cmds [][]string) {
+	for _, argv := range cmds {
+		fmt.Fprintln(w, shellJoin(argv))
+	}
+}
+
+// runCommands runs cmds with at most workers running at once and returns
+// the number that failed. Each failure is reported to stderr.
+func runCommands(cmds [][]string, workers int, stdout, stderr io.Writer) int {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	jobs := make(chan []string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for argv := range jobs {
+				if err := runCommand(argv, stdout, stderr); err != nil {
+					mu.Lock()
+					failed++
+					fmt.Fprintf(stderr, "Error: %s: %v\n", argv[0], err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, argv := range cmds {
+		jobs <- argv
+	}
+	close(jobs)
+	wg.Wait()
+	return failed
+}
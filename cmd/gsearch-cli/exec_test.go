@@ -0,0 +1,267 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeStub creates a shell script that appends each argument it receives,
+// one per line, to argsFile
+func writeStub(t *testing.T) (script, argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stub command needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	argsFile = filepath.Join(dir, "args")
+	script = filepath.Join(dir, "stub")
+	body := "#!/bin/sh\nfor a in \"$@\"; do printf '%s\\n' \"$a\"; done >> " + shellQuote(argsFile) + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, argsFile
+}
+
+func TestOpenCommandRunsStub(t *testing.T) {
+	stub, argsFile := writeStub(t)
+	path := "/home/user/my report; rm -rf $HOME 'quoted'.pdf"
+
+	argv, err := openCommand(stub+" --new-window", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(argv, os.Stdout, os.Stderr); err != nil {
+		t.Fatalf("runCommand failed: %v", err)
+	}
+
+	got, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--new-window\n" + path + "\n"
+	if string(got) != want {
+		t.Errorf("Stub received:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestOpenCommandEmpty(t *testing.T) {
+	if _, err := openCommand("  ", "/x"); err == nil {
+		t.Error("Expected error for empty command")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/home/user/test.txt", "/home/user/test.txt"},
+		{"", "''"},
+		{"my file.txt", "'my file.txt'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"a\nb", "'a\nb'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := shellJoin([]string{"xdg-open", "/tmp/a b"}); got != "xdg-open '/tmp/a b'" {
+		t.Errorf("shellJoin = %q", got)
+	}
+}
+
+func TestShellJoinRoundTrip(t *testing.T) {
+	stub, argsFile := writeStub(t)
+	args := []string{"plain", "with space", "it's", "$(echo no)", "tab\there", "*"}
+
+	// A shell given the joined command line must see exactly the original
+	// arguments
+	line := shellJoin(append([]string{stub}, args...))
+	if err := runCommand([]string{"/bin/sh", "-c", line}, os.Stdout, os.Stderr); err != nil {
+		t.Fatalf("sh -c failed: %v", err)
+	}
+
+	got, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(args, "\n") + "\n"; string(got) != want {
+		t.Errorf("Shell saw:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestSplitExecArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantRest  []string
+		wantArgv  []string
+		wantBatch bool
+		wantNil   bool
+	}{
+		{
+			name:      "per result",
+			args:      []string{"-q", "x", "-exec", "rm", "-f", "{}", ";", "-files"},
+			wantRest:  []string{"-q", "x", "-files"},
+			wantArgv:  []string{"rm", "-f", "{}"},
+			wantBatch: false,
+		},
+		{
+			name:      "batched",
+			args:      []string{"-exec", "tar", "czf", "out.tgz", "{}", "+", "-q", "x"},
+			wantRest:  []string{"-q", "x"},
+			wantArgv:  []string{"tar", "czf", "out.tgz", "{}"},
+			wantBatch: true,
+		},
+		{
+			name:      "unterminated",
+			args:      []string{"-q", "x", "-first", "-exec", "xdg-open"},
+			wantRest:  []string{"-q", "x", "-first"},
+			wantArgv:  []string{"xdg-open"},
+			wantBatch: true,
+		},
+		{
+			name:      "bare",
+			args:      []string{"-open-cmd", "xdg-open", "-exec", "-q", "x"},
+			wantRest:  []string{"-open-cmd", "xdg-open", "-q", "x"},
+			wantArgv:  nil,
+			wantBatch: true,
+		},
+		{
+			name:     "absent",
+			args:     []string{"-q", "x"},
+			wantRest: []string{"-q", "x"},
+			wantNil:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, spec, err := splitExecArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+				t.Errorf("rest = %q, want %q", rest, tt.wantRest)
+			}
+			if tt.wantNil {
+				if spec != nil {
+					t.Errorf("Expected no -exec, got %+v", spec)
+				}
+				return
+			}
+			if strings.Join(spec.argv, " ") != strings.Join(tt.wantArgv, " ") || spec.batch != tt.wantBatch {
+				t.Errorf("spec = %+v, want argv %q batch %v", spec, tt.wantArgv, tt.wantBatch)
+			}
+		})
+	}
+
+	if _, _, err := splitExecArgs([]string{"-exec", "cp", "{}", "{}", "+"}); err == nil {
+		t.Error("Expected error for two {} in a batched command")
+	}
+}
+
+func TestExecCommands(t *testing.T) {
+	paths := []string{"/a b/one.txt", "/two.txt", "/three.txt"}
+
+	perResult := (&execSpec{argv: []string{"mv", "{}", "{}.bak"}}).commands(paths)
+	if len(perResult) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(perResult))
+	}
+	if got := strings.Join(perResult[0], "|"); got != "mv|/a b/one.txt|/a b/one.txt.bak" {
+		t.Errorf("Per-result substitution: got %q", got)
+	}
+
+	batched := (&execSpec{argv: []string{"ls", "{}", "-d"}, batch: true}).commands(paths)
+	if len(batched) != 1 {
+		t.Fatalf("Expected 1 batched command, got %d", len(batched))
+	}
+	if got := strings.Join(batched[0], "|"); got != "ls|/a b/one.txt|/two.txt|/three.txt|-d" {
+		t.Errorf("Batched substitution: got %q", got)
+	}
+
+	// Batches split before exceeding maxBatchBytes
+	long := make([]string, 3)
+	for i := range long {
+		long[i] = "/" + strings.Repeat("x", maxBatchBytes/2)
+	}
+	if n := len((&execSpec{argv: []string{"echo"}, batch: true}).commands(long)); n != 3 {
+		t.Errorf("Expected 3 batches for oversized paths, got %d", n)
+	}
+}
+
+func TestRunCommandsStub(t *testing.T) {
+	stub, argsFile := writeStub(t)
+	paths := []string{"/one.txt", "/with space.txt", "/three.txt", "/four.txt"}
+
+	// Per result: one invocation per path, each seeing "--" and its path
+	spec := &execSpec{argv: []string{stub, "--", "{}"}}
+	if failed := runCommands(spec.commands(paths), 3, os.Stdout, os.Stderr); failed != 0 {
+		t.Fatalf("%d commands failed", failed)
+	}
+	got, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(got), "\n"), "\n")
+	if n := strings.Count(string(got), "--\n"); n != len(paths) {
+		t.Errorf("Expected %d invocations, got %d", len(paths), n)
+	}
+	var seen []string
+	for _, line := range lines {
+		if line != "--" {
+			seen = append(seen, line)
+		}
+	}
+	sort.Strings(seen)
+	want := append([]string{}, paths...)
+	sort.Strings(want)
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("Paths passed: %q, want %q", seen, want)
+	}
+
+	// Batched: a single invocation with all paths in order
+	os.Remove(argsFile)
+	spec = &execSpec{argv: []string{stub, "--", "{}"}, batch: true}
+	if failed := runCommands(spec.commands(paths), 3, os.Stdout, os.Stderr); failed != 0 {
+		t.Fatalf("%d commands failed", failed)
+	}
+	got, _ = os.ReadFile(argsFile)
+	if want := "--\n" + strings.Join(paths, "\n") + "\n"; string(got) != want {
+		t.Errorf("Batched invocation saw:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Failures are counted
+	var stderr strings.Builder
+	if failed := runCommands([][]string{{"false"}, {"true"}}, 1, os.Stdout, &stderr); failed != 1 {
+		t.Errorf("Expected 1 failure, got %d", failed)
+	}
+}
+
+func TestPrintCommandsDryRun(t *testing.T) {
+	paths := []string{"/home/user/notes.txt", "/home/user/it's notes.txt", "/tmp/$(reboot)", "/tmp/a\nb"}
+
+	var out strings.Builder
+	printCommands(&out, (&execSpec{argv: []string{"rm", "-f", "{}"}}).commands(paths))
+	want := strings.Join([]string{
+		"rm -f /home/user/notes.txt",
+		`rm -f '/home/user/it'\''s notes.txt'`,
+		"rm -f '/tmp/$(reboot)'",
+		"rm -f '/tmp/a\nb'",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("Dry run printed:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printCommands(&out, (&execSpec{argv: []string{"tar", "czf", "out.tgz", "{}"}, batch: true}).commands(paths[:2]))
+	if want := `tar czf out.tgz /home/user/notes.txt '/home/user/it'\''s notes.txt'` + "\n"; out.String() != want {
+		t.Errorf("Batched dry run printed:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
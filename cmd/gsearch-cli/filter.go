@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// A -filter expression is a boolean combination of comparisons between an
+// entry field and a literal, for example
+//
+//	size > 1MB && ext == "go" && !(path contains "vendor")
+//
+// It is parsed once into a tree of filterNodes and evaluated per result.
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = field op literal
+
+// filterNode is a parsed boolean expression
+type filterNode interface {
+	eval(e *filterEntry) bool
+}
+
+// filterEntry gives the expression access to an entry's fields, computing
+// the full path through the result's path cache only if the expression
+// uses it
+type filterEntry struct {
+	entry  *db.Entry
+	result *db.SearchResult
+	path   string
+}
+
+func (f *filterEntry) fullPath() string {
+	if f.path == "" {
+		f.path = f.result.FullPath(f.entry)
+	}
+	return f.path
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindTime
+)
+
+// filterFields lists the fields an expression may compare, with their kind
+var filterFields = map[string]fieldKind{
+	"name":  kindString,
+	"path":  kindString,
+	"ext":   kindString, // lowercase, without the dot
+	"type":  kindString, // "file" or "folder"
+	"size":  kindNumber, // bytes
+	"mtime": kindTime,
+}
+
+// stringField returns the value of a string field
+func stringField(name string, f *filterEntry) string {
+	switch name {
+	case "name":
+		return f.entry.Name
+	case "path":
+		return f.fullPath()
+	case "ext":
+		return strings.ToLower(db.Extension(f.entry.Name))
+	case "type":
+		if f.entry.Type == db.EntryTypeFolder {
+			return "folder"
+		}
+		return "file"
+	}
+	return ""
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) eval(e *filterEntry) bool { return n.left.eval(e) && n.right.eval(e) }
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) eval(e *filterEntry) bool { return n.left.eval(e) || n.right.eval(e) }
+
+type notNode struct{ operand filterNode }
+
+func (n notNode) eval(e *filterEntry) bool { return !n.operand.eval(e) }
+
+// compareNode compares a field with a literal of the same kind
+type compareNode struct {
+	field string
+	op    string
+	str   string
+	num   int64
+	time  time.Time
+}
+
+func (n compareNode) eval(e *filterEntry) bool {
+	switch filterFields[n.field] {
+	case kindNumber:
+		return compareOrdered(e.entry.Size, n.num, n.op)
+	case kindTime:
+		mtime := e.entry.MTime
+		switch {
+		case mtime.Before(n.time):
+			return n.op == "<" || n.op == "<=" || n.op == "!="
+		case mtime.After(n.time):
+			return n.op == ">" || n.op == ">=" || n.op == "!="
+		default:
+			return n.op == "==" || n.op == "<=" || n.op == ">="
+		}
+	}
+
+	v := stringField(n.field, e)
+	switch n.op {
+	case "contains":
+		return strings.Contains(v, n.str)
+	case "startswith":
+		return strings.HasPrefix(v, n.str)
+	case "endswith":
+		return strings.HasSuffix(v, n.str)
+	}
+	return compareOrdered(v, n.str, n.op)
+}
+
+func compareOrdered[T int64 | string](a, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+// filterOps lists the comparison operators; the word operators apply to
+// string fields only
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">", "contains", "startswith", "endswith"}
+
+// filterToken is a lexical token with its byte offset in the expression
+type filterToken struct {
+	text string
+	pos  int
+	str  bool // a quoted string literal, with text already unquoted
+}
+
+// lexFilter splits an expression into tokens
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %v", i, err)
+			}
+			tokens = append(tokens, filterToken{text: text, pos: i, str: true})
+			i = j + 1
+		case strings.ContainsRune("()!&|=<>", rune(c)):
+			n := 1
+			if i+1 < len(s) {
+				switch s[i : i+2] {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					n = 2
+				}
+			}
+			if (c == '&' || c == '|' || c == '=') && n == 1 {
+				return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+			}
+			tokens = append(tokens, filterToken{text: s[i : i+n], pos: i})
+			i += n
+		default:
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '.' || s[j] == '_' || s[j] == '-' || s[j] == ':') {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+			}
+			tokens = append(tokens, filterToken{text: s[i:j], pos: i})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser over the tokens
+type filterParser struct {
+	tokens []filterToken
+	next   int
+	end    int // length of the expression, for errors at its end
+}
+
+// parseFilter parses a -filter expression
+func parseFilter(s string) (filterNode, error) {
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, end: len(s)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return node, nil
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.next >= len(p.tokens) {
+		return filterToken{pos: p.end}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// accept consumes the next token if it is the operator text
+func (p *filterParser) accept(text string) bool {
+	if t, ok := p.peek(); ok && !t.str && t.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right filterNode
+		right, err = p.parseAnd()
+		left = orNode{left, right}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right filterNode
+		right, err = p.parseUnary()
+		left = andNode{left, right}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		return notNode{operand}, err
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			t, _ := p.peek()
+			return nil, fmt.Errorf("expected ) at position %d", t.pos)
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	field, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a field at position %d", field.pos)
+	}
+	kind, known := filterFields[field.text]
+	if field.str || !known {
+		return nil, fmt.Errorf("unknown field %q at position %d (fields: ext, mtime, name, path, size, type)", field.text, field.pos)
+	}
+	p.next++
+
+	op, ok := p.peek()
+	if !ok || op.str || !oneOf(op.text, filterOps) {
+		return nil, fmt.Errorf("expected an operator after %s at position %d", field.text, op.pos)
+	}
+	p.next++
+	if kind != kindString && !strings.ContainsAny(op.text, "=<>") {
+		return nil, fmt.Errorf("%s applies only to text fields, not %s (position %d)", op.text, field.text, op.pos)
+	}
+
+	lit, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a value after %s %s at position %d", field.text, op.text, lit.pos)
+	}
+	p.next++
+
+	node := compareNode{field: field.text, op: op.text}
+	var err error
+	switch kind {
+	case kindString:
+		if !lit.str {
+			return nil, fmt.Errorf("expected a quoted string for %s at position %d", field.text, lit.pos)
+		}
+		node.str = lit.text
+	case kindNumber:
+		if node.num, err = parseSize(lit.text); err != nil || lit.str {
+			return nil, fmt.Errorf("expected a size such as 4096 or 1MB for %s at position %d", field.text, lit.pos)
+		}
+	case kindTime:
+		if node.time, err = parseFilterTime(lit.text); err != nil {
+			return nil, fmt.Errorf("expected a date such as \"2024-01-31\" for %s at position %d", field.text, lit.pos)
+		}
+	}
+	return node, nil
+}
+
+// parseFilterTime accepts a date or an RFC 3339 timestamp. Dates are
+// midnight UTC.
+func parseFilterTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// filterResults keeps only the results for which expr is true
+func filterResults(result *db.SearchResult, expr filterNode) {
+	files := result.Files[:0]
+	for _, file := range result.Files {
+		if expr.eval(&filterEntry{entry: file, result: result}) {
+			files = append(files, file)
+		}
+	}
+	result.Files = files
+
+	folders := result.Folders[:0]
+	for _, folder := range result.Folders {
+		if expr.eval(&filterEntry{entry: &folder.Entry, result: result}) {
+			folders = append(folders, folder)
+		}
+	}
+	result.Folders = folders
+}
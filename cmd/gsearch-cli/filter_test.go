@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func filterTestEntries() (*db.Folder, []*db.Entry) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	src := &db.Folder{Entry: db.Entry{Name: "src", Type: db.EntryTypeFolder, Parent: root}}
+	vendor := &db.Folder{Entry: db.Entry{Name: "vendor", Type: db.EntryTypeFolder, Parent: src}}
+	march := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []*db.Entry{
+		{Name: "main.go", Size: 2 << 20, MTime: march, Parent: src},
+		{Name: "lib.GO", Size: 3 << 20, MTime: march, Parent: vendor},
+		{Name: "readme.txt", Size: 100, MTime: march.AddDate(-1, 0, 0), Parent: src},
+	}
+	return vendor, files
+}
+
+func TestFilterExpressions(t *testing.T) {
+	vendor, files := filterTestEntries()
+	all := append([]*db.Entry{&vendor.Entry}, files...)
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		// Comparisons
+		{`size > 1MB`, []string{"main.go", "lib.GO"}},
+		{`size <= 100`, []string{"vendor", "readme.txt"}},
+		{`size == 2M`, []string{"main.go"}},
+		{`name != "main.go"`, []string{"vendor", "lib.GO", "readme.txt"}},
+		{`ext == "go"`, []string{"main.go", "lib.GO"}},
+		{`type == "folder"`, []string{"vendor"}},
+		{`mtime < "2024-01-01"`, []string{"vendor", "readme.txt"}},
+		{`mtime >= 2024-03-01T12:00:00Z`, []string{"main.go", "lib.GO"}},
+
+		// String operators
+		{`path contains "vendor"`, []string{"vendor", "lib.GO"}},
+		{`name startswith "read"`, []string{"readme.txt"}},
+		{`path endswith ".go"`, []string{"main.go"}},
+		{`name == "say \"hi\""`, nil},
+
+		// Boolean combinators and precedence
+		{`size > 1MB && ext == "go" && !(path contains "vendor")`, []string{"main.go"}},
+		{`type == "folder" || ext == "txt"`, []string{"vendor", "readme.txt"}},
+		{`ext == "txt" || ext == "go" && size > 2MB`, []string{"lib.GO", "readme.txt"}},
+		{`(ext == "txt" || ext == "go") && size > 2MB`, []string{"lib.GO"}},
+		{`!!(name == "main.go")`, []string{"main.go"}},
+	}
+
+	for _, tt := range tests {
+		expr, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) unexpected error: %v", tt.expr, err)
+			continue
+		}
+		var got []string
+		for _, e := range all {
+			if expr.eval(&filterEntry{entry: e, result: &db.SearchResult{}}) {
+				got = append(got, e.Name)
+			}
+		}
+		if !sameSet(got, tt.want) {
+			t.Errorf("%s matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s]--; seen[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`size`,
+		`size >`,
+		`owner == "me"`,
+		`size > "big"`,
+		`size contains 1`,
+		`name == main.go`,
+		`mtime > "yesterday"`,
+		`name == "x" &`,
+		`(name == "x"`,
+		`name == "x")`,
+		`name == "x" size > 1`,
+		`name == "unterminated`,
+	} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Errorf("parseFilter(%q) expected error", expr)
+		}
+	}
+}
+
+func TestFilterResults(t *testing.T) {
+	vendor, files := filterTestEntries()
+	result := &db.SearchResult{Files: files, Folders: []*db.Folder{vendor}}
+
+	expr, err := parseFilter(`ext == "go"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterResults(result, expr)
+
+	if len(result.Folders) != 0 {
+		t.Errorf("Folders = %d, want 0", len(result.Folders))
+	}
+	if len(result.Files) != 2 || result.Files[0].Name != "main.go" || result.Files[1].Name != "lib.GO" {
+		t.Errorf("Files = %v, want main.go and lib.GO in order", result.Files)
+	}
+}
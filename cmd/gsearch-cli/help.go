@@ -21,25 +21,114 @@ USAGE:
 
 DESCRIPTION:
     Search the FSearch database file for files and folders matching your query.
-    Supports wildcard patterns (* and ?) and various output formats.
+    Supports wildcard patterns (*, ?, and [...]) and various output formats.
 
 SEARCH OPTIONS:
     -q, -query <query>
         Search query (required unless using -path)
-        Supports wildcard patterns: * (any sequence) and ? (single character)
-        Examples: "test", "*.txt", "test*", "file?.go"
+        Supports wildcard patterns: * (any sequence), ? (single character),
+        and [abc], [a-z], or [!x] (one character in or not in a class)
+        Brace groups match any of their alternatives: "*.{jpg,png}"; escape
+        a literal brace or comma with a backslash, as in "\{a,b\}.txt", and
+        a literal bracket the same way, as in "Movie \[2019\]"
+        Examples: "test", "*.txt", "test*", "file?.go", "file[0-9].txt"
+        May be repeated with -merge-sorted.
+
+    -merge-sorted
+        Run each -q as its own search and merge the results into a single
+        listing in -sort order (required), each entry listed once. Other
+        options apply to each query separately, except -max, which keeps
+        the first results of the merged listing. Cannot be combined with
+        -path.
 
     -path <pattern>
         Search in full path instead of just name
         Also supports wildcard patterns
         Examples: "/home/user", "/home/*", "*.txt"
 
+    -segment-match
+        With -path, the pattern must line up with whole path segments
+        (bounded by / or the ends of the path): "user" matches
+        /home/user/notes.txt but not /home/username/notes.txt
+
+    -and-path <pattern>
+        With -q, also require the full path of each match to match this
+        pattern, read as -path reads it, e.g. -q "*.log" -and-path "/var/*"
+        for the log files under /var. Both are checked in one pass over
+        the database. Cannot be combined with -path.
+
+    -depth <n>
+        With -path, keep only entries at most n levels below the matched
+        part of the path: -path /home -depth 1 lists /home and its direct
+        children but not /home/user/notes.txt, and -depth 0 only /home.
+        A match ending inside a name counts from the end of that name; a
+        wildcard pattern counts from its text before the first wildcard.
+        -path / -depth 1 lists the top-level entries. (default: unlimited)
+
     -case
         Enable case-sensitive search (default: false)
 
+    -fold-accents
+        Ignore accents and other combining marks when matching, in both
+        the query and the names: -q cafe finds café and -q café finds
+        cafe. Combines with case-insensitive matching and with -case.
+        Letters such as ø or ß are distinct letters and are kept.
+
+    -no-normalize
+        Match names and the query byte for byte. By default both are
+        brought to Unicode normalization form C first, so -q café finds a
+        file whose name macOS stored decomposed, as e plus a combining
+        accent, though the two look alike.
+
+    -locale <tag>
+        BCP 47 language tag that sets both case-insensitive matching and
+        the -sort name/path order, so the two always agree (default: und,
+        language-neutral). For example, with -locale tr "I" matches "ı"
+        rather than "i"; with -locale de "strasse" matches "Straße" and
+        "ä" sorts next to "a". Sorting ignores case unless -case is given.
+
+    -natural
+        Compare runs of digits by value in -sort name and path, so
+        img2.png sorts before img10.png (default: true). Use
+        -natural=false for plain character order.
+
     -whole
         Match whole words only (default: false)
 
+    -stem
+        Match -q against each name without its final extension. A plain
+        query must equal the whole stem: -q report -stem finds report.pdf
+        and report.docx but not quarterly-report.pdf. With -whole the query
+        need only be a word of the stem, so quarterly-report.pdf matches
+        too. Wildcards, -regex, and -fuzzy match the stem as they would a
+        name, e.g. -q 'IMG_*' -stem.
+
+    -contains
+        Let a wildcard -q match anywhere in a name, as plain text does,
+        instead of the whole name (see WILDCARD PATTERNS). Cannot be
+        combined with -regex or -fuzzy.
+
+    -regex
+        Treat -q as a Go regular expression (RE2 syntax) matched anywhere
+        in the name, instead of a wildcard pattern; anchor it with ^ and $
+        as needed. Ignores case unless -case is given. An invalid pattern
+        is an error. Cannot be combined with -path or -whole.
+
+    -boolean
+        Read -q as terms combined with the operators NOT, AND, and OR,
+        which bind in that order, and parentheses. Terms side by side are
+        joined by AND, so "invoice 2024 NOT draft" finds invoices from
+        2024 that are not drafts. Each term is matched as a -q of its own,
+        wildcards included. Double quotes make operator words and spaces
+        literal: "AND" OR "my notes". A malformed query is an error.
+        Cannot be combined with -regex or -fuzzy.
+
+    -fuzzy
+        Match names containing the characters of -q in order, though not
+        necessarily together, so "rdme" finds readme.txt. Each match is
+        scored (see RELEVANCE); use -sort score to list the closest first.
+        Cannot be combined with -regex, -path, or -whole.
+
     -files
         Search only files (exclude folders)
 
@@ -47,34 +136,488 @@ SEARCH OPTIONS:
         Search only folders (exclude files)
 
     -max <n>
-        Maximum number of results (0 = unlimited, default: 0)
+        Maximum number of results (0 = unlimited, default: 0). With -sort
+        every match is collected and sorted first, so -sort size -max 10
+        gives the ten smallest.
+
+    -max-per-db <n>
+        Maximum number of results from each database (0 = unlimited,
+        default: 0), so one large database cannot crowd out the others.
+        Combines with -max, which is then shared out evenly between them.
+
+    -size <n>
+        Only files of exactly n bytes; folders are left out. Accepts K, M,
+        G, and T suffixes (powers of 1024), e.g. 4096, 10K, or 1.5M.
+        -size 0 finds empty files. Cannot be combined with -minsize or
+        -maxsize.
+
+    -exclude <pattern>
+        Drop -q matches whose name matches this wildcard pattern, or whose
+        full path does if the pattern contains /. May be repeated, e.g.
+        -q "*.log" -exclude "*/tmp/*" -exclude "debug*". Case follows
+        -case. Applies to entries added by -follow too.
+
+    -exclude-path
+        Match every -exclude pattern against the full path, even one
+        without a /
+
+    -minsize <n>, -maxsize <n>
+        Only files of at least / at most n bytes, with the same suffixes as
+        -size, e.g. -minsize 100M. -maxsize 0 means no upper bound. Folders
+        are checked against their total size when the database indexes
+        sizes, and are otherwise unaffected.
+
+    -after <time>, -before <time>
+        Only entries modified strictly after / before time: an RFC 3339
+        timestamp (2024-01-31T09:00:00Z), a date (2024-01-31, midnight
+        UTC), or a duration ago such as -7d, -24h, or -2w. Entries without
+        a recorded modification time are left out, and the database must
+        index modification times.
+
+    -within <duration>
+        Only entries modified within duration before now, so -within 30d
+        is -after -30d. Units are d (days), w (weeks), h, m (minutes), and
+        s; any other unit is an error. Cannot be combined with -after or
+        -newer.
+
+    -newer <path>
+        Only entries modified strictly after the file at path on this
+        system was, as with find -newer. Cannot be combined with -after.
+
+    -filter <expr>
+        Keep only results for which the expression is true, e.g.
+        'size > 1MB && ext == "go" && !(path contains "vendor")'.
+        Applies to the matches of -q or -path, before -max. See FILTER
+        EXPRESSIONS below.
+
+    -max-per-ext <n>
+        Maximum number of files per extension (0 = unlimited, default: 0)
+        Gives a spread across file types instead of many of one kind.
+        Extensions compare case-insensitively; folders are not capped.
+        Combines with -max, which still limits the overall total.
+
+    -limit-files <n>
+    -limit-folders <n>
+        Maximum number of files, and of folders, each capped on its own
+        (0 = unlimited, default: 0), e.g. -limit-files 50 -limit-folders 10
+        for up to 50 files and up to 10 folders. The per-type caps apply
+        first; -max, if also given, then caps the total, and files come
+        before folders in filling it.
+
+    -match-path
+        Match -q against each entry's full path as well as its name, so
+        -q projects finds everything under a "projects" folder.
+
+    -weight-name <w>, -weight-path <w>
+        With -match-path, how much a hit in the name counts against a hit
+        elsewhere in the path when scoring (default: 2 and 1). Raise
+        -weight-path when directory names carry the meaning, e.g.
+        -weight-name 1 -weight-path 3. Affects -sort score and -min-score.
+
+    -follow
+        Also include everything beneath each folder whose name matches -q,
+        e.g. -q photos -follow lists the contents of every "photos" folder.
+        Combine with -files to list only the files inside. Each entry is
+        listed once, even under nested matching folders.
+
+    -follow-depth <n>
+        Levels below a matched folder to include with -follow
+        (0 = unlimited, default: 0; 1 = direct children only)
+
+    -min-score <score>
+        Drop -q matches whose relevance score is below score (0 to 1).
+        Scores appear in JSON and CSV output; see RELEVANCE below.
+
+    -sample <n>
+        Return a random sample of n results (0 = all, default: 0)
+
+    -dedupe
+        Keep only the first result of each name, in the order results are
+        listed, so copies of a file across backups show once. With -sort,
+        the copy that sorts first is kept: -sort mtime -desc keeps the
+        newest. Files and folders are compared among themselves.
+
+    -dedupe-by <key>
+        What -dedupe treats as the same result:
+        - name: the same basename (default)
+        - name+size: the same basename and size
+        - path: the same full path, as with several -db databases
+
+    -duplicates
+        Instead of listing the results, list the groups of matched files
+        that have the same size, largest first, as candidate duplicates to
+        check with a hashing tool. Only sizes are compared, since the
+        database records no file contents, so this is a heuristic. Requires
+        -q or -path, text output, and a database that indexes sizes; the
+        limits such as -max and -sample, which would leave duplicates
+        out, cannot be combined with it.
+
+    -stratify <key>
+        Spread the -sample evenly across groups instead of sampling uniformly,
+        so rare groups are represented alongside common ones:
+        - ext: by file extension (folders form one group)
+        - dir: by parent folder
+        Groups smaller than their share are taken whole and the remaining
+        slots go to the larger groups.
+
+    -seed <n>
+        Random seed for -sample, for reproducible samples (default: time-based)
 
 OUTPUT OPTIONS:
     -output <format>
-        Output format: text, json, or csv (default: text)
+        Output format: text, json, jsonl, json0, csv, sunburst-json,
+        rsync-filter, names-sorted, shell, null, paths, template, or xml
+        (default: text)
         - text: Human-readable format with emojis
         - json: JSON array with fields: name, path, type, size, mtime
+        - jsonl: One compact JSON object per line (NDJSON)
+        - json0: One compact JSON object per result, each terminated by a
+          NUL byte; safe to stream even when names contain newlines
         - csv: CSV format with header row
+        - sunburst-json: Nested folder tree with aggregate sizes for the
+          matched folders, ready for D3 sunburst/treemap charts
+        - rsync-filter: Rules for rsync --filter selecting exactly the
+          results: "+" rules for each result and its parent directories
+          (matched folders include their contents), then "- *"
+        - names-sorted: Each distinct name once per line, in byte order,
+          for comm, join, or loading into a bloom filter. Without -q or
+          -path, lists every name in the database.
+        - shell: Each path on its own line, quoted for a POSIX shell
+          (spaces, quotes, $, etc.) so it can be pasted into a command
+        - null: Each full path followed by a NUL byte and nothing else,
+          for xargs -0; safe for paths with spaces or newlines
+        - paths: Each full path on its own line and nothing else, in -sort
+          order; no results print nothing
+        - template: Each result through the Go text/template given by
+          -template-str or -template-file, followed by a newline
+        - xml: A <results> document with an <entry type="file"> or
+          <entry type="folder"> element per result, holding the json fields
+
+    -template-str <template>
+        With -output template, the template for each result, e.g.
+        '{{.Path}}\t{{.SizeHuman}}'. Fields: Name, Path, Type, Size, MTime,
+        MTimeTS, Score, and SizeHuman (the size as text output shows it
+        by default).
+        \t, \n, \0, and \\ are unescaped. A template that fails to parse
+        or names an unknown field is an error before the search runs.
+
+    -template-file <path>
+        Like -template-str, but read the template from a file, as it is
+
+    -collate
+        With -output names-sorted, order names by the -locale collation
+        instead of byte order
+
+    -meta
+        With -output jsonl, end the stream with a summary line:
+        {"_meta":{"count":N,"truncated":true|false,"elapsed_ms":T}}
+        truncated is true when -max, -max-per-ext, -max-per-db, -sample,
+        -first, -nth, or -timeout with -partial left out matches. Result objects never have a _meta key.
+
+    -json-compact
+        With -output json, write the array (or -summary object) on a
+        single line instead of indented, for smaller output that other
+        programs parse faster
+
+    -show-index
+        With -output json, jsonl, or json0, add "index", the entry's
+        position among the files or the folders of its database, and for
+        folders "db_index", FSearch's number for the indexed location the
+        folder belongs to, to correlate results with FSearch's own data
+
+    -match-range
+        With -q and -output json, jsonl, or json0, add "match_start" and
+        "match_end", the byte range [start,end) of the name the query
+        matched: the first occurrence of plain text, the first match of a
+        -regex, or the whole name for a wildcard pattern. Entries whose
+        match cannot be placed, as for -fuzzy or -boolean, have neither
+
+    -summary
+        With -output json, print an object instead of an array:
+        {"results":[...],"count":N,"files":F,"total_size":B,"truncated":false}
+        total_size adds up the sizes of the matched files; folders are
+        not counted. Text output ends with the same total when files match.
+
+    -time-as <format>
+        Modification time fields in JSON, XML, and CSV output:
+        - unix: Unix timestamp only (mtime_ts)
+        - rfc3339: RFC3339 string only (mtime)
+        - both: both fields
+        Default: both for JSON; CSV keeps its single mtime column unless
+        -time-as is given
+
+    -maxdepth <n>
+        Keep only entries at most n levels below the root, counting from 0
+        for the root itself, so /home is at 1 and /home/me/notes.txt at 3
+        (0 = unlimited). Applies to -q and -path searches alike, and also
+        limits the rings of -output sunburst-json.
+
+    -mindepth <n>
+        With -q, keep only entries at least n levels below the root, e.g.
+        -q node_modules -folders -maxdepth 3 finds node_modules folders
+        near the top without the ones nested inside packages
 
     -sort <field>
-        Sort results by field: name, path, size, or mtime (default: no sorting)
+        Sort results by field: name, path, size, mtime, pathlen, score, or ext (default: no sorting)
         - name: Sort by file/folder name
         - path: Sort by full path
-        - size: Sort by file size (files only, folders sorted by name)
+        - size: Sort by file size (files only, folders sorted by name);
+          with -first, -nth, or -max the folders are left out unless
+          nothing else matched, so they pick the smallest or largest file
         - mtime: Sort by modification time
+        - pathlen: Sort by full path length in bytes
+        - score: Sort by relevance to -q, best matches first
+        - ext: Sort files by extension, ignoring case, then by name; files
+          without an extension come first and folders are sorted by name
+
+    -desc, -reverse
+        Sort in descending order (requires -sort). Entries that compare
+        equal keep their relative order.
+
+    -group <mode>
+        Where folders go among the results in every output format:
+        - dirs-first: all folders, then all files (default)
+        - dirs-last: all files, then all folders
+        - mixed: folders and files in one list in -sort order, so
+          -sort size -group mixed interleaves them by size (requires -sort)
+
+    -flag-dupes
+        Append [dup] to text lines whose file or folder name appears more
+        than once among the results, e.g. the same config.yaml in several
+        folders
+
+    -always-count
+        Always start text output with "Found N result(s):", even for zero
+        results, instead of "No results found.", so scripts can parse
+        every run the same way
+
+    -color <mode>
+        Color text output: folders in blue, sizes dimmed, and the query
+        highlighted where it occurs in each name. For wildcards and
+        -regex the part of the name that matched is highlighted, as
+        -match-range reports it; -fuzzy and -boolean are not highlighted.
+        Highlighting ignores case letter by letter, so a name that matches
+        only through full case folding, as "strasse" matches "Straße",
+        is listed but not highlighted.
+        - auto: when stdout is a terminal, -o is not given, and NO_COLOR
+          is not set (default)
+        - always: even into a pipe or a file
+        - never: no color
+
+    -no-emoji
+        Leave out the folder and file icons of text output; folder paths
+        end in / instead
+
+    -units <units>
+        How text output writes sizes:
+        - binary: in powers of 1024, as 1.5 KiB or 2.0 MiB (default)
+        - si: in powers of 1000, as 1.5 KB or 2.0 MB
+        - bytes: the exact count, as 1536 B
+
+    -compact-paths
+        When all results lie under one directory, print it once in the
+        header and show each result relative to it (text output only).
+        Paths are shown in full when the results share no directory.
+
+    -relative <dir>
+        Print each result path relative to the absolute directory dir, in
+        every output format, e.g. -relative /home/me/ shows
+        /home/me/notes.txt as notes.txt and dir itself as ".". A trailing
+        slash is ignored. Paths outside dir are printed in full, with a
+        warning on stderr. Cannot be combined with -compact-paths,
+        -checkpoint, -exec, -open-cmd, -output rsync-filter (whose rules
+        are anchored at the root), or -server.
+
+    -sep <text>
+        Print text instead of / between the components of result paths,
+        e.g. -sep '\' for Windows-style paths (default "/"). Only printed
+        paths change: -path still matches against /-separated paths, and
+        -exec and -open-cmd still get them. Cannot be combined with
+        -compact-paths, -checkpoint, -output rsync-filter, or -server.
+
+    -first
+        Output only the first result, after sorting (e.g. with -sort score,
+        the best match); the same as -nth 1
+
+    -nth <n>
+        Output only the nth result, counting from 1, after sorting and in
+        the order -group lists them, e.g. -sort size -desc -nth 2 for the
+        second largest. Nothing is printed if there are fewer than n.
+
+    -quiet
+        Print nothing, whatever -output says, and exit with status 0 if
+        anything matches and 1 if nothing does, like grep -q, for shell
+        conditionals. The search stops at the first match unless -filter
+        is given. Errors are still reported on stderr, with status 1.
+        Requires -q or -path; cannot be combined with -first or -nth.
+
+    -open-cmd <command>
+        Print the command that runs <command> on the first result, with
+        the path shell-quoted, instead of listing results. The command may
+        include its own arguments, e.g. -open-cmd "open -a Preview".
+
+    -exec <command> [args] {} \;
+    -exec <command> [args] {} +
+        Run a command on the results instead of listing them, like find:
+        with \; once per result, {} replaced by its path (also inside an
+        argument, e.g. {}.bak); with + as few times as possible, {} replaced
+        by as many paths as fit. Without a terminator -exec must come last;
+        the paths are then appended, e.g. -first -exec xdg-open. Commands
+        run without a shell, so unusual names are safe. Nothing runs if
+        there are no results. Exits with status 1 if any command fails.
+        A bare -exec after -open-cmd runs that command instead of printing it.
+
+    -dry-run
+        With -exec, print the commands that would run, one per line and
+        quoted so they can be pasted into a shell, without running any.
+        Use it to preview destructive commands such as rm.
+
+    -workers <n>
+        Number of -exec commands to run at once (default: 1)
+
+    -o <file>
+        Write output to file instead of stdout
+
+    -gzip
+        Gzip-compress the -o output file (any format)
+
+    -checkpoint <file>
+        Record export progress in file (requires -o, text or csv output).
+        If the export is interrupted, re-running the same command resumes
+        where it stopped and appends the remaining results. The checkpoint
+        is removed when the export completes.
 
 DATABASE OPTIONS:
     -db <path>
         Path to FSearch database file
         Default: ~/.local/share/fsearch/fsearch.db
+        Repeat -db, or separate paths with commas, to search several
+        databases together, e.g. one per mount point. Each is searched on
+        its own and the results merged; -stats adds up their counts. A
+        database that fails to load is reported and skipped. -db-info,
+        -dump-entry, -etag, -index-stats, -reindex-hint, and -checkpoint
+        take a single database.
+
+    -auto
+        Instead of -db, use the most recently modified *.db file with a
+        valid FSearch header found in $XDG_DATA_HOME/fsearch (by default
+        ~/.local/share/fsearch), in the fsearch directory of each
+        $XDG_DATA_DIRS entry, and in every -auto-dir
+
+    -auto-dir <dir>
+        With -auto, also look for databases directly inside dir; may be
+        repeated
+
+    -list
+        With -auto, list every database found (newest marked with *, or
+        why it is invalid) instead of searching
 
     -stats
         Show database statistics instead of searching
 
+    -depth-stats
+        With -stats, also show how many entries sit at each folder depth
+        (0 is the root), as a histogram, and the ten largest files
+
+    -server
+        Load the database once, then answer one JSON request per stdin
+        line with one JSON response line, until EOF. See SERVER MODE.
+
+    -interactive
+        Load the database once, then search for each line typed on stdin
+        as if it were given with -q, printing the results before reading
+        the next, until EOF or :quit. The other search, sort, and output
+        flags apply to every query. :stats shows database statistics and
+        :help lists the commands. Ctrl-C stops a long search, not the
+        session. Works over a pipe as well as at a terminal.
+
+    -index-stats
+        Check each precomputed sorted array in the database: whether it
+        orders every folder and file exactly once, which sort key its ID
+        is believed to stand for (ID n is the property of index flag bit n),
+        and whether -sort uses it. Text or -output json.
+
+    -db-info
+        Show the format header only: magic, version, index flags, and block
+        sizes. Reads no entries, so it is instant even on a huge database
+        and a quick way to identify a file of unknown origin.
+
+    -unreachable
+        List entries whose parent chain never reaches the root folder (a sign
+        of index corruption), with their best-effort partial path. Exits with
+        status 1 if any are found.
+
+    -verify
+        Load the database and cross-check its structure instead of
+        searching: parent indices in range, no loops in the folder
+        hierarchy, and every sorted array an ordering of all entries.
+        Prints each problem and exits with status 1 if there are any. A
+        database too damaged to load at all, such as one whose entry counts
+        or block sizes disagree with its header, fails with the load error.
+
+    -reindex-hint
+        Compare the files' modification times with the database file's
+        own mtime: report the newest file and how many were modified after
+        the database was written, and recommend re-running fsearch if any
+        were. Needs a database that indexes modification times.
+
+    -parent-of <path>
+        Print the folder containing the entry at path. A name without a
+        slash resolves every entry with that name, one line each.
+
+    -ancestors
+        With -parent-of, print every folder up to the root, nearest first
+
+    -case-collisions
+        List entries in the same folder whose names differ only by case
+        (e.g. README and readme), which cannot coexist on case-insensitive
+        filesystems. Exits with status 1 if any are found.
+
+    -path-cache-size <n>
+        Maximum number of full paths kept in memory during -path searches;
+        the least recently used are evicted beyond n (0 = no cache,
+        default: 100000). Lower it to bound memory on very large databases.
+
+    -timeout <duration>
+        Stop searching after this long (e.g. 500ms, 2s). On timeout the
+        command fails without output unless -partial is given.
+
+    Ctrl-C during a search stops it, prints the matches found so far as
+    -partial does, and exits with status 130, so scripts can tell an
+    interrupted search from a failed one (status 1). With -exec,
+    -open-cmd, or -checkpoint nothing is run or written.
+
+    -partial
+        With -timeout, print the matches found before the timeout instead
+        of failing. The output stays well-formed in every format, and the
+        -meta footer reports truncated: true. A warning goes to stderr.
+
+    -retry <n>
+        Retry loading the database up to n more times if it cannot be read,
+        e.g. while fsearch is re-indexing (default: 0)
+
+    -retry-interval <duration>
+        Wait between retries (default: 1s)
+        Accepts Go durations (500ms, 1m) plus d (days) and w (weeks)
+
+    -strict
+        Refuse a database whose format minor version is newer than 0.9.
+        By default it is loaded with a warning, since minor versions keep
+        the same layout; anything the newer format added is ignored.
+
 HELP:
     -h, -help
         Show this help message
 
+    -v, -verbose
+        Print how long each phase took (load, search, sort, output) to stderr
+
+    -etag
+        Print an ETag line to stderr, e.g. ETag: "3f2a...", derived from the
+        database file (path, size, mtime) and the other arguments. It is
+        the same for as long as the output would be, so a service wrapping
+        the CLI can use it to answer 304 Not Modified.
+
 EXAMPLES:
     # Basic search
     %s -q test
@@ -104,12 +647,68 @@ EXAMPLES:
     # Sort by modification time
     %s -q test -sort mtime
 
+    # Longest paths first
+    %s -q test -sort pathlen -desc
+
     # Combine options
     %s -q "*.go" -files -sort size -output json
 
+    # Folder size tree for visualization, two levels deep
+    %s -q "*" -folders -output sunburst-json -maxdepth 2
+
+    # Balanced sample of 100 files across file types
+    %s -q "*" -files -sample 100 -stratify ext -seed 42
+
+    # Sync only the matched files
+    %s -q "*.pdf" -files -output rsync-filter > pdfs.rules
+    rsync -a --filter="merge pdfs.rules" / backup/
+
+    # Resumable export of a large result set
+    %s -q "*" -output csv -o all.csv -checkpoint all.checkpoint
+
     # Show database statistics
     %s -stats
 
+    # One listing of two searches, newest first
+    %s -q "*.pdf" -q "*.docx" -merge-sorted -sort mtime -desc
+
+    # Go files changed in the last week
+    %s -q "*.go" -after -7d
+
+    # Search two mount points' databases together, at most 20 hits each
+    %s -db ~/home.db,/mnt/data/fsearch.db -q report -max-per-db 20
+
+    # Log files over 100 MB
+    %s -q "*.log" -files -minsize 100M
+
+    # Find empty log files
+    %s -q "*.log" -size 0
+
+    # Delete temporary files whatever their names contain
+    %s -q "*.tmp" -files -output null | xargs -0 rm --
+
+    # Large Go files outside vendor directories
+    %s -q "*.go" -filter 'size > 1MB && !(path contains "/vendor/")'
+
+    # Where does a file live, all the way up?
+    %s -parent-of /home/user/test.txt -ancestors
+
+    # Which databases can -auto see, and which would it use?
+    %s -auto -list -auto-dir /srv/indexes
+
+    # Identify the format of an unknown database file
+    %s -db /tmp/unknown.db -db-info
+
+    # Open the best match for "report"
+    %s -q report -sort score -open-cmd xdg-open -exec
+
+    # Preview what would be deleted, then delete it
+    %s -q "*.tmp" -files -exec rm {} + -dry-run
+    %s -q "*.tmp" -files -exec rm {} +
+
+    # Compress every .log file, four at a time
+    %s -q "*.log" -files -workers 4 -exec gzip {} \;
+
 WILDCARD PATTERNS:
     *       Matches any sequence of characters (zero or more)
     ?       Matches a single character
@@ -120,13 +719,22 @@ WILDCARD PATTERNS:
         ?.go         Matches single character + .go (e.g., "a.go")
         *test*       Matches files with "test" anywhere
 
+    A plain query matches anywhere in a name, but a wildcard pattern
+    must match the whole name: test* means "starts with test" and *test
+    "ends with test". -contains lets a wildcard pattern match anywhere,
+    as if it began and ended with *, so -q 'rep*2024' -contains finds
+    my-report-2024.pdf.
+
+    With -regex, -q is a regular expression instead and * and ? have
+    their regex meanings.
+
 OUTPUT FORMATS:
     text (default):
         Human-readable format with folder/file indicators
         Example:
             Found 2 result(s):
-            📁 /Documents
-            📄 /home/user/test.txt (1.0 KB)
+            📁 /Documents (3 files, 1 folder)
+            📄 /home/user/test.txt (1.0 KiB)
 
     json:
         JSON array with structured data
@@ -142,22 +750,79 @@ OUTPUT FORMATS:
               }
             ]
 
+    json0:
+        The same objects as json, compact and NUL-terminated, one per result
+        Example:
+            %s -q report -output json0 | xargs -0 -n1 echo
+
     csv:
         CSV format with header row
         Example:
-            name,path,type,size,mtime
-            test.txt,/home/user/test.txt,file,1024,2024-01-03T12:00:00Z
+            name,path,type,size,mtime,num_files,num_folders
+            test.txt,/home/user/test.txt,file,1024,2024-01-03T12:00:00Z,,
 
 SORTING:
     Results can be sorted by:
-    - name: Alphabetical by file/folder name
-    - path: Alphabetical by full path
+    - name: Alphabetical by file/folder name (see -locale)
+    - path: Alphabetical by full path (see -locale)
     - size: By file size (ascending), folders sorted by name
     - mtime: By modification time (oldest first)
+    - pathlen: By full path length (shortest first)
+    - score: By relevance to -q (highest first)
+    - ext: By file extension, then name; folders sorted by name
+
+    Use -desc to reverse any of these orderings.
+
+    For size and mtime, a valid sorted array in the database (see
+    -index-stats) supplies the order directly instead of comparing the
+    results, which is faster on large databases. Results that are few next
+    to the size of the database are still compared, which is faster then.
+    Either way, ties keep the order the search found them in.
+
+FILTER EXPRESSIONS:
+    A -filter expression compares fields with literals:
+    - name, path: the entry's name and full path
+    - ext: the extension in lowercase, without the dot ("" if none)
+    - type: "file" or "folder"
+    - size: bytes, written as 4096 or with a suffix, e.g. 10K, 1.5MB
+    - mtime: a date ("2024-01-31", midnight UTC) or RFC 3339 timestamp
+    Operators are == != < <= > >= for every field, and contains,
+    startswith, endswith for the text fields, all case-sensitive. Text is
+    double-quoted. Combine comparisons with && (and), || (or), ! (not),
+    and parentheses; && binds tighter than ||.
+
+SERVER MODE:
+    Each -server request is a JSON object on one line, with fields named
+    after the flags: q or path, and optionally case, whole, files, folders,
+    max, sort, desc, and an id of any type that is echoed back:
+        {"id":1,"q":"report","files":true,"sort":"mtime","max":10}
+    Each response is one line:
+        {"id":1,"results":[...],"count":N,"truncated":false}
+    with results shaped as in -output json. An invalid request gets a
+    response with an "error" field and the session continues.
+
+RELEVANCE:
+    With -sort score, -min-score, or -fuzzy, each -q match gets a score
+    from 0 to 1, added as a "score" field in JSON and a score column in
+    CSV:
+    - 1.0: the name equals the query (ignoring case unless -case)
+    - 0.7: the name starts with the query
+    - 0.5: the query starts a later word (after "-", ".", " ", etc.)
+    - 0.3: the query occurs elsewhere, or matches via wildcards
+    plus up to 0.25 for the share of the name the query covers. Scores are
+    rounded to four decimal places and are the same on every run.
+
+    With -fuzzy, a name that holds the query's characters only in order
+    scores up to 0.2 for how tightly they cluster, plus 0.05 when the
+    first one starts the name, so it always ranks below a substring match.
+
+    With -match-path the full path is scored the same way. Each score is
+    multiplied by its weight (-weight-name, -weight-path), the higher one
+    is kept and divided by the larger weight, so scores stay within 0 to 1.
 
     Note: Sorting applies to both files and folders together.
 
-`, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName)
+`, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName, programName)
 
 	fmt.Fprintf(os.Stderr, "\n%s v%s\n", programName, version.Get())
 	fmt.Fprintf(os.Stderr, "Copyright © 2026 Runable.app. All rights reserved.\n")
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/gsearch-cli/internal/db"
+	"golang.org/x/text/collate"
+)
+
+// indexSortFields are the -sort fields a database sorted array can stand in
+// for. Names and paths are left out: FSearch orders them its own way rather
+// than byte by byte or by -locale as -sort does.
+var indexSortFields = []sortField{sortFieldSize, sortFieldMTime}
+
+// sortResultsIndexed sorts result as sortResults does, but when database
+// has a usable sorted array for field it reads the order from there instead
+// of comparing entries, which is linear in the size of the database rather
+// than n log n in the number of results. Results too few for that to pay
+// are compared as usual.
+func sortResultsIndexed(database *db.Database, result *db.SearchResult, field sortField, desc bool, coll *collate.Collator) {
+	n := len(result.Files) + len(result.Folders)
+	var arr *db.SortedArray
+	ok := false
+	if oneOf(field, indexSortFields) && n*bits.Len(uint(n)) >= len(database.Files)+len(database.Folders) {
+		arr, ok = database.SortOrder(string(field))
+	}
+	if !ok {
+		sortResults(result, field, desc, coll)
+		return
+	}
+
+	sameFile := func(a, b *db.Entry) bool { return a.Size == b.Size }
+	sameFolder := func(a, b *db.Folder) bool { return a.MTime.Equal(b.MTime) }
+	if field == sortFieldMTime {
+		sameFile = func(a, b *db.Entry) bool { return a.MTime.Equal(b.MTime) }
+	}
+	result.Files = inIndexOrder(result.Files, arr.Files, database.Files, desc, sameFile)
+	if field == sortFieldSize {
+		// -sort size orders folders by name
+		_, folderLess := sortLess(result, field, desc, coll)
+		sort.SliceStable(result.Folders, func(i, j int) bool {
+			return folderLess(&result.Folders[i].Entry, &result.Folders[j].Entry)
+		})
+	} else {
+		result.Folders = inIndexOrder(result.Folders, arr.Folders, database.Folders, desc, sameFolder)
+	}
+}
+
+// inIndexOrder returns the items of subset in the order that order, a list
+// of indices into all, gives them, reversed if desc. Items that same says
+// tie keep their order in subset either way, as a stable sort keeps them.
+// Items not in all, such as entries from another database, follow in their
+// original order.
+func inIndexOrder[T comparable](subset []T, order []uint32, all []T, desc bool, same func(a, b T) bool) []T {
+	pending := make(map[T]int, len(subset))
+	for i, item := range subset {
+		pending[item] = i
+	}
+
+	// Runs of tied items, in order
+	var runs [][]T
+	for i := 0; i < len(order); {
+		j := i + 1
+		for j < len(order) && same(all[order[i]], all[order[j]]) {
+			j++
+		}
+		var run []T
+		for _, idx := range order[i:j] {
+			if _, ok := pending[all[idx]]; ok {
+				run = append(run, all[idx])
+			}
+		}
+		if len(run) > 0 {
+			sort.Slice(run, func(a, b int) bool { return pending[run[a]] < pending[run[b]] })
+			runs = append(runs, run)
+		}
+		i = j
+	}
+
+	out := make([]T, 0, len(subset))
+	for i := range runs {
+		if desc {
+			i = len(runs) - 1 - i
+		}
+		for _, item := range runs[i] {
+			delete(pending, item)
+			out = append(out, item)
+		}
+	}
+	for _, item := range subset {
+		if _, ok := pending[item]; ok {
+			delete(pending, item)
+			out = append(out, item)
+		}
+	}
+	return out
+}
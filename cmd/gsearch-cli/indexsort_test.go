@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func fileNames(result *db.SearchResult) string {
+	var names []string
+	for _, f := range result.Files {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSortResultsIndexed(t *testing.T) {
+	database := loadTestDatabase(t)
+	search := func() *db.SearchResult {
+		return mustSearch(t, database, db.SearchOptions{Query: "*", SearchInFiles: true, SearchInFolders: true})
+	}
+	identity := func(n int) []uint32 {
+		order := make([]uint32, n)
+		for i := range order {
+			order[i] = uint32(i)
+		}
+		return order
+	}
+
+	// Without a sorted array the results are compared as usual
+	want := search()
+	sortResults(want, sortFieldSize, false, nil)
+	got := search()
+	sortResultsIndexed(database, got, sortFieldSize, false, nil)
+	if fileNames(got) != fileNames(want) {
+		t.Errorf("Fallback: got %s, want %s", fileNames(got), fileNames(want))
+	}
+
+	// An order that does not follow the sizes shows the array is consulted
+	order := []uint32{4, 0, 3, 1, 2}
+	var byIndex []string
+	for _, i := range order {
+		byIndex = append(byIndex, database.Files[i].Name)
+	}
+	database.SortedArrays = map[uint32]*db.SortedArray{
+		2: {ID: 2, Folders: identity(len(database.Folders)), Files: order},
+	}
+	got = search()
+	sortResultsIndexed(database, got, sortFieldSize, false, nil)
+	if fileNames(got) != strings.Join(byIndex, ",") {
+		t.Errorf("Indexed: got %s, want %s", fileNames(got), strings.Join(byIndex, ","))
+	}
+	// Folders keep their name order under -sort size
+	for i := 1; i < len(got.Folders); i++ {
+		if got.Folders[i-1].Name > got.Folders[i].Name {
+			t.Errorf("Folders out of name order: %q before %q", got.Folders[i-1].Name, got.Folders[i].Name)
+		}
+	}
+
+	got = search()
+	sortResultsIndexed(database, got, sortFieldSize, true, nil)
+	for i, j := 0, len(byIndex)-1; i < j; i, j = i+1, j-1 {
+		byIndex[i], byIndex[j] = byIndex[j], byIndex[i]
+	}
+	if fileNames(got) != strings.Join(byIndex, ",") {
+		t.Errorf("Indexed desc: got %s, want %s", fileNames(got), strings.Join(byIndex, ","))
+	}
+
+	// Only the matched entries are listed
+	got = mustSearch(t, database, db.SearchOptions{Query: "test", SearchInFiles: true})
+	sortResultsIndexed(database, got, sortFieldSize, false, nil)
+	if len(got.Files) != 2 {
+		t.Errorf("Expected the 2 matches only, got %s", fileNames(got))
+	}
+
+	// An array that is not a full ordering is ignored
+	database.SortedArrays[2] = &db.SortedArray{ID: 2, Folders: identity(len(database.Folders)), Files: []uint32{4, 4, 3, 1, 2}}
+	got = search()
+	sortResultsIndexed(database, got, sortFieldSize, false, nil)
+	if fileNames(got) != fileNames(want) {
+		t.Errorf("Invalid array: got %s, want %s", fileNames(got), fileNames(want))
+	}
+}
+
+func TestInIndexOrder(t *testing.T) {
+	all := []string{"a", "b", "c", "d"}
+	order := []uint32{2, 0, 3, 1}
+	distinct := func(a, b string) bool { return a == b }
+	got := inIndexOrder([]string{"a", "x", "b", "c"}, order, all, false, distinct)
+	if strings.Join(got, ",") != "c,a,b,x" {
+		t.Errorf("Got %v, want c,a,b,x", got)
+	}
+	got = inIndexOrder([]string{"a", "x", "b", "c"}, order, all, true, distinct)
+	if strings.Join(got, ",") != "b,a,c,x" {
+		t.Errorf("Desc: got %v, want b,a,c,x", got)
+	}
+
+	// Ties keep their order in the subset, as a stable sort keeps them
+	key := map[string]int{"a": 1, "b": 2, "c": 1, "d": 2}
+	tied := func(a, b string) bool { return key[a] == key[b] }
+	got = inIndexOrder([]string{"a", "x", "b", "c"}, order, all, false, tied)
+	if strings.Join(got, ",") != "a,c,b,x" {
+		t.Errorf("Ties: got %v, want a,c,b,x", got)
+	}
+	got = inIndexOrder([]string{"a", "x", "b", "c"}, order, all, true, tied)
+	if strings.Join(got, ",") != "b,a,c,x" {
+		t.Errorf("Ties desc: got %v, want b,a,c,x", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// indexStat is the -index-stats report for one sorted array
+type indexStat struct {
+	ID       uint32 `json:"id"`
+	Key      string `json:"key"`
+	Folders  int    `json:"folders"`
+	Files    int    `json:"files"`
+	Valid    bool   `json:"valid"`
+	Problem  string `json:"problem,omitempty"`
+	Sortable bool   `json:"sortable"`
+}
+
+// indexStats checks the database's sorted arrays. An array is sortable
+// when it is valid and -sort reads its key's order from it.
+func indexStats(database *db.Database) []indexStat {
+	stats := make([]indexStat, 0, len(database.SortedArrays))
+	for _, s := range database.CheckSortedArrays() {
+		key := s.Key
+		if key == "" {
+			key = "unknown"
+		}
+		stats = append(stats, indexStat{
+			ID:       s.ID,
+			Key:      key,
+			Folders:  s.Folders,
+			Files:    s.Files,
+			Valid:    s.Valid,
+			Problem:  s.Problem,
+			Sortable: s.Valid && oneOf(sortField(s.Key), indexSortFields),
+		})
+	}
+	return stats
+}
+
+// showIndexStats prints the sorted-array report as text or JSON
+func showIndexStats(w io.Writer, database *db.Database, format outputFormat) error {
+	stats := indexStats(database)
+	if format == outputFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	fmt.Fprintf(w, "Sorted Arrays: %d (database has %d folders, %d files)\n", len(stats), len(database.Folders), len(database.Files))
+	for _, s := range stats {
+		status := "ok"
+		if !s.Valid {
+			status = "invalid: " + s.Problem
+		}
+		fmt.Fprintf(w, "  ID %d (%s): %d folders, %d files, %s", s.ID, s.Key, s.Folders, s.Files, status)
+		if s.Sortable {
+			fmt.Fprintf(w, ", usable for -sort %s", s.Key)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestIndexStats(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	database := &db.Database{
+		Folders: []*db.Folder{root},
+		Files: []*db.Entry{
+			{Name: "b.txt", Parent: root, Type: db.EntryTypeFile},
+			{Name: "a.txt", Parent: root, Type: db.EntryTypeFile, Index: 1},
+		},
+		SortedArrays: map[uint32]*db.SortedArray{
+			2: {ID: 2, Folders: []uint32{0}, Files: []uint32{1, 0}},
+			1: {ID: 1, Folders: []uint32{0}, Files: []uint32{0, 5}},
+		},
+	}
+
+	var out strings.Builder
+	if err := showIndexStats(&out, database, outputFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var stats []indexStat
+	if err := json.Unmarshal([]byte(out.String()), &stats); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 arrays, got %d", len(stats))
+	}
+	if s := stats[0]; s.ID != 1 || s.Key != "path" || s.Valid || s.Sortable || !strings.Contains(s.Problem, "out of range") {
+		t.Errorf("Expected array 1 to be an invalid path ordering, got %+v", s)
+	}
+	if s := stats[1]; s.ID != 2 || s.Key != "size" || !s.Valid || !s.Sortable {
+		t.Errorf("Expected array 2 to be a valid, sortable size ordering, got %+v", s)
+	}
+
+	out.Reset()
+	if err := showIndexStats(&out, database, outputFormatText); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Sorted Arrays: 2 (database has 1 folders, 2 files)",
+		"ID 1 (path): 1 folders, 2 files, invalid: files: index 5 at position 1 out of range\n",
+		"ID 2 (size): 1 folders, 2 files, ok, usable for -sort size\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestShowDepthStats(t *testing.T) {
+	var out strings.Builder
+	database := loadTestDatabase(t)
+	showDepthStats(&out, database, database.Stats(), unitsBinary)
+	want := `
+Entries by depth:
+    0         1  ##########
+    1         3  ##############################
+    2         4  ########################################
+    3         2  ####################
+
+Largest files:
+    16.0 KiB  /Downloads/file.zip
+     8.0 KiB  /Documents/test.go
+     4.0 KiB  /Documents/document.pdf
+     2.0 KiB  /home/user/readme.txt
+     1.0 KiB  /home/user/test.txt
+`
+	if out.String() != want {
+		t.Errorf("Depth stats:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
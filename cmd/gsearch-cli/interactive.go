@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+	"golang.org/x/text/collate"
+)
+
+// interactiveSession holds what -interactive applies to every query: the
+// search options built from the other flags, whose Query each input line
+// replaces, and how the results are filtered, sorted, and printed
+type interactiveSession struct {
+	database  *db.Database
+	opts      db.SearchOptions
+	timeout   time.Duration
+	filter    filterNode
+	sortField sortField
+	desc      bool
+	coll      *collate.Collator
+	dedupe    dedupeKey
+	max       int // -max, when the search does not apply it itself
+	nth       int
+	format    outputFormat
+	outOpts   outputOptions
+}
+
+// interactiveCommands are the lines -interactive treats as commands rather
+// than queries
+const interactiveCommands = `Commands:
+  :stats  Show database statistics
+  :help   Show this list
+  :quit   End the session (as does EOF)
+Any other line is searched for as -q.
+`
+
+// run searches for each line of r and prints its results to w until r is
+// exhausted or :quit is read. Blank lines are skipped. A query that fails
+// prints its error and the session goes on. With prompt set, as for a
+// terminal, a prompt is written before each line is read.
+func (s *interactiveSession) run(r io.Reader, w io.Writer, prompt bool) error {
+	scanner := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	for {
+		if prompt {
+			fmt.Fprint(bw, "gsearch> ")
+		}
+		// Flush before blocking on input, so results and the prompt show
+		// up over a pipe too
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == ":quit" || line == ":q":
+			return bw.Flush()
+		case line == ":stats":
+			showDatabaseStats(bw, s.database)
+		case line == ":help":
+			fmt.Fprint(bw, interactiveCommands)
+		case strings.HasPrefix(line, ":"):
+			fmt.Fprintf(bw, "Unknown command %s (try :help)\n", line)
+		default:
+			if err := s.search(bw, line); err != nil {
+				fmt.Fprintf(bw, "Error: %v\n", err)
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+// search runs one query and prints its results. Ctrl-C stops the search
+// rather than the session.
+func (s *interactiveSession) search(w io.Writer, query string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	opts := s.opts
+	opts.Query = query
+	result, err := s.database.SearchContext(ctx, opts)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errors.New("search interrupted")
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("search timed out after %s", s.timeout)
+	case err != nil:
+		return err
+	}
+
+	if s.filter != nil {
+		filterResults(result, s.filter)
+	}
+	if s.sortField != "" {
+		sortResultsIndexed(s.database, result, s.sortField, s.desc, s.coll)
+	}
+	if s.dedupe != dedupeNone {
+		dedupeResults(result, s.dedupe)
+	}
+	outOpts := s.outOpts
+	outOpts.style.highlight = highlightQuery(opts)
+	if outOpts.order.mode == groupMixed {
+		outOpts.order.less, _ = sortLess(result, s.sortField, s.desc, s.coll)
+	}
+	if s.max > 0 || s.nth > 0 {
+		keepSizedFiles(result, s.sortField)
+	}
+	if s.max > 0 {
+		truncateResults(result, s.max, outOpts.order)
+	}
+	if s.nth > 0 {
+		keepNth(result, s.nth, outOpts.order)
+	}
+	printResults(w, result, s.format, outOpts)
+	return nil
+}
+
+// isTerminal reports whether f is a character device such as a terminal,
+// rather than a pipe or a file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestInteractiveSession(t *testing.T) {
+	session := &interactiveSession{
+		database: loadTestDatabase(t),
+		opts: db.SearchOptions{
+			SearchInFiles:   true,
+			SearchInFolders: true,
+			Boolean:         true,
+		},
+		sortField: sortFieldName,
+		format:    outputFormatShell,
+	}
+	input := strings.Join([]string{
+		"*.txt",
+		"",
+		":stats",
+		"(unbalanced",
+		":nope",
+		"readme OR Documents",
+		":quit",
+		"never searched",
+	}, "\n")
+
+	var out strings.Builder
+	if err := session.run(strings.NewReader(input), &out, false); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"/home/user/readme.txt",
+		"/home/user/test.txt",
+		"Database Statistics:",
+		"  Folders: 5",
+		"  Files: 5",
+		"  Total entries: 10",
+		"  Index flags: 13",
+		"  Sorted arrays: 0",
+		`Error: invalid boolean query "(unbalanced": missing )`,
+		"Unknown command :nope (try :help)",
+		"/Documents",
+		"/home/user/readme.txt",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("Session output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
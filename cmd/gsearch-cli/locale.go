@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// locale ties case-insensitive matching and name/path sorting to one
+// language, so that the entries a query treats as equal also sort together
+type locale struct {
+	tag  language.Tag
+	fold func(string) string
+	coll *collate.Collator
+}
+
+// newLocale returns the locale for a BCP 47 tag such as "und", "de", or
+// "tr". Turkic languages lowercase with their own dotted and dotless i rules;
+// all others use full Unicode case folding, which for example matches German
+// ß with ss. Unless caseSensitive, sorting ignores case as matching does.
+// With natural, runs of digits sort by their numeric value, so file2 comes
+// before file10.
+func newLocale(name string, caseSensitive, natural bool) (*locale, error) {
+	tag, err := language.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locale %q: %w", name, err)
+	}
+
+	newCaser := cases.Fold
+	switch base, _ := tag.Base(); base.String() {
+	case "tr", "az":
+		newCaser = func(...cases.Option) cases.Caser { return cases.Lower(tag) }
+	}
+	// A Caser keeps state between calls, and the search folds from
+	// several goroutines, so each call borrows one of its own
+	casers := sync.Pool{New: func() any {
+		c := newCaser()
+		return &c
+	}}
+	fold := func(s string) string {
+		c := casers.Get().(*cases.Caser)
+		defer casers.Put(c)
+		return c.String(s)
+	}
+
+	var collOpts []collate.Option
+	if !caseSensitive {
+		collOpts = append(collOpts, collate.IgnoreCase)
+	}
+	if natural {
+		collOpts = append(collOpts, collate.Numeric)
+	}
+
+	return &locale{
+		tag:  tag,
+		fold: fold,
+		coll: collate.New(tag, collOpts...),
+	}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// searchAndSort runs a case-insensitive name search under the given locale
+// and returns the matching names sorted by name
+func searchAndSort(t *testing.T, localeName, query string, names ...string) []string {
+	t.Helper()
+	loc, err := newLocale(localeName, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := &db.Database{}
+	for _, name := range names {
+		database.Files = append(database.Files, &db.Entry{Name: name, Type: db.EntryTypeFile})
+	}
+	result := mustSearch(t, database, db.SearchOptions{Query: query, SearchInFiles: true, Fold: loc.fold})
+	sortResults(result, sortFieldName, false, loc.coll)
+
+	var got []string
+	for _, file := range result.Files {
+		got = append(got, file.Name)
+	}
+	return got
+}
+
+func TestLocaleTurkish(t *testing.T) {
+	names := []string{"IRMAK.txt", "ırmak-2.txt", "irmak-3.txt", "İzmir.txt"}
+
+	// In Turkish, I folds to dotless ı and İ to i
+	if got := strings.Join(searchAndSort(t, "tr", "ırmak", names...), ","); got != "ırmak-2.txt,IRMAK.txt" {
+		t.Errorf("tr: ırmak matched %q", got)
+	}
+	if got := strings.Join(searchAndSort(t, "tr", "izmir", names...), ","); got != "İzmir.txt" {
+		t.Errorf("tr: izmir matched %q", got)
+	}
+
+	// Language-neutral folding treats I as i instead
+	if got := strings.Join(searchAndSort(t, "und", "irmak", names...), ","); got != "irmak-3.txt,IRMAK.txt" {
+		t.Errorf("und: irmak matched %q", got)
+	}
+}
+
+func TestLocaleGerman(t *testing.T) {
+	names := []string{"Straße.txt", "STRASSE-alt.txt", "Strand.txt", "äpfel.txt", "Zebra.txt", "apfel.txt"}
+
+	// Full case folding matches ß with ss, in either direction
+	if got := strings.Join(searchAndSort(t, "de", "strasse", names...), ","); got != "STRASSE-alt.txt,Straße.txt" {
+		t.Errorf("de: strasse matched %q", got)
+	}
+	if got := strings.Join(searchAndSort(t, "de", "STRAßE", names...), ","); got != "STRASSE-alt.txt,Straße.txt" {
+		t.Errorf("de: STRAßE matched %q", got)
+	}
+
+	// Sorting follows the collation, ignoring case as matching does: ä
+	// sorts with a rather than after z
+	if got := strings.Join(searchAndSort(t, "de", ".txt", names...), ","); got != "apfel.txt,äpfel.txt,Strand.txt,STRASSE-alt.txt,Straße.txt,Zebra.txt" {
+		t.Errorf("de: sorted %q", got)
+	}
+}
+
+func TestLocaleInvalid(t *testing.T) {
+	if _, err := newLocale("not a locale!", false, true); err == nil {
+		t.Error("Expected error for invalid locale")
+	}
+}
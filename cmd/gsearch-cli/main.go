@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gsearch-cli/internal/db"
 	"github.com/gsearch-cli/version"
@@ -21,17 +28,129 @@ const (
 	outputFormatText outputFormat = "text"
 	outputFormatJSON outputFormat = "json"
 	outputFormatCSV  outputFormat = "csv"
+
+	// outputFormatJSONL emits one compact JSON object per line (NDJSON)
+	outputFormatJSONL outputFormat = "jsonl"
+
+	// outputFormatJSON0 emits one compact JSON object per result, each
+	// terminated by a NUL byte
+	outputFormatJSON0 outputFormat = "json0"
+
+	// outputFormatSunburst emits a nested folder tree with aggregate sizes
+	outputFormatSunburst outputFormat = "sunburst-json"
+
+	// outputFormatRsyncFilter emits include/exclude rules for rsync --filter
+	outputFormatRsyncFilter outputFormat = "rsync-filter"
+
+	// outputFormatNamesSorted emits the distinct result names, sorted, one
+	// per line
+	outputFormatNamesSorted outputFormat = "names-sorted"
+
+	// outputFormatShell emits each path quoted for pasting into a shell
+	outputFormatShell outputFormat = "shell"
+
+	// outputFormatNull emits each full path followed by a NUL byte, for
+	// xargs -0
+	outputFormatNull outputFormat = "null"
+
+	// outputFormatPaths emits each full path on its own line and nothing
+	// else
+	outputFormatPaths outputFormat = "paths"
+
+	// outputFormatTemplate emits each result through the -template-str or
+	// -template-file Go template, one per line
+	outputFormatTemplate outputFormat = "template"
+
+	// outputFormatXML emits the results as a <results> XML document
+	outputFormatXML outputFormat = "xml"
 )
 
+// outputFormats lists the accepted -output values in the order shown in errors
+var outputFormats = []outputFormat{
+	outputFormatText,
+	outputFormatJSON,
+	outputFormatJSONL,
+	outputFormatJSON0,
+	outputFormatCSV,
+	outputFormatSunburst,
+	outputFormatRsyncFilter,
+	outputFormatNamesSorted,
+	outputFormatShell,
+	outputFormatNull,
+	outputFormatPaths,
+	outputFormatTemplate,
+	outputFormatXML,
+}
+
 type sortField string
 
 const (
-	sortFieldName sortField = "name"
-	sortFieldPath sortField = "path"
-	sortFieldSize sortField = "size"
-	sortFieldMTime sortField = "mtime"
+	sortFieldName    sortField = "name"
+	sortFieldPath    sortField = "path"
+	sortFieldSize    sortField = "size"
+	sortFieldMTime   sortField = "mtime"
+	sortFieldPathLen sortField = "pathlen" // full path length in bytes
+	sortFieldScore   sortField = "score"   // relevance to -q, highest first
+	sortFieldExt     sortField = "ext"     // file extension, then name
 )
 
+// sortFields lists the accepted -sort values in the order shown in errors
+var sortFields = []sortField{
+	sortFieldName,
+	sortFieldPath,
+	sortFieldSize,
+	sortFieldMTime,
+	sortFieldPathLen,
+	sortFieldScore,
+	sortFieldExt,
+}
+
+// oneOf reports whether v is in valid
+func oneOf[T comparable](v T, valid []T) bool {
+	for _, candidate := range valid {
+		if v == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// choiceList renders valid values for an error message, e.g. "a, b, or c"
+func choiceList[T ~string](valid []T) string {
+	names := make([]string, len(valid))
+	for i, v := range valid {
+		names[i] = string(v)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ", ") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// splitDBPaths flattens -db values, each of which may itself be a
+// comma-separated list
+func splitDBPaths(values []string) []string {
+	var paths []string
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
 func showVersion() {
 	programName := "gsearch-cli"
 	if len(os.Args) > 0 {
@@ -42,6 +161,8 @@ func showVersion() {
 }
 
 func main() {
+	start := time.Now()
+
 	// Check for "help" or "version" as single argument before flag parsing
 	if len(os.Args) == 2 {
 		arg := os.Args[1]
@@ -55,27 +176,143 @@ func main() {
 		}
 	}
 
+	var queries stringList
+	flag.Var(&queries, "q", "Search query (supports wildcards: * and ?); repeat with -merge-sorted")
+	var dbFlags stringList
+	flag.Var(&dbFlags, "db", "Path to fsearch database file (default "+defaultDBPath+"); repeat or separate with commas to search several")
+	var autoDirs stringList
+	flag.Var(&autoDirs, "auto-dir", "Also look for databases in this directory with -auto; may be repeated")
+	var excludes stringList
+	flag.Var(&excludes, "exclude", "Drop -q matches whose name matches this wildcard pattern (or full path, if it contains /); may be repeated")
 	var (
-		dbPath         = flag.String("db", defaultDBPath, "Path to fsearch database file")
-		query          = flag.String("q", "", "Search query (supports wildcards: * and ?)")
-		caseSensitive  = flag.Bool("case", false, "Case-sensitive search")
-		wholeWord      = flag.Bool("whole", false, "Match whole words only")
-		searchPath     = flag.String("path", "", "Search in full path (instead of just name, supports wildcards)")
-		filesOnly      = flag.Bool("files", false, "Search only files")
-		foldersOnly    = flag.Bool("folders", false, "Search only folders")
-		maxResults     = flag.Int("max", 0, "Maximum number of results (0 = unlimited)")
-		showStats      = flag.Bool("stats", false, "Show database statistics")
-		outputFormatStr = flag.String("output", "text", "Output format: text, json, or csv")
-		sortBy          = flag.String("sort", "", "Sort results by: name, path, size, or mtime")
+		auto            = flag.Bool("auto", false, "Use the newest fsearch database found in the standard locations instead of -db")
+		listDBs         = flag.Bool("list", false, "With -auto, list the databases found instead of searching")
+		mergeSortedFlag = flag.Bool("merge-sorted", false, "Run each -q separately and merge the results into one -sort ordered listing")
+		caseSensitive   = flag.Bool("case", false, "Case-sensitive search")
+		natural         = flag.Bool("natural", true, "Sort names and paths with runs of digits compared by value, so file2 precedes file10")
+		localeName      = flag.String("locale", "und", "BCP 47 language for case folding and sort order (e.g. de, tr)")
+		noNormalize     = flag.Bool("no-normalize", false, "Match names byte for byte instead of normalizing them and the query to Unicode NFC")
+		foldAccents     = flag.Bool("fold-accents", false, "Ignore accents when matching, so cafe matches café")
+		wholeWord       = flag.Bool("whole", false, "Match whole words only")
+		matchStem       = flag.Bool("stem", false, "Match -q against names without their extension; the whole stem unless -whole or wildcards")
+		containsFlag    = flag.Bool("contains", false, "Let a wildcard -q match anywhere in a name rather than the whole name")
+		useRegex        = flag.Bool("regex", false, "Treat -q as a Go regular expression instead of a wildcard pattern")
+		boolean         = flag.Bool("boolean", false, "Read -q as terms combined with AND, OR, NOT, and parentheses")
+		fuzzy           = flag.Bool("fuzzy", false, "Match names containing the characters of -q in order, scored by how close together they are")
+		searchPath      = flag.String("path", "", "Search in full path (instead of just name, supports wildcards)")
+		excludePath     = flag.Bool("exclude-path", false, "Match every -exclude pattern against the full path")
+		segmentMatch    = flag.Bool("segment-match", false, "With -path, match whole path segments only")
+		andPath         = flag.String("and-path", "", "With -q, also require the full path to match this pattern, as -path would")
+		pathDepth       = flag.Int("depth", -1, "With -path, keep only entries at most this many levels below the match (0 = the match itself, -1 = unlimited)")
+		filesOnly       = flag.Bool("files", false, "Search only files")
+		foldersOnly     = flag.Bool("folders", false, "Search only folders")
+		maxResults      = flag.Int("max", 0, "Maximum number of results (0 = unlimited)")
+		maxPerDB        = flag.Int("max-per-db", 0, "Maximum number of results from each database (0 = unlimited)")
+		exactSize       = flag.String("size", "", "Only files of exactly this size, e.g. 0, 4096, or 1.5M")
+		minSizeStr      = flag.String("minsize", "", "Only files of at least this size, e.g. 10K, 5M, or 2G")
+		maxSizeStr      = flag.String("maxsize", "", "Only files of at most this size (0 = no limit)")
+		afterStr        = flag.String("after", "", "Only entries modified after this time: RFC 3339, a date, or a duration ago such as -7d")
+		beforeStr       = flag.String("before", "", "Only entries modified before this time: RFC 3339, a date, or a duration ago such as -24h")
+		newerPath       = flag.String("newer", "", "Only entries modified after this file was, like find -newer")
+		within          = flag.String("within", "", "Only entries modified within this long before now, e.g. 30d, 2w, 12h, or 90m")
+		filterExpr      = flag.String("filter", "", "Keep only results matching an expression, e.g. 'size > 1MB && ext == \"go\"'")
+		maxPerExt       = flag.Int("max-per-ext", 0, "Maximum number of files per extension (0 = unlimited)")
+		limitFiles      = flag.Int("limit-files", 0, "Maximum number of files (0 = unlimited); -max still caps the total")
+		limitFolders    = flag.Int("limit-folders", 0, "Maximum number of folders (0 = unlimited); -max still caps the total")
+		follow          = flag.Bool("follow", false, "Also include the contents of folders matching -q")
+		followDepth     = flag.Int("follow-depth", 0, "Levels below a matched folder to include with -follow (0 = unlimited)")
+		matchPath       = flag.Bool("match-path", false, "Match -q against full paths as well as names")
+		weightName      = flag.Float64("weight-name", db.DefaultNameWeight, "Relevance weight of a name hit with -match-path")
+		weightPath      = flag.Float64("weight-path", db.DefaultPathWeight, "Relevance weight of a path hit with -match-path")
+		minScore        = flag.Float64("min-score", 0, "Drop matches with a relevance score below this (0-1)")
+		sampleSize      = flag.Int("sample", 0, "Return a random sample of N results (0 = all)")
+		dedupe          = flag.Bool("dedupe", false, "Keep only the first result of each name (see -dedupe-by)")
+		dedupeBy        = flag.String("dedupe-by", "", "What -dedupe compares: name (default), name+size, or path")
+		duplicates      = flag.Bool("duplicates", false, "List groups of matched files that share a size, as candidate duplicates")
+		stratify        = flag.String("stratify", "", "Spread -sample evenly across groups: ext or dir")
+		seed            = flag.Int64("seed", 0, "Random seed for -sample (0 = time-based)")
+		serverMode      = flag.Bool("server", false, "Answer newline-delimited JSON queries from stdin until EOF")
+		interactive     = flag.Bool("interactive", false, "Read queries from stdin, one per line, and print each one's results")
+		showStats       = flag.Bool("stats", false, "Show database statistics")
+		jsonCompact     = flag.Bool("json-compact", false, "With -output json, write the JSON on one line instead of indented")
+		showIndex       = flag.Bool("show-index", false, "With JSON output, add each entry's database index and each folder's FSearch db_index")
+		matchRange      = flag.Bool("match-range", false, "With JSON output, add match_start and match_end, the byte range of each name the query matched")
+		jsonSummaryFlag = flag.Bool("summary", false, "With -output json, wrap the results in an object with their count and total size")
+		depthStats      = flag.Bool("depth-stats", false, "With -stats, also show entries per folder depth and the largest files")
+		indexStatsFlag  = flag.Bool("index-stats", false, "Check the database's sorted arrays and show which -sort keys they cover")
+		dbInfo          = flag.Bool("db-info", false, "Show the database format header without loading entries")
+		unreachable     = flag.Bool("unreachable", false, "List entries whose parent chain never reaches the root")
+		strict          = flag.Bool("strict", false, "Refuse a database with a newer minor format version instead of warning")
+		verify          = flag.Bool("verify", false, "Check the database's structure and report every problem, without searching")
+		parentOf        = flag.String("parent-of", "", "Print the folder containing the entry at this path (or with this name)")
+		ancestors       = flag.Bool("ancestors", false, "With -parent-of, print every folder up to the root")
+		reindexHint     = flag.Bool("reindex-hint", false, "Report files modified after the database was written and whether to re-index")
+		caseCollisions  = flag.Bool("case-collisions", false, "List sibling entries whose names differ only by case")
+		outputFormatStr = flag.String("output", "text", "Output format: text, json, jsonl, json0, csv, sunburst-json, rsync-filter, names-sorted, shell, null, paths, template, or xml")
+		templateStr     = flag.String("template-str", "", "With -output template, the Go template for each result, e.g. '{{.Path}}\\t{{.SizeHuman}}'")
+		templateFile    = flag.String("template-file", "", "With -output template, read the template for each result from this file")
+		metaFooter      = flag.Bool("meta", false, "End jsonl output with a _meta line holding count, truncation, and timing")
+		timeAs          = flag.String("time-as", "", "Modification time fields in JSON/XML/CSV: unix, rfc3339, or both")
+		sortBy          = flag.String("sort", "", "Sort results by: name, path, size, mtime, pathlen, score, or ext")
+		sortDesc        = flag.Bool("desc", false, "Sort in descending order")
+		groupBy         = flag.String("group", "", "Where folders go in the output: dirs-first (default), dirs-last, or mixed in -sort order")
+		collateNames    = flag.Bool("collate", false, "Sort -output names-sorted by -locale instead of byte order")
+		flagDupes       = flag.Bool("flag-dupes", false, "In text output, mark results whose name occurs more than once with [dup]")
+		alwaysCount     = flag.Bool("always-count", false, "In text output, print the result count line even when nothing matched")
+		colorFlag       = flag.String("color", "", "Color text output: auto (default, when stdout is a terminal), always, or never")
+		noEmoji         = flag.Bool("no-emoji", false, "In text output, leave out the folder and file icons")
+		unitsFlag       = flag.String("units", "", "How text output writes sizes: binary (KiB, default), si (KB), or bytes")
+		pathSep         = flag.String("sep", "/", "Separator between the components of printed paths, e.g. \\ for Windows-style paths")
+		relativeTo      = flag.String("relative", "", "Print result paths relative to this directory; paths outside it are printed in full with a warning")
+		compactPaths    = flag.Bool("compact-paths", false, "In text output, print the directory shared by all results once and paths relative to it")
+		maxDepth        = flag.Int("maxdepth", 0, "Keep only entries at most this many levels below the root (0 = unlimited)")
+		minDepth        = flag.Int("mindepth", 0, "With -q, keep only entries at least this many levels below the root")
+		first           = flag.Bool("first", false, "Output only the first result (after sorting)")
+		nthFlag         = flag.Int("nth", 0, "Output only the Nth result (after sorting), counting from 1")
+		quiet           = flag.Bool("quiet", false, "Print nothing; exit 0 if anything matches and 1 if not, like grep -q")
+		openCmd         = flag.String("open-cmd", "", "Print the command that runs CMD on the first result, e.g. xdg-open")
+		dryRun          = flag.Bool("dry-run", false, "Print the -exec commands instead of running them")
+		workers         = flag.Int("workers", 1, "Number of -exec commands to run at once")
+		outputPath      = flag.String("o", "", "Write output to file instead of stdout")
+		gzipOutput      = flag.Bool("gzip", false, "Gzip-compress the -o output file")
+		checkpointPath  = flag.String("checkpoint", "", "Record export progress in file so an interrupted -o export can resume")
+		pathCacheSize   = flag.Int("path-cache-size", db.DefaultPathCacheSize, "Maximum number of full paths cached during -path search (0 = no cache)")
+		timeout         = flag.String("timeout", "", "Stop searching after this long (e.g. 500ms, 2s)")
+		partial         = flag.Bool("partial", false, "On -timeout, print the matches found so far instead of failing")
+		retries         = flag.Int("retry", 0, "Retry loading the database N times if it is unavailable")
+		retryInterval   = flag.String("retry-interval", "1s", "Wait between load retries (e.g. 500ms, 1s, 1m)")
+		etag            = flag.Bool("etag", false, "Print an ETag for this query and database to stderr")
+		verbose         = flag.Bool("verbose", false, "Print a timing breakdown to stderr")
+		verboseShort    = flag.Bool("v", false, "Print a timing breakdown to stderr (alias for -verbose)")
+		dumpEntry       = flag.Int("dump-entry", -1, "Developer: hex dump the on-disk record of entry N")
+		dumpType        = flag.String("type", "file", "Developer: record type for -dump-entry: file or folder")
 		showHelp        = flag.Bool("help", false, "Show detailed help")
 		flagHelp        = flag.Bool("h", false, "Show detailed help (alias for -help)")
 	)
+	flag.BoolVar(sortDesc, "reverse", false, "Sort in descending order (alias for -desc)")
 
 	flag.Usage = func() {
 		showUsage()
 	}
 
-	flag.Parse()
+	// -exec takes a whole command line, so it is split off before parsing
+	args, execCmd, err := splitExecArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	flag.CommandLine.Parse(args)
+	query := ""
+	if len(queries) > 0 {
+		query = queries[0]
+	}
+	if len(queries) > 1 && !*mergeSortedFlag {
+		fmt.Fprintf(os.Stderr, "Error: -q may be repeated only with -merge-sorted\n")
+		os.Exit(1)
+	}
+	// -interactive reads its queries from stdin, so the flags that refine a
+	// -q search apply to each of them
+	hasQuery := query != "" || *interactive
 
 	// Show help if requested
 	if *showHelp || *flagHelp {
@@ -83,10 +320,25 @@ func main() {
 		os.Exit(0)
 	}
 
+	timer := &phaseTimer{enabled: *verbose || *verboseShort}
+
 	// Validate output format
 	format := outputFormat(strings.ToLower(*outputFormatStr))
-	if format != outputFormatText && format != outputFormatJSON && format != outputFormatCSV {
-		fmt.Fprintf(os.Stderr, "Error: invalid output format %q. Must be: text, json, or csv\n", *outputFormatStr)
+	if !oneOf(format, outputFormats) {
+		fmt.Fprintf(os.Stderr, "Error: invalid output format %q. Must be: %s\n", *outputFormatStr, choiceList(outputFormats))
+		os.Exit(1)
+	}
+
+	loc, err := newLocale(*localeName, *caseSensitive, *natural)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate time format
+	outOpts := outputOptions{timeAs: timeFormat(strings.ToLower(*timeAs))}
+	if outOpts.timeAs != "" && !oneOf(outOpts.timeAs, timeFormats) {
+		fmt.Fprintf(os.Stderr, "Error: invalid time format %q. Must be: %s\n", *timeAs, choiceList(timeFormats))
 		os.Exit(1)
 	}
 
@@ -94,86 +346,1557 @@ func main() {
 	var sortFieldVal sortField
 	if *sortBy != "" {
 		sortFieldVal = sortField(strings.ToLower(*sortBy))
-		if sortFieldVal != sortFieldName && sortFieldVal != sortFieldPath && sortFieldVal != sortFieldSize && sortFieldVal != sortFieldMTime {
-			fmt.Fprintf(os.Stderr, "Error: invalid sort field %q. Must be: name, path, size, or mtime\n", *sortBy)
+		if !oneOf(sortFieldVal, sortFields) {
+			fmt.Fprintf(os.Stderr, "Error: invalid sort field %q. Must be: %s\n", *sortBy, choiceList(sortFields))
 			os.Exit(1)
 		}
 	}
 
-	// Expand ~ in path
-	if strings.HasPrefix(*dbPath, "~") {
-		home, err := os.UserHomeDir()
+	outOpts.order.mode = groupDirsFirst
+	if *groupBy != "" {
+		outOpts.order.mode = groupMode(strings.ToLower(*groupBy))
+		if !oneOf(outOpts.order.mode, groupModes) {
+			fmt.Fprintf(os.Stderr, "Error: invalid group mode %q. Must be: %s\n", *groupBy, choiceList(groupModes))
+			os.Exit(1)
+		}
+		if outOpts.order.mode == groupMixed && *sortBy == "" {
+			fmt.Fprintf(os.Stderr, "Error: -group mixed requires -sort\n")
+			os.Exit(1)
+		}
+	}
+
+	if *gzipOutput {
+		if *outputPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -gzip requires -o\n")
+			os.Exit(1)
+		}
+		if *checkpointPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: -gzip cannot be combined with -checkpoint\n")
+			os.Exit(1)
+		}
+	}
+
+	if *checkpointPath != "" {
+		if *outputPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -checkpoint requires -o\n")
+			os.Exit(1)
+		}
+		if format != outputFormatText && format != outputFormatCSV {
+			fmt.Fprintf(os.Stderr, "Error: -checkpoint supports only text or csv output\n")
+			os.Exit(1)
+		}
+	}
+
+	if *compactPaths {
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -compact-paths requires -output text\n")
+			os.Exit(1)
+		}
+		if *checkpointPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: -compact-paths cannot be combined with -checkpoint\n")
+			os.Exit(1)
+		}
+		outOpts.compactPaths = true
+	}
+
+	if *relativeTo != "" {
+		if !strings.HasPrefix(*relativeTo, "/") {
+			fmt.Fprintf(os.Stderr, "Error: -relative must be an absolute path\n")
+			os.Exit(1)
+		}
+		for _, conflict := range []struct {
+			name string
+			set  bool
+		}{
+			{"-compact-paths", *compactPaths},
+			{"-checkpoint", *checkpointPath != ""},
+			{"-exec", execCmd != nil},
+			{"-open-cmd", *openCmd != ""},
+			{"-output rsync-filter", format == outputFormatRsyncFilter},
+			{"-server", *serverMode},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: -relative cannot be combined with %s\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+		outOpts.relativeTo = filepath.Clean(*relativeTo)
+	}
+
+	if *pathSep != "/" {
+		if *pathSep == "" {
+			fmt.Fprintf(os.Stderr, "Error: -sep cannot be empty\n")
+			os.Exit(1)
+		}
+		for _, conflict := range []struct {
+			name string
+			set  bool
+		}{
+			{"-compact-paths", *compactPaths},
+			{"-checkpoint", *checkpointPath != ""},
+			{"-output rsync-filter", format == outputFormatRsyncFilter},
+			{"-server", *serverMode},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: -sep cannot be combined with %s\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+		outOpts.pathSep = *pathSep
+	}
+
+	var sizeFilter *int64
+	if *exactSize != "" {
+		n, err := parseSize(*exactSize)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: failed to get home directory: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: invalid -size: %v\n", err)
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -size requires -q\n")
+			os.Exit(1)
+		}
+		if *minSizeStr != "" || *maxSizeStr != "" {
+			fmt.Fprintf(os.Stderr, "Error: -size cannot be combined with -minsize or -maxsize\n")
 			os.Exit(1)
 		}
-		*dbPath = filepath.Join(home, strings.TrimPrefix(*dbPath, "~"+string(filepath.Separator)))
+		sizeFilter = &n
 	}
 
-	// Load database
-	database, err := db.Load(*dbPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to load database: %v\n", err)
+	// Size range; folders are checked too when the database indexes sizes
+	var minSize, maxSize int64
+	for _, bound := range []struct {
+		name  string
+		value string
+		dst   *int64
+	}{{"minsize", *minSizeStr, &minSize}, {"maxsize", *maxSizeStr, &maxSize}} {
+		if bound.value == "" {
+			continue
+		}
+		n, err := parseSize(bound.value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -%s: %v\n", bound.name, err)
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -%s requires -q\n", bound.name)
+			os.Exit(1)
+		}
+		*bound.dst = n
+	}
+	if maxSize > 0 && minSize > maxSize {
+		fmt.Fprintf(os.Stderr, "Error: -minsize must not exceed -maxsize\n")
 		os.Exit(1)
 	}
 
-	// Show statistics if requested
-	if *showStats {
-		showDatabaseStats(database)
-		return
+	if len(excludes) > 0 && !hasQuery {
+		fmt.Fprintf(os.Stderr, "Error: -exclude requires -q\n")
+		os.Exit(1)
+	}
+	if *excludePath && len(excludes) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -exclude-path requires -exclude\n")
+		os.Exit(1)
 	}
 
-	// Perform search
-	if *query == "" && *searchPath == "" {
-		fmt.Fprintf(os.Stderr, "Error: must provide either -q (query) or -path (path search)\n")
-		flag.Usage()
+	// Modification time window; relative bounds count back from now
+	var mtimeAfter, mtimeBefore time.Time
+	now := time.Now()
+	for _, bound := range []struct {
+		name  string
+		value string
+		dst   *time.Time
+	}{{"after", *afterStr, &mtimeAfter}, {"before", *beforeStr, &mtimeBefore}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseTimeBound(bound.value, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -%s: %v\n", bound.name, err)
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -%s requires -q\n", bound.name)
+			os.Exit(1)
+		}
+		*bound.dst = t
+	}
+	afterName := "-after"
+	if *newerPath != "" {
+		if *afterStr != "" {
+			fmt.Fprintf(os.Stderr, "Error: -newer cannot be combined with -after\n")
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -newer requires -q\n")
+			os.Exit(1)
+		}
+		info, err := os.Stat(*newerPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read the -newer reference file: %v\n", err)
+			os.Exit(1)
+		}
+		mtimeAfter, afterName = info.ModTime(), "-newer"
+	}
+	if *within != "" {
+		d, err := parseDuration(*within)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid -within %q: want a duration such as 30d, 2w, 12h, or 90m\n", *within)
+			os.Exit(1)
+		}
+		if *afterStr != "" || *newerPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: -within cannot be combined with -after or -newer\n")
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -within requires -q\n")
+			os.Exit(1)
+		}
+		mtimeAfter, afterName = now.Add(-d), "-within"
+	}
+	if !mtimeAfter.IsZero() && !mtimeBefore.IsZero() && !mtimeAfter.Before(mtimeBefore) {
+		fmt.Fprintf(os.Stderr, "Error: %s must be earlier than -before\n", afterName)
 		os.Exit(1)
 	}
 
-	var result *db.SearchResult
-	if *searchPath != "" {
-		result = database.SearchByPath(*searchPath, *caseSensitive)
-	} else {
-		opts := db.SearchOptions{
-			Query:           *query,
-			CaseSensitive:   *caseSensitive,
-			MatchWholeWord:  *wholeWord,
-			SearchInFiles:   !*foldersOnly,
-			SearchInFolders: !*filesOnly,
-			MaxResults:      *maxResults,
+	var filter filterNode
+	if *filterExpr != "" {
+		var err error
+		if filter, err = parseFilter(*filterExpr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -filter: %v\n", err)
+			os.Exit(1)
 		}
-		result = database.Search(opts)
 	}
 
-	// Sort results if requested
-	if *sortBy != "" {
-		sortResults(result, sortFieldVal)
+	if *mergeSortedFlag {
+		if query == "" {
+			fmt.Fprintf(os.Stderr, "Error: -merge-sorted requires -q\n")
+			os.Exit(1)
+		}
+		if *sortBy == "" {
+			fmt.Fprintf(os.Stderr, "Error: -merge-sorted requires -sort\n")
+			os.Exit(1)
+		}
+		if *searchPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: -merge-sorted cannot be combined with -path\n")
+			os.Exit(1)
+		}
 	}
 
-	// Print results in requested format
-	printResults(result, format)
-}
+	if *interactive {
+		for _, conflict := range []struct {
+			name string
+			set  bool
+		}{
+			{"-q", query != ""},
+			{"-path", *searchPath != ""},
+			{"-server", *serverMode},
+			{"-merge-sorted", *mergeSortedFlag},
+			{"-sample", *sampleSize > 0},
+			{"-exec", execCmd != nil},
+			{"-open-cmd", *openCmd != ""},
+			{"-o", *outputPath != ""},
+			{"-meta", *metaFooter},
+			{"-output sunburst-json", format == outputFormatSunburst},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: -interactive cannot be combined with %s\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+	}
 
-func showDatabaseStats(database *db.Database) {
-	fmt.Printf("Database Statistics:\n")
-	fmt.Printf("  Folders: %d\n", len(database.Folders))
-	fmt.Printf("  Files: %d\n", len(database.Files))
-	fmt.Printf("  Total entries: %d\n", len(database.Folders)+len(database.Files))
-	fmt.Printf("  Index flags: %d\n", database.IndexFlags)
-	fmt.Printf("  Sorted arrays: %d\n", len(database.SortedArrays))
-}
+	if *quiet {
+		if query == "" && *searchPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -quiet requires -q or -path\n")
+			os.Exit(1)
+		}
+		for _, conflict := range []struct {
+			name string
+			set  bool
+		}{
+			{"-o", *outputPath != ""},
+			{"-exec", execCmd != nil},
+			{"-open-cmd", *openCmd != ""},
+			{"-checkpoint", *checkpointPath != ""},
+			{"-duplicates", *duplicates},
+			{"-server", *serverMode},
+			{"-interactive", *interactive},
+			{"-first", *first},
+			{"-nth", *nthFlag > 0},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: -quiet cannot be combined with %s\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+	}
 
+	if *duplicates {
+		if query == "" && *searchPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -duplicates requires -q or -path\n")
+			os.Exit(1)
+		}
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -duplicates requires -output text\n")
+			os.Exit(1)
+		}
+		// The limits would group an arbitrary part of the matches, missing
+		// duplicates left out
+		for _, conflict := range []struct {
+			name string
+			set  bool
+		}{
+			{"-exec", execCmd != nil},
+			{"-open-cmd", *openCmd != ""},
+			{"-checkpoint", *checkpointPath != ""},
+			{"-server", *serverMode},
+			{"-interactive", *interactive},
+			{"-max", *maxResults > 0},
+			{"-max-per-db", *maxPerDB > 0},
+			{"-max-per-ext", *maxPerExt > 0},
+			{"-limit-files", *limitFiles > 0},
+			{"-sample", *sampleSize > 0},
+			{"-first", *first},
+			{"-nth", *nthFlag > 0},
+		} {
+			if conflict.set {
+				fmt.Fprintf(os.Stderr, "Error: -duplicates cannot be combined with %s\n", conflict.name)
+				os.Exit(1)
+			}
+		}
+	}
 
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+	if *jsonSummaryFlag {
+		if format != outputFormatJSON {
+			fmt.Fprintf(os.Stderr, "Error: -summary requires -output json\n")
+			os.Exit(1)
+		}
+		outOpts.summary = true
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	if *jsonCompact {
+		if format != outputFormatJSON {
+			fmt.Fprintf(os.Stderr, "Error: -json-compact requires -output json\n")
+			os.Exit(1)
+		}
+		outOpts.compactJSON = true
+	}
+	if *showIndex {
+		if format != outputFormatJSON && format != outputFormatJSONL && format != outputFormatJSON0 {
+			fmt.Fprintf(os.Stderr, "Error: -show-index requires -output json, jsonl, or json0\n")
+			os.Exit(1)
+		}
+		outOpts.showIndex = true
+	}
+	if *matchRange {
+		if format != outputFormatJSON && format != outputFormatJSONL && format != outputFormatJSON0 {
+			fmt.Fprintf(os.Stderr, "Error: -match-range requires -output json, jsonl, or json0\n")
+			os.Exit(1)
+		}
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -match-range requires -q\n")
+			os.Exit(1)
+		}
+	}
+
+	if *depthStats && !*showStats {
+		fmt.Fprintf(os.Stderr, "Error: -depth-stats requires -stats\n")
+		os.Exit(1)
+	}
+
+	if *ancestors && *parentOf == "" {
+		fmt.Fprintf(os.Stderr, "Error: -ancestors requires -parent-of\n")
+		os.Exit(1)
+	}
+
+	if format == outputFormatTemplate {
+		if (*templateStr == "") == (*templateFile == "") {
+			fmt.Fprintf(os.Stderr, "Error: -output template requires one of -template-str or -template-file\n")
+			os.Exit(1)
+		}
+		tmpl, err := parseOutputTemplate(*templateStr, *templateFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid template: %v\n", err)
+			os.Exit(1)
+		}
+		outOpts.template = tmpl
+	} else if *templateStr != "" || *templateFile != "" {
+		fmt.Fprintf(os.Stderr, "Error: -template-str and -template-file require -output template\n")
+		os.Exit(1)
+	}
+
+	if *flagDupes {
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -flag-dupes requires -output text\n")
+			os.Exit(1)
+		}
+		outOpts.flagDupes = true
+	}
+
+	if *alwaysCount {
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -always-count requires -output text\n")
+			os.Exit(1)
+		}
+		outOpts.alwaysCount = true
+	}
+
+	colorVal := colorAuto
+	if *colorFlag != "" {
+		colorVal = colorMode(strings.ToLower(*colorFlag))
+		if !oneOf(colorVal, colorModes) {
+			fmt.Fprintf(os.Stderr, "Error: invalid color mode %q. Must be: %s\n", *colorFlag, choiceList(colorModes))
+			os.Exit(1)
+		}
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -color requires -output text\n")
+			os.Exit(1)
+		}
+	}
+	// NO_COLOR (https://no-color.org) turns off automatic color only
+	outOpts.style.color = format == outputFormatText && (colorVal == colorAlways ||
+		colorVal == colorAuto && *outputPath == "" && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout))
+
+	if *noEmoji {
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -no-emoji requires -output text\n")
+			os.Exit(1)
+		}
+		outOpts.style.noEmoji = true
+	}
+
+	outOpts.style.units = unitsBinary
+	if *unitsFlag != "" {
+		outOpts.style.units = sizeUnits(strings.ToLower(*unitsFlag))
+		if !oneOf(outOpts.style.units, sizeUnitModes) {
+			fmt.Fprintf(os.Stderr, "Error: invalid units %q. Must be: %s\n", *unitsFlag, choiceList(sizeUnitModes))
+			os.Exit(1)
+		}
+		if format != outputFormatText {
+			fmt.Fprintf(os.Stderr, "Error: -units requires -output text\n")
+			os.Exit(1)
+		}
+	}
+
+	if *collateNames {
+		if format != outputFormatNamesSorted {
+			fmt.Fprintf(os.Stderr, "Error: -collate requires -output names-sorted\n")
+			os.Exit(1)
+		}
+		outOpts.collator = loc.coll
+	}
+
+	if *indexStatsFlag && format != outputFormatText && format != outputFormatJSON {
+		fmt.Fprintf(os.Stderr, "Error: -index-stats supports only text or json output\n")
+		os.Exit(1)
+	}
+
+	if *metaFooter && format != outputFormatJSONL {
+		fmt.Fprintf(os.Stderr, "Error: -meta requires -output jsonl\n")
+		os.Exit(1)
+	}
+
+	if *maxPerDB < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -max-per-db must not be negative\n")
+		os.Exit(1)
+	}
+
+	if *maxPerExt < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -max-per-ext must not be negative\n")
+		os.Exit(1)
+	}
+
+	if *limitFiles < 0 || *limitFolders < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -limit-files and -limit-folders must not be negative\n")
+		os.Exit(1)
+	}
+
+	// Validate relevance scoring
+	if *minScore < 0 || *minScore > 1 {
+		fmt.Fprintf(os.Stderr, "Error: -min-score must be between 0 and 1\n")
+		os.Exit(1)
+	}
+	scored := *minScore > 0 || sortFieldVal == sortFieldScore || *fuzzy
+	if scored && !hasQuery {
+		fmt.Fprintf(os.Stderr, "Error: relevance scores (-min-score, -sort score, -fuzzy) require -q\n")
+		os.Exit(1)
+	}
+	outOpts.scored = scored
+	if *weightName < 0 || *weightPath < 0 || *weightName+*weightPath == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -weight-name and -weight-path must not be negative or both zero\n")
+		os.Exit(1)
+	}
+
+	if *matchStem && !hasQuery {
+		fmt.Fprintf(os.Stderr, "Error: -stem requires -q\n")
+		os.Exit(1)
+	}
+
+	if *containsFlag {
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -contains requires -q\n")
+			os.Exit(1)
+		}
+		if *useRegex || *fuzzy {
+			fmt.Fprintf(os.Stderr, "Error: -contains cannot be combined with -regex or -fuzzy\n")
+			os.Exit(1)
+		}
+	}
+
+	if *follow && !hasQuery {
+		fmt.Fprintf(os.Stderr, "Error: -follow requires -q\n")
+		os.Exit(1)
+	}
+	if *followDepth < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -follow-depth must not be negative\n")
+		os.Exit(1)
+	}
+	if *followDepth > 0 && !*follow {
+		fmt.Fprintf(os.Stderr, "Error: -follow-depth requires -follow\n")
+		os.Exit(1)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
 
+	if *useRegex {
+		switch {
+		case !hasQuery:
+			fmt.Fprintf(os.Stderr, "Error: -regex requires -q\n")
+			os.Exit(1)
+		case *searchPath != "":
+			fmt.Fprintf(os.Stderr, "Error: -regex cannot be combined with -path, which takes a wildcard pattern\n")
+			os.Exit(1)
+		case *wholeWord:
+			fmt.Fprintf(os.Stderr, "Error: -regex cannot be combined with -whole; use \\b in the pattern\n")
+			os.Exit(1)
+		}
+	}
+	if *boolean {
+		switch {
+		case !hasQuery:
+			fmt.Fprintf(os.Stderr, "Error: -boolean requires -q\n")
+			os.Exit(1)
+		case *useRegex || *fuzzy:
+			fmt.Fprintf(os.Stderr, "Error: -boolean cannot be combined with -regex or -fuzzy\n")
+			os.Exit(1)
+		}
+	}
+	if *fuzzy {
+		switch {
+		case *useRegex:
+			fmt.Fprintf(os.Stderr, "Error: -fuzzy cannot be combined with -regex\n")
+			os.Exit(1)
+		case *searchPath != "":
+			fmt.Fprintf(os.Stderr, "Error: -fuzzy cannot be combined with -path\n")
+			os.Exit(1)
+		case *wholeWord:
+			fmt.Fprintf(os.Stderr, "Error: -fuzzy cannot be combined with -whole\n")
+			os.Exit(1)
+		}
+	}
+
+	if *andPath != "" {
+		switch {
+		case !hasQuery:
+			fmt.Fprintf(os.Stderr, "Error: -and-path requires -q\n")
+			os.Exit(1)
+		case *searchPath != "":
+			fmt.Fprintf(os.Stderr, "Error: -and-path cannot be combined with -path\n")
+			os.Exit(1)
+		}
+	}
+
+	if *segmentMatch && *searchPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: -segment-match requires -path\n")
+		os.Exit(1)
+	}
+	var pathMaxDepth *int
+	if *pathDepth >= 0 {
+		if *searchPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -depth requires -path\n")
+			os.Exit(1)
+		}
+		pathMaxDepth = pathDepth
+	}
+
+	// Validate sampling
+	if *sampleSize < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -sample must not be negative\n")
+		os.Exit(1)
+	}
+	stratifyVal := stratifyKey(strings.ToLower(*stratify))
+	if stratifyVal != stratifyNone {
+		if !oneOf(stratifyVal, stratifyKeys) {
+			fmt.Fprintf(os.Stderr, "Error: invalid stratify key %q. Must be: %s\n", *stratify, choiceList(stratifyKeys))
+			os.Exit(1)
+		}
+		if *sampleSize == 0 {
+			fmt.Fprintf(os.Stderr, "Error: -stratify requires -sample\n")
+			os.Exit(1)
+		}
+	}
+
+	// Validate deduplication
+	dedupeVal := dedupeNone
+	if *dedupe {
+		dedupeVal = dedupeName
+	}
+	if *dedupeBy != "" {
+		if !*dedupe {
+			fmt.Fprintf(os.Stderr, "Error: -dedupe-by requires -dedupe\n")
+			os.Exit(1)
+		}
+		dedupeVal = dedupeKey(strings.ToLower(*dedupeBy))
+		if !oneOf(dedupeVal, dedupeKeys) {
+			fmt.Fprintf(os.Stderr, "Error: invalid dedupe key %q. Must be: %s\n", *dedupeBy, choiceList(dedupeKeys))
+			os.Exit(1)
+		}
+	}
+
+	// Validate result selection; -first is -nth 1
+	nth := *nthFlag
+	if nth < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -nth must not be negative\n")
+		os.Exit(1)
+	}
+	if *first {
+		if nth > 0 {
+			fmt.Fprintf(os.Stderr, "Error: -first cannot be combined with -nth\n")
+			os.Exit(1)
+		}
+		nth = 1
+	}
+
+	if *maxDepth < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -maxdepth must not be negative\n")
+		os.Exit(1)
+	}
+	if *minDepth < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -mindepth must not be negative\n")
+		os.Exit(1)
+	}
+	if *minDepth > 0 {
+		if !hasQuery {
+			fmt.Fprintf(os.Stderr, "Error: -mindepth requires -q\n")
+			os.Exit(1)
+		}
+		if *maxDepth > 0 && *minDepth > *maxDepth {
+			fmt.Fprintf(os.Stderr, "Error: -mindepth must not exceed -maxdepth\n")
+			os.Exit(1)
+		}
+	}
+
+	if *sortDesc && *sortBy == "" {
+		fmt.Fprintf(os.Stderr, "Error: -desc (or -reverse) requires -sort\n")
+		os.Exit(1)
+	}
+
+	// A bare -exec runs the -open-cmd command; otherwise -exec has its own
+	execOpen := execCmd != nil && len(execCmd.argv) == 0
+	if execOpen && *openCmd == "" {
+		fmt.Fprintf(os.Stderr, "Error: -exec requires a command, e.g. -exec rm {} \\;\n")
+		os.Exit(1)
+	}
+	if execCmd != nil && !execOpen && *openCmd != "" {
+		fmt.Fprintf(os.Stderr, "Error: -exec with a command cannot be combined with -open-cmd\n")
+		os.Exit(1)
+	}
+	if *dryRun && (execCmd == nil || execOpen) {
+		fmt.Fprintf(os.Stderr, "Error: -dry-run requires -exec with a command\n")
+		os.Exit(1)
+	}
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -workers must be at least 1\n")
+		os.Exit(1)
+	}
+
+	if *pathCacheSize < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -path-cache-size must not be negative\n")
+		os.Exit(1)
+	}
+
+	// Validate retry options
+	if *retries < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -retry must not be negative\n")
+		os.Exit(1)
+	}
+	retryWait, err := parseDuration(*retryInterval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -retry-interval: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate search timeout
+	var searchTimeout time.Duration
+	if *timeout != "" {
+		searchTimeout, err = parseDuration(*timeout)
+		if err != nil || searchTimeout <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid -timeout %q: must be a positive duration\n", *timeout)
+			os.Exit(1)
+		}
+	}
+	if *partial && searchTimeout == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -partial requires -timeout\n")
+		os.Exit(1)
+	}
+
+	if *listDBs && !*auto {
+		fmt.Fprintf(os.Stderr, "Error: -list requires -auto\n")
+		os.Exit(1)
+	}
+	if len(autoDirs) > 0 && !*auto {
+		fmt.Fprintf(os.Stderr, "Error: -auto-dir requires -auto\n")
+		os.Exit(1)
+	}
+	dbPaths := splitDBPaths(dbFlags)
+	if *auto {
+		if len(dbPaths) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: -auto cannot be combined with -db\n")
+			os.Exit(1)
+		}
+		home, _ := os.UserHomeDir()
+		dirs := discoveryDirs(home, os.Getenv, autoDirs)
+		candidates := discoverDatabases(dirs)
+		if *listDBs {
+			printCandidates(os.Stdout, dirs, candidates)
+			return
+		}
+		best, ok := newestDatabase(candidates)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no valid fsearch database found in: %s\n", strings.Join(dirs, ", "))
+			os.Exit(1)
+		}
+		dbPaths = []string{best.Path}
+	}
+	if len(dbPaths) == 0 {
+		dbPaths = []string{defaultDBPath}
+	}
+
+	// Expand ~ in paths
+	for i, p := range dbPaths {
+		if !strings.HasPrefix(p, "~") {
+			continue
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to get home directory: %v\n", err)
+			os.Exit(1)
+		}
+		dbPaths[i] = filepath.Join(home, strings.TrimPrefix(p, "~"+string(filepath.Separator)))
+	}
+	dbPath := dbPaths[0]
+
+	// These read or describe one database file
+	if len(dbPaths) > 1 {
+		for _, single := range []struct {
+			name string
+			set  bool
+		}{
+			{"-db-info", *dbInfo},
+			{"-dump-entry", *dumpEntry >= 0},
+			{"-etag", *etag},
+			{"-index-stats", *indexStatsFlag},
+			{"-reindex-hint", *reindexHint},
+			{"-checkpoint", *checkpointPath != ""},
+		} {
+			if single.set {
+				fmt.Fprintf(os.Stderr, "Error: %s works on a single -db\n", single.name)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// The header banner reads only the first few dozen bytes, so it is
+	// handled before the full load
+	if *dbInfo {
+		meta, err := db.LoadMetadata(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printDBInfo(os.Stdout, meta)
+		return
+	}
+
+	// Dump a raw record if requested. This reads the file directly rather
+	// than loading it, so it still works on a database that fails to load.
+	if *dumpEntry >= 0 {
+		typ := db.EntryTypeFile
+		switch strings.ToLower(*dumpType) {
+		case "file":
+		case "folder":
+			typ = db.EntryTypeFolder
+		default:
+			fmt.Fprintf(os.Stderr, "Error: invalid -type %q. Must be: file or folder\n", *dumpType)
+			os.Exit(1)
+		}
+		rec, err := db.DumpRecord(dbPath, typ, uint32(*dumpEntry))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printRawRecord(os.Stdout, rec)
+		return
+	}
+
+	// The ETag is taken before loading: if the database changes in between,
+	// the output is newer than its tag and the next request sees a new tag,
+	// rather than stale output being cached under the current one
+	if *etag {
+		tag, err := computeETag(os.Args[1:], dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to compute ETag: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "ETag: %s\n", tag)
+	}
+
+	// Load databases. With several, one that fails to load is reported and
+	// skipped; the search goes ahead as long as any loaded.
+	var databases []*db.Database
+	for _, p := range dbPaths {
+		d, err := db.LoadWithRetry(p, *retries, retryWait)
+		if err == nil && d.UnknownVersion {
+			if *strict {
+				err = fmt.Errorf("unsupported minor version: newer than %d.%d (-strict)", db.MajorVersion, db.MinorVersion)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: %s uses a newer format than version %d.%d; loading what is understood\n", p, db.MajorVersion, db.MinorVersion)
+			}
+		}
+		if err != nil {
+			if len(dbPaths) == 1 {
+				fmt.Fprintf(os.Stderr, "Error: failed to load database: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: failed to load database %s: %v\n", p, err)
+			continue
+		}
+		timer.addLoad(d.Timings)
+		d.SetPathCacheSize(*pathCacheSize)
+		databases = append(databases, d)
+	}
+	if len(databases) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: none of the %d databases could be loaded\n", len(dbPaths))
+		os.Exit(1)
+	}
+	database := databases[0]
+	if len(databases) > 1 {
+		database = db.Merge(databases...)
+		database.SetPathCacheSize(*pathCacheSize)
+	}
+
+	// Answer queries from stdin against the loaded database if requested
+	if *serverMode {
+		if err := serve(os.Stdin, os.Stdout, database, *localeName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Show statistics if requested
+	if *showStats {
+		showDatabaseStats(os.Stdout, database)
+		if *depthStats {
+			showDepthStats(os.Stdout, database, database.Stats(), outOpts.style.units)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	// Report on the precomputed sort orders if requested
+	if *indexStatsFlag {
+		if err := showIndexStats(os.Stdout, database, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	// Audit the folder hierarchy if requested
+	if *unreachable {
+		broken := showUnreachable(os.Stdout, database)
+		timer.report(os.Stderr)
+		if broken > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check the database's structure if requested
+	if *verify {
+		problems := showVerify(os.Stdout, database)
+		timer.report(os.Stderr)
+		if problems > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check whether the index looks out of date if requested
+	if *reindexHint {
+		info, err := os.Stat(dbPath)
+		if err == nil {
+			err = showReindexHint(os.Stdout, database, info.ModTime())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	// Look up where an entry lives if requested
+	if *parentOf != "" {
+		if err := showParents(os.Stdout, database, *parentOf, *ancestors); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	// Find names that clash on case-insensitive filesystems if requested
+	if *caseCollisions {
+		groups := showCaseCollisions(os.Stdout, database)
+		timer.report(os.Stderr)
+		if groups > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Perform search. Listing names needs no query: without one, every
+	// entry is listed.
+	if !hasQuery && *searchPath == "" && format != outputFormatNamesSorted {
+		fmt.Fprintf(os.Stderr, "Error: must provide either -q (query) or -path (path search)\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Ctrl-C stops the search rather than the process, so it can be
+	// reported; once the search is over it interrupts as usual
+	ctx, stopInterrupt := signal.NotifyContext(context.Background(), os.Interrupt)
+	if searchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, searchTimeout)
+		defer cancel()
+	}
+
+	// -max stops a -q search at that many matches, which are then not the
+	// first ones in -sort order, nor all that -filter and -dedupe keep;
+	// such a search, like a -path search or a listing, collects every
+	// match and keeps the first -max once they are sorted, filtered, and
+	// deduplicated
+	searchMax, keepMax := *maxResults, 0
+	if !hasQuery || *searchPath != "" || *sortBy != "" || filter != nil || dedupeVal != dedupeNone {
+		searchMax, keepMax = 0, *maxResults
+	}
+
+	// Options for -q searches
+	nameOpts := db.SearchOptions{
+		Query:            query,
+		CaseSensitive:    *caseSensitive,
+		MatchWholeWord:   *wholeWord,
+		MatchStem:        *matchStem,
+		Contains:         *containsFlag,
+		PathPattern:      *andPath,
+		UseRegex:         *useRegex,
+		Fuzzy:            *fuzzy,
+		Boolean:          *boolean,
+		Fold:             loc.fold,
+		FoldAccents:      *foldAccents,
+		NoNormalize:      *noNormalize,
+		SearchInFiles:    !*foldersOnly,
+		SearchInFolders:  !*filesOnly,
+		MaxResults:       searchMax,
+		MaxPerExtension:  *maxPerExt,
+		MinDepth:         *minDepth,
+		MaxDepth:         *maxDepth,
+		MaxFiles:         *limitFiles,
+		MaxFolders:       *limitFolders,
+		ExactSize:        sizeFilter,
+		MinSize:          minSize,
+		MaxSize:          maxSize,
+		MTimeAfter:       mtimeAfter,
+		MTimeBefore:      mtimeBefore,
+		ExcludePatterns:  excludes,
+		ExcludeMatchPath: *excludePath,
+		Score:            scored,
+		MinScore:         *minScore,
+		Follow:           *follow,
+		FollowDepth:      *followDepth,
+		MatchPath:        *matchPath,
+		NameWeight:       *weightName,
+		PathWeight:       *weightPath,
+		// Colored text highlights the ranges too, where the query itself
+		// cannot be
+		MatchRanges: *matchRange || outOpts.style.color,
+	}
+
+	if len(queries) <= 1 {
+		outOpts.style.highlight, outOpts.style.caseSensitive = highlightQuery(nameOpts), *caseSensitive
+	}
+
+	// Answer queries typed on stdin, one search per line, if requested
+	if *interactive {
+		stopInterrupt()
+		session := &interactiveSession{
+			database:  database,
+			opts:      nameOpts,
+			timeout:   searchTimeout,
+			filter:    filter,
+			sortField: sortFieldVal,
+			desc:      *sortDesc,
+			coll:      loc.coll,
+			dedupe:    dedupeVal,
+			max:       keepMax,
+			nth:       nth,
+			format:    format,
+			outOpts:   outOpts,
+		}
+		if err := session.run(os.Stdin, os.Stdout, isTerminal(os.Stdin)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Each database is searched on its own, so -max-per-db can cap each
+	// one's share of the merged results
+	searchOne := func(d *db.Database) (*db.SearchResult, error) {
+		var result *db.SearchResult
+		var searchErr error
+		switch {
+		case query == "" && *searchPath == "":
+			result = allEntries(d, !*foldersOnly, !*filesOnly)
+		case *searchPath != "":
+			result, searchErr = d.SearchPathContext(ctx, db.PathSearchOptions{
+				Pattern:       *searchPath,
+				CaseSensitive: *caseSensitive,
+				SegmentMatch:  *segmentMatch,
+				Fold:          loc.fold,
+				FoldAccents:   *foldAccents,
+				NoNormalize:   *noNormalize,
+				MaxDepth:      pathMaxDepth,
+				MaxRootDepth:  *maxDepth,
+			})
+		default:
+			opts := nameOpts
+			if !*mergeSortedFlag {
+				result, searchErr = d.SearchContext(ctx, opts)
+				break
+			}
+			// Each query is searched on its own
+			results := make([]*db.SearchResult, 0, len(queries))
+			for _, q := range queries {
+				opts.Query = q
+				r, err := d.SearchContext(ctx, opts)
+				results = append(results, r)
+				if err != nil {
+					searchErr = err
+					break
+				}
+			}
+			result = mergeSorted(results, sortFieldVal, *sortDesc, loc.coll)
+		}
+		return result, searchErr
+	}
+
+	// -quiet only asks whether anything matches, and unless -filter may
+	// drop matches the search can stop at the first one
+	if *quiet && filter == nil && query != "" && *searchPath == "" {
+		qs := []string{query}
+		if *mergeSortedFlag {
+			qs = queries
+		}
+		found := false
+		var searchErr error
+	quietSearch:
+		for _, d := range databases {
+			for _, q := range qs {
+				opts := nameOpts
+				opts.Query = q
+				searchErr = d.SearchStreamContext(ctx, opts, func(db.Match) bool {
+					found = true
+					return false
+				})
+				if found || searchErr != nil {
+					break quietSearch
+				}
+			}
+		}
+		stopInterrupt()
+		switch {
+		case found:
+			os.Exit(0)
+		case errors.Is(searchErr, context.Canceled):
+			fmt.Fprintf(os.Stderr, "Error: search interrupted\n")
+			os.Exit(130)
+		case errors.Is(searchErr, context.DeadlineExceeded):
+			fmt.Fprintf(os.Stderr, "Error: search timed out after %s\n", searchTimeout)
+		case searchErr != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", searchErr)
+		}
+		os.Exit(1)
+	}
+
+	// A -q search whose results need no further processing is printed as
+	// it runs in the formats that write one record per result, so memory
+	// does not grow with the number of matches. The search yields the
+	// folders first for -group dirs-first. -max then already bounds memory,
+	// and keeps the files first when the search applies it, so only
+	// dirs-last or a single kind of entry streams with it. Text, whose
+	// lines wait in a spool file for the count that heads them, streams
+	// unless -compact-paths or -flag-dupes need every result first.
+	stream := oneOf(format, streamFormats) && len(databases) == 1 && query != "" && *searchPath == "" &&
+		!*mergeSortedFlag && filter == nil && dedupeVal == dedupeNone && *maxPerDB == 0 && *sampleSize == 0 && *sortBy == "" &&
+		nth == 0 && !*duplicates && !*quiet && (searchMax == 0 || outOpts.order.mode == groupDirsLast || *filesOnly || *foldersOnly) &&
+		!outOpts.compactPaths && !outOpts.flagDupes && execCmd == nil && *openCmd == "" && *checkpointPath == "" && !*metaFooter &&
+		(searchTimeout == 0 || *partial)
+	if stream {
+		out, closeOut, err := openOutput(*outputPath, *gzipOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		bw := bufio.NewWriter(out)
+		mw := &matchWriter{w: bw, database: database, format: format, opts: outOpts}
+		streamOpts := nameOpts
+		streamOpts.FoldersFirst = outOpts.order.mode == groupDirsFirst
+		searchStart := time.Now()
+		var writeErr error
+		searchErr := database.SearchStreamContext(ctx, streamOpts, func(m db.Match) bool {
+			writeErr = mw.write(m)
+			return writeErr == nil
+		})
+		stopInterrupt()
+		timer.since("search", searchStart)
+		// An invalid query is reported with no output at all
+		if searchErr == nil || errors.Is(searchErr, context.Canceled) || errors.Is(searchErr, context.DeadlineExceeded) {
+			if closeErr := mw.close(); writeErr == nil {
+				writeErr = closeErr
+			}
+		}
+		err = bw.Flush()
+		if writeErr != nil {
+			err = writeErr
+		}
+		if closeErr := closeOut(); err == nil {
+			err = closeErr
+		}
+		switch {
+		case errors.Is(searchErr, context.Canceled):
+			// The matches found so far are already written
+			fmt.Fprintf(os.Stderr, "Warning: search interrupted; results are partial\n")
+			os.Exit(130)
+		case errors.Is(searchErr, context.DeadlineExceeded):
+			fmt.Fprintf(os.Stderr, "Warning: search timed out after %s; results are partial\n", searchTimeout)
+		case searchErr != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", searchErr)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write output file: %v\n", err)
+			os.Exit(1)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	searchStart := time.Now()
+	var result *db.SearchResult
+	var searchErr error
+	if len(databases) == 1 {
+		result, searchErr = searchOne(database)
+	} else {
+		results := make([]*db.SearchResult, 0, len(databases))
+		for _, d := range databases {
+			r, err := searchOne(d)
+			results = append(results, r)
+			if err != nil {
+				searchErr = err
+				break
+			}
+		}
+		result = db.MergeResults(results, *maxPerDB, searchMax)
+	}
+	stopInterrupt()
+	timer.since("search", searchStart)
+
+	// An interrupted search prints the matches found before Ctrl-C and
+	// exits with status 130 once they are written, unless they were to be
+	// acted on, which would act on only some of them
+	interrupted := errors.Is(searchErr, context.Canceled)
+	if interrupted {
+		if execCmd != nil || *openCmd != "" || *checkpointPath != "" || *quiet {
+			fmt.Fprintf(os.Stderr, "Error: search interrupted\n")
+			os.Exit(130)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: search interrupted; results are partial\n")
+		searchErr = nil
+	}
+
+	// A timed-out search has only seen part of the database. Its matches
+	// are used only when asked for, and are then marked truncated.
+	if searchErr != nil && !errors.Is(searchErr, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", searchErr)
+		os.Exit(1)
+	}
+	if searchErr != nil {
+		if !*partial {
+			fmt.Fprintf(os.Stderr, "Error: search timed out after %s (use -partial to print the matches found so far)\n", searchTimeout)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: search timed out after %s; results are partial\n", searchTimeout)
+	}
+
+	if filter != nil {
+		filterResults(result, filter)
+	}
+
+	if *quiet {
+		if len(result.Files)+len(result.Folders) > 0 {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	// Cap each database's contribution if requested; several databases
+	// were already capped as they were merged
+	if *maxPerDB > 0 && len(databases) == 1 {
+		result = db.MergeResults([]*db.SearchResult{result}, *maxPerDB, searchMax)
+	}
+
+	// Reduce to a random sample if requested
+	if *sampleSize > 0 {
+		rngSeed := *seed
+		if rngSeed == 0 {
+			rngSeed = time.Now().UnixNano()
+		}
+		sampleResults(result, *sampleSize, stratifyVal, rand.New(rand.NewSource(rngSeed)))
+	}
+
+	// Sort results if requested; results merged by -merge-sorted are
+	// already in order unless they came from several databases
+	if *sortBy != "" && (!*mergeSortedFlag || len(databases) > 1) {
+		sortStart := time.Now()
+		sortResultsIndexed(database, result, sortFieldVal, *sortDesc, loc.coll)
+		timer.since("sort", sortStart)
+	}
+
+	if dedupeVal != dedupeNone {
+		dedupeResults(result, dedupeVal)
+	}
+
+	if outOpts.order.mode == groupMixed {
+		outOpts.order.less, _ = sortLess(result, sortFieldVal, *sortDesc, loc.coll)
+	}
+
+	if keepMax > 0 || nth > 0 {
+		keepSizedFiles(result, sortFieldVal)
+	}
+	if keepMax > 0 {
+		truncateResults(result, keepMax, outOpts.order)
+	}
+
+	if nth > 0 {
+		keepNth(result, nth, outOpts.order)
+	}
+
+	// Run a command on the results instead of listing them
+	if execCmd != nil && !execOpen {
+		var paths []string
+		for _, entry := range collectEntries(result, outOpts) {
+			paths = append(paths, entry.Path)
+		}
+		if len(paths) == 0 {
+			fmt.Fprintf(os.Stderr, "No results; nothing to run.\n")
+			timer.report(os.Stderr)
+			return
+		}
+		if *dryRun {
+			printCommands(os.Stdout, execCmd.commands(paths))
+			timer.report(os.Stderr)
+			return
+		}
+		execStart := time.Now()
+		failed := runCommands(execCmd.commands(paths), *workers, os.Stdout, os.Stderr)
+		timer.since("exec", execStart)
+		timer.report(os.Stderr)
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Open the first match with a command instead of listing results
+	if *openCmd != "" {
+		entries := collectEntries(result, outOpts)
+		if len(entries) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no results to open\n")
+			os.Exit(1)
+		}
+		argv, err := openCommand(*openCmd, entries[0].Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -open-cmd: %v\n", err)
+			os.Exit(1)
+		}
+		if !execOpen {
+			fmt.Println(shellJoin(argv))
+			timer.report(os.Stderr)
+			return
+		}
+		if err := runCommand(argv, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", argv[0], err)
+			os.Exit(1)
+		}
+		timer.report(os.Stderr)
+		return
+	}
+
+	outputStart := time.Now()
+
+	// Resumable export: skip what a previous run already wrote
+	if *checkpointPath != "" {
+		if err := runCheckpointedExport(*outputPath, *checkpointPath, dbPath, result, format, outOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: export failed: %v\n", err)
+			os.Exit(1)
+		}
+		timer.since("output", outputStart)
+		timer.report(os.Stderr)
+		return
+	}
+
+	// Print results in requested format
+	out, closeOut, err := openOutput(*outputPath, *gzipOutput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create output file: %v\n", err)
+		os.Exit(1)
+	}
+	if format == outputFormatSunburst {
+		err = printSunburst(out, database, result, *maxDepth)
+	} else if *duplicates {
+		if database.IndexFlags&db.IndexFlagSize == 0 {
+			fmt.Fprintf(os.Stderr, "Error: -duplicates needs a database that indexes file sizes\n")
+			os.Exit(1)
+		}
+		showDuplicates(out, result, outOpts)
+	} else {
+		printResults(out, result, format, outOpts)
+		if *metaFooter {
+			printMetaFooter(out, resultMeta{
+				Count:     len(result.Files) + len(result.Folders),
+				Truncated: result.Truncated,
+				ElapsedMS: time.Since(start).Milliseconds(),
+			})
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := closeOut(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output file: %v\n", err)
+		os.Exit(1)
+	}
+	timer.since("output", outputStart)
+	timer.report(os.Stderr)
+	if interrupted {
+		os.Exit(130)
+	}
+}
+
+// runCheckpointedExport writes the results to outputPath, resuming from the
+// checkpoint file if one was left behind by an interrupted run with the same
+// arguments. The checkpoint is removed once the export completes.
+func runCheckpointedExport(outputPath, checkpointPath, dbPath string, result *db.SearchResult, format outputFormat, opts outputOptions) error {
+	key, err := checkpointKey(os.Args[1:], dbPath)
+	if err != nil {
+		return err
+	}
+	cp, err := loadCheckpoint(checkpointPath, key)
+	if err != nil {
+		return err
+	}
+	if cp.Written > 0 {
+		fmt.Fprintf(os.Stderr, "Resuming export after %d record(s)\n", cp.Written)
+	}
+
+	f, err := openCheckpointOutput(outputPath, cp)
+	if err != nil {
+		return err
+	}
+	if err := exportCheckpointed(f, collectEntries(result, opts), format, opts, cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(checkpointPath)
+}
+
+func showDatabaseStats(w io.Writer, database *db.Database) {
+	fmt.Fprintf(w, "Database Statistics:\n")
+	fmt.Fprintf(w, "  Folders: %d\n", len(database.Folders))
+	fmt.Fprintf(w, "  Files: %d\n", len(database.Files))
+	fmt.Fprintf(w, "  Total entries: %d\n", len(database.Folders)+len(database.Files))
+	fmt.Fprintf(w, "  Index flags: %d\n", database.IndexFlags)
+	fmt.Fprintf(w, "  Sorted arrays: %d\n", len(database.SortedArrays))
+}
+
+// depthBarWidth is the length of the longest bar in the -depth-stats
+// histogram
+const depthBarWidth = 40
+
+// showDepthStats prints the -depth-stats part of -stats: a histogram of
+// entries per folder depth and the largest files
+func showDepthStats(w io.Writer, database *db.Database, stats db.Stats, units sizeUnits) {
+	fmt.Fprintf(w, "\nEntries by depth:\n")
+	most := 0
+	for _, n := range stats.Depths {
+		most = max(most, n)
+	}
+	for depth, n := range stats.Depths {
+		bar := 0
+		if most > 0 {
+			bar = (n*depthBarWidth + most - 1) / most
+		}
+		fmt.Fprintf(w, "  %3d  %8d  %s\n", depth, n, strings.Repeat("#", bar))
+	}
+
+	fmt.Fprintf(w, "\nLargest files:\n")
+	if len(stats.Largest) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	for _, file := range stats.Largest {
+		fmt.Fprintf(w, "  %10s  %s\n", formatSize(file.Size, units), database.FullPath(file))
+	}
+}
+
+// showUnreachable prints entries whose parent chain is broken and returns
+// how many were found
+func showUnreachable(w io.Writer, database *db.Database) int {
+	broken := database.Unreachable()
+	if len(broken) == 0 {
+		fmt.Fprintln(w, "No unreachable entries found.")
+		return 0
+	}
+
+	fmt.Fprintf(w, "Found %d unreachable entries:\n\n", len(broken))
+	for _, u := range broken {
+		icon := "📄"
+		if u.Entry.Type == db.EntryTypeFolder {
+			icon = "📁"
+		}
+		fmt.Fprintf(w, "%s %s (%s)\n", icon, u.PartialPath, u.Reason)
+	}
+	return len(broken)
+}
+
+// showVerify prints each structural problem Verify finds and returns how
+// many there were
+func showVerify(w io.Writer, database *db.Database) int {
+	problems := database.Verify()
+	if len(problems) == 0 {
+		fmt.Fprintln(w, "No problems found.")
+		return 0
+	}
+
+	fmt.Fprintf(w, "Found %d problem(s):\n", len(problems))
+	for _, err := range problems {
+		fmt.Fprintf(w, "  %v\n", err)
+	}
+	return len(problems)
+}
+
+// showCaseCollisions prints groups of siblings whose names differ only by
+// case and returns how many groups were found
+func showCaseCollisions(w io.Writer, database *db.Database) int {
+	groups := database.CaseCollisions()
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "No case collisions found.")
+		return 0
+	}
+
+	fmt.Fprintf(w, "Found %d case collision group(s):\n", len(groups))
+	for _, group := range groups {
+		fmt.Fprintln(w)
+		for _, e := range group {
+			icon := "📄"
+			if e.Type == db.EntryTypeFolder {
+				icon = "📁"
+			}
+			fmt.Fprintf(w, "%s %s\n", icon, database.FullPath(e))
+		}
+	}
+	return len(groups)
+}
+
+// allEntries returns every file and/or folder in the database as a result,
+// for listings that need no query
+func allEntries(database *db.Database, files, folders bool) *db.SearchResult {
+	result := &db.SearchResult{}
+	if files {
+		result.Files = append(result.Files, database.Files...)
+	}
+	if folders {
+		result.Folders = append(result.Folders, database.Folders...)
+	}
+	return result
+}
+
+// showReindexHint compares the files' modification times with indexed, the
+// time the database was written, and recommends re-indexing if any file is
+// newer
+func showReindexHint(w io.Writer, database *db.Database, indexed time.Time) error {
+	s := database.StalenessSince(indexed)
+	if !s.Indexed {
+		return fmt.Errorf("the database does not record modification times")
+	}
+
+	fmt.Fprintf(w, "Index written: %s\n", indexed.Format(time.RFC3339))
+	if !s.Newest.IsZero() {
+		fmt.Fprintf(w, "Newest file:   %s\n", s.Newest.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Files modified after the index was written: %d of %d\n", s.Newer, len(database.Files))
+	if s.Newer > 0 {
+		fmt.Fprintln(w, "The index looks out of date; re-run fsearch to update it.")
+	} else {
+		fmt.Fprintln(w, "The index looks up to date.")
+	}
+	return nil
+}
+
+// sizeUnits selects how text output writes sizes
+type sizeUnits string
+
+const (
+	unitsBinary sizeUnits = "binary" // powers of 1024: KiB, MiB, ...
+	unitsSI     sizeUnits = "si"     // powers of 1000: KB, MB, ...
+	unitsBytes  sizeUnits = "bytes"  // the exact count
+)
+
+// sizeUnitModes lists the accepted -units values
+var sizeUnitModes = []sizeUnits{unitsBinary, unitsSI, unitsBytes}
+
+// formatSize writes a size in units, e.g. "1.5 MiB" or "1.5 MB". Sizes
+// under one unit, and every size in unitsBytes, are written in bytes.
+func formatSize(bytes int64, units sizeUnits) string {
+	unit, suffix := int64(1024), "iB"
+	switch units {
+	case unitsSI:
+		unit, suffix = 1000, "B"
+	case unitsBytes:
+		return fmt.Sprintf("%d B", bytes)
+	}
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %c%s", float64(bytes)/float64(div), "KMGTPE"[exp], suffix)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/gsearch-cli/internal/db"
+	"golang.org/x/text/collate"
+)
+
+// mergeSorted combines the results of several queries into one listing
+// ordered by field, with each entry listed once however many queries
+// matched it. Each result is sorted on its own and the sorted runs are then
+// merged, so the combined set is never sorted as a whole. An entry scored
+// by more than one query keeps its highest score.
+func mergeSorted(results []*db.SearchResult, field sortField, desc bool, coll *collate.Collator) *db.SearchResult {
+	merged := &db.SearchResult{
+		Files:   make([]*db.Entry, 0),
+		Folders: make([]*db.Folder, 0),
+	}
+
+	// The orderings need keys, such as scores and collation keys, for
+	// every entry they will compare, so they are built over the union
+	var files [][]*db.Entry
+	var folders [][]*db.Folder
+	union := &db.SearchResult{}
+	for _, r := range results {
+		union.Files = append(union.Files, r.Files...)
+		union.Folders = append(union.Folders, r.Folders...)
+		files = append(files, r.Files)
+		folders = append(folders, r.Folders)
+		merged.Truncated = merged.Truncated || r.Truncated
+		for e, s := range r.Scores {
+			if merged.Scores == nil {
+				merged.Scores = make(map[*db.Entry]float64)
+			}
+			if s > merged.Scores[e] {
+				merged.Scores[e] = s
+			}
+		}
+	}
+	union.Scores = merged.Scores
+
+	fileLess, folderLess := sortLess(union, field, desc, coll)
+	if fileLess == nil {
+		// No ordering: keep the first occurrence of each entry, query by query
+		fileLess = func(a, b *db.Entry) bool { return false }
+		folderLess = fileLess
+	}
+	for _, run := range files {
+		sort.SliceStable(run, func(i, j int) bool { return fileLess(run[i], run[j]) })
+	}
+	for _, run := range folders {
+		sort.SliceStable(run, func(i, j int) bool { return folderLess(&run[i].Entry, &run[j].Entry) })
+	}
+
+	merged.Files = mergeRuns(files, fileLess)
+	merged.Folders = mergeRuns(folders, func(a, b *db.Folder) bool { return folderLess(&a.Entry, &b.Entry) })
+	return merged
+}
+
+// mergeRuns merges sorted runs into one sorted slice, dropping repeats of
+// an item already taken. Ties go to the earlier run.
+func mergeRuns[T comparable](runs [][]T, less func(a, b T) bool) []T {
+	h := &runHeap[T]{less: less}
+	total := 0
+	for i, run := range runs {
+		total += len(run)
+		if len(run) > 0 {
+			h.cursors = append(h.cursors, runCursor[T]{run: run, index: i})
+		}
+	}
+	heap.Init(h)
+
+	out := make([]T, 0, total)
+	seen := make(map[T]bool, total)
+	for h.Len() > 0 {
+		c := &h.cursors[0]
+		item := c.run[0]
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+		if c.run = c.run[1:]; len(c.run) == 0 {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return out
+}
+
+// runCursor is the unmerged remainder of one sorted run
+type runCursor[T any] struct {
+	run   []T
+	index int // position of the run, for stable tie-breaking
+}
+
+// runHeap orders run cursors by their next item
+type runHeap[T any] struct {
+	cursors []runCursor[T]
+	less    func(a, b T) bool
+}
+
+func (h *runHeap[T]) Len() int { return len(h.cursors) }
+
+func (h *runHeap[T]) Less(i, j int) bool {
+	a, b := h.cursors[i], h.cursors[j]
+	if h.less(a.run[0], b.run[0]) {
+		return true
+	}
+	if h.less(b.run[0], a.run[0]) {
+		return false
+	}
+	return a.index < b.index
+}
+
+func (h *runHeap[T]) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *runHeap[T]) Push(x any) { h.cursors = append(h.cursors, x.(runCursor[T])) }
+
+func (h *runHeap[T]) Pop() any {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestMergeSorted(t *testing.T) {
+	database := loadTestDatabase(t)
+	search := func(q string) *db.SearchResult {
+		return mustSearch(t, database, db.SearchOptions{Query: q, SearchInFiles: true})
+	}
+	paths := func(r *db.SearchResult) string {
+		var out []string
+		for _, e := range collectEntries(r, outputOptions{}) {
+			out = append(out, e.Path)
+		}
+		return strings.Join(out, ",")
+	}
+
+	// test.txt matches both queries and is listed once
+	merged := mergeSorted([]*db.SearchResult{search("test"), search("*.txt")}, sortFieldSize, false, nil)
+	want := "/home/user/test.txt,/home/user/readme.txt,/Documents/test.go"
+	if got := paths(merged); got != want {
+		t.Errorf("Merged by size:\n got %s\nwant %s", got, want)
+	}
+
+	merged = mergeSorted([]*db.SearchResult{search("test"), search("*.txt")}, sortFieldPath, true, nil)
+	want = "/home/user/test.txt,/home/user/readme.txt,/Documents/test.go"
+	if got := paths(merged); got != want {
+		t.Errorf("Merged by path, descending:\n got %s\nwant %s", got, want)
+	}
+
+	// Scores from either query count; the better one wins
+	scored := func(q string) *db.SearchResult {
+		return mustSearch(t, database, db.SearchOptions{Query: q, SearchInFiles: true, Score: true})
+	}
+	merged = mergeSorted([]*db.SearchResult{scored("test.txt"), scored("txt")}, sortFieldScore, false, nil)
+	if merged.Files[0].Name != "test.txt" || merged.Scores[merged.Files[0]] != 1 {
+		t.Errorf("Expected the exact match first with score 1, got %s (%v)", paths(merged), merged.Scores)
+	}
+}
+
+func TestMergeRuns(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	got := mergeRuns([][]int{{1, 4, 9}, {2, 4, 5}, nil, {0, 9, 10}}, less)
+	want := []int{0, 1, 2, 4, 5, 9, 10}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
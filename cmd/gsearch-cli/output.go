@@ -1,185 +1,1114 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/gsearch-cli/internal/db"
+	"golang.org/x/text/collate"
 )
 
 type resultEntry struct {
-	Name    string `json:"name"`
-	Path    string `json:"path"`
-	Type    string `json:"type"` // "file" or "folder"
-	Size    int64  `json:"size,omitempty"`
-	MTime   string `json:"mtime,omitempty"`
-	MTimeTS int64  `json:"mtime_ts,omitempty"`
+	Name string `json:"name" xml:"name"`
+	Path string `json:"path" xml:"path"`
+	Type string `json:"type" xml:"type,attr"` // "file" or "folder"
+	Size int64  `json:"size,omitempty" xml:"size,omitempty"`
+	// NumFiles and NumFolders count a folder's direct children; nil for
+	// files
+	NumFiles   *uint32 `json:"num_files,omitempty" xml:"num_files,omitempty"`
+	NumFolders *uint32 `json:"num_folders,omitempty" xml:"num_folders,omitempty"`
+	MTime      string  `json:"mtime,omitempty" xml:"mtime,omitempty"`
+	MTimeTS    int64   `json:"mtime_ts,omitempty" xml:"mtime_ts,omitempty"`
+	Score      float64 `json:"score,omitempty" xml:"score,omitempty"` // relevance in (0, 1], set only when scored
+
+	// Set only when the database indexes them
+	ATime            string `json:"atime,omitempty" xml:"atime,omitempty"`
+	CTime            string `json:"ctime,omitempty" xml:"ctime,omitempty"`
+	StatusChangeTime string `json:"status_change_time,omitempty" xml:"status_change_time,omitempty"`
+
+	// Index is the entry's position among the files or the folders of its
+	// database, and DBIndex a folder's FSearch db_index; both are set only
+	// with -show-index
+	Index   *uint32 `json:"index,omitempty" xml:"-"`
+	DBIndex *uint32 `json:"db_index,omitempty" xml:"-"`
+
+	// MatchStart and MatchEnd are the byte range of Name the query
+	// matched, set with -match-range where the match can be placed
+	MatchStart *int `json:"match_start,omitempty" xml:"-"`
+	MatchEnd   *int `json:"match_end,omitempty" xml:"-"`
+}
+
+// entryLess reports whether entry a should sort before entry b
+type entryLess func(a, b *db.Entry) bool
+
+// sortResults sorts the search results by the specified field.
+// If desc is true the ordering is reversed. Names and paths are ordered by
+// coll, or byte by byte if it is nil.
+func sortResults(result *db.SearchResult, field sortField, desc bool, coll *collate.Collator) {
+	fileLess, folderLess := sortLess(result, field, desc, coll)
+	if fileLess == nil {
+		return
+	}
+
+	sort.SliceStable(result.Files, func(i, j int) bool {
+		return fileLess(result.Files[i], result.Files[j])
+	})
+	sort.SliceStable(result.Folders, func(i, j int) bool {
+		return folderLess(&result.Folders[i].Entry, &result.Folders[j].Entry)
+	})
 }
 
-// sortResults sorts the search results by the specified field
-func sortResults(result *db.SearchResult, field sortField) {
+// sortLess returns the file and folder orderings sortResults uses for
+// field, or nils for an unknown field. Any per-entry keys are computed up
+// front for the entries of result, so the comparisons apply only to them.
+func sortLess(result *db.SearchResult, field sortField, desc bool, coll *collate.Collator) (fileLess, folderLess entryLess) {
+	byName := func(a, b *db.Entry) bool { return naturalLess(a.Name, b.Name) }
+	if coll != nil {
+		byName = collatedLess(result, coll, func(e *db.Entry) string { return e.Name })
+	}
+
 	switch field {
 	case sortFieldName:
-		sort.Slice(result.Files, func(i, j int) bool {
-			return result.Files[i].Name < result.Files[j].Name
-		})
-		sort.Slice(result.Folders, func(i, j int) bool {
-			return result.Folders[i].Name < result.Folders[j].Name
-		})
+		fileLess, folderLess = byName, byName
 	case sortFieldPath:
-		sort.Slice(result.Files, func(i, j int) bool {
-			return result.Files[i].GetFullPath() < result.Files[j].GetFullPath()
-		})
-		sort.Slice(result.Folders, func(i, j int) bool {
-			return result.Folders[i].GetFullPath() < result.Folders[j].GetFullPath()
-		})
+		byPath := func(a, b *db.Entry) bool { return naturalLess(result.FullPath(a), result.FullPath(b)) }
+		if coll != nil {
+			byPath = collatedLess(result, coll, result.FullPath)
+		}
+		fileLess, folderLess = byPath, byPath
 	case sortFieldSize:
-		sort.Slice(result.Files, func(i, j int) bool {
-			return result.Files[i].Size < result.Files[j].Size
-		})
+		fileLess = func(a, b *db.Entry) bool { return a.Size < b.Size }
 		// Folders don't have meaningful size for sorting
-		sort.Slice(result.Folders, func(i, j int) bool {
-			return result.Folders[i].Name < result.Folders[j].Name
-		})
+		folderLess = byName
 	case sortFieldMTime:
-		sort.Slice(result.Files, func(i, j int) bool {
-			return result.Files[i].MTime.Before(result.Files[j].MTime)
-		})
-		sort.Slice(result.Folders, func(i, j int) bool {
-			return result.Folders[i].MTime.Before(result.Folders[j].MTime)
-		})
+		byMTime := func(a, b *db.Entry) bool { return a.MTime.Before(b.MTime) }
+		fileLess, folderLess = byMTime, byMTime
+	case sortFieldPathLen:
+		// Compute each path once; the comparator runs O(n log n) times
+		paths := make(map[*db.Entry]string, len(result.Files)+len(result.Folders))
+		for _, file := range result.Files {
+			paths[file] = result.FullPath(file)
+		}
+		for _, folder := range result.Folders {
+			paths[&folder.Entry] = result.FullPath(&folder.Entry)
+		}
+		byPathLen := func(a, b *db.Entry) bool {
+			pa, pb := paths[a], paths[b]
+			if len(pa) != len(pb) {
+				return len(pa) < len(pb)
+			}
+			return pa < pb
+		}
+		fileLess, folderLess = byPathLen, byPathLen
+	case sortFieldScore:
+		// Highest score first, so -desc puts the weakest matches first
+		byScore := func(a, b *db.Entry) bool {
+			sa, sb := result.Scores[a], result.Scores[b]
+			if sa != sb {
+				return sa > sb
+			}
+			return a.Name < b.Name
+		}
+		fileLess, folderLess = byScore, byScore
+	case sortFieldExt:
+		// Extensions ignore case, as with -max-per-ext; files without one
+		// come first. Folders are sorted by name, as for size.
+		fileLess = func(a, b *db.Entry) bool {
+			ea, eb := strings.ToLower(db.Extension(a.Name)), strings.ToLower(db.Extension(b.Name))
+			if ea != eb {
+				return ea < eb
+			}
+			return byName(a, b)
+		}
+		folderLess = byName
+	default:
+		return nil, nil
+	}
+
+	if desc {
+		fileLess, folderLess = reversed(fileLess), reversed(folderLess)
 	}
+	return fileLess, folderLess
+}
+
+// naturalLess orders a before b as people number things: runs of ASCII
+// digits compare by value, so img2.png precedes img10.png, and everything
+// else byte by byte. Numbers of equal value with more leading zeros sort
+// after the shorter ones, so the order stays total.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		la, lb := digitPrefix(a), digitPrefix(b)
+		if la == 0 || lb == 0 {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		na, nb := strings.TrimLeft(a[:la], "0"), strings.TrimLeft(b[:lb], "0")
+		if len(na) != len(nb) {
+			return len(na) < len(nb)
+		}
+		if na != nb {
+			return na < nb
+		}
+		if la != lb {
+			return la < lb
+		}
+		a, b = a[la:], b[lb:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix returns the number of ASCII digits s starts with
+func digitPrefix(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// collatedLess orders entries by the collation keys of text(entry), which
+// are computed once per entry rather than on every comparison
+func collatedLess(result *db.SearchResult, coll *collate.Collator, text func(*db.Entry) string) entryLess {
+	var buf collate.Buffer
+	keys := make(map[*db.Entry][]byte, len(result.Files)+len(result.Folders))
+	for _, file := range result.Files {
+		keys[file] = coll.KeyFromString(&buf, text(file))
+	}
+	for _, folder := range result.Folders {
+		keys[&folder.Entry] = coll.KeyFromString(&buf, text(&folder.Entry))
+	}
+	return func(a, b *db.Entry) bool {
+		return bytes.Compare(keys[a], keys[b]) < 0
+	}
+}
+
+// reversed returns a comparison that orders entries opposite to less
+func reversed(less entryLess) entryLess {
+	return func(a, b *db.Entry) bool {
+		return less(b, a)
+	}
+}
+
+// timeFormat selects which modification time representations are output
+type timeFormat string
+
+const (
+	timeFormatBoth    timeFormat = "both"    // RFC3339 string and Unix timestamp
+	timeFormatUnix    timeFormat = "unix"    // Unix timestamp only
+	timeFormatRFC3339 timeFormat = "rfc3339" // RFC3339 string only
+)
+
+// timeFormats lists the accepted -time-as values
+var timeFormats = []timeFormat{timeFormatUnix, timeFormatRFC3339, timeFormatBoth}
+
+// groupMode selects where output places folders relative to files
+type groupMode string
+
+const (
+	groupDirsFirst groupMode = "dirs-first" // all folders, then all files
+	groupDirsLast  groupMode = "dirs-last"  // all files, then all folders
+	groupMixed     groupMode = "mixed"      // one list in -sort order
+)
+
+// groupModes lists the accepted -group values
+var groupModes = []groupMode{groupDirsFirst, groupDirsLast, groupMixed}
+
+// entryOrder is how output lists the entries of a result: grouped as mode
+// says, and for groupMixed interleaved in the order less gives. The zero
+// value lists folders first.
+type entryOrder struct {
+	mode groupMode
+	less entryLess
+}
+
+// outputOptions holds settings that affect how results are rendered
+type outputOptions struct {
+	// order places folders and files in the output
+	order entryOrder
+
+	// timeAs selects the mtime fields in JSON and CSV. When empty, JSON
+	// carries both and CSV only the RFC3339 column, as before -time-as.
+	timeAs timeFormat
+
+	// scored adds the relevance score to JSON and CSV output
+	scored bool
+
+	// compactPaths prints the directory shared by all results once in the
+	// text header and each result relative to it
+	compactPaths bool
+
+	// collator orders names-sorted output; nil means byte order
+	collator *collate.Collator
+
+	// flagDupes marks text lines whose name occurs more than once among
+	// the results
+	flagDupes bool
+
+	// alwaysCount prints the "Found N result(s):" line in text output even
+	// when N is 0, instead of "No results found."
+	alwaysCount bool
+
+	// template lays out each result for -output template
+	template *template.Template
+
+	// summary wraps JSON output in an object that also holds the count
+	// and total size of the results
+	summary bool
+
+	// compactJSON writes -output json on one line rather than indented
+	compactJSON bool
+
+	// relativeTo is the directory that result paths are printed relative
+	// to, or "" to print them in full
+	relativeTo string
+
+	// style decorates the lines of text output
+	style textStyle
+
+	// showIndex adds the database indices of each entry to JSON output
+	showIndex bool
+
+	// pathSep replaces / between the components of printed paths, or is
+	// "" to keep it
+	pathSep string
+}
+
+// relativizePath returns full relative to the directory base, or "." for
+// base itself, and whether full lies within base at all. A trailing slash
+// on base is ignored.
+func relativizePath(full, base string) (string, bool) {
+	base = strings.TrimRight(base, "/")
+	if full == base || (base == "" && full == "/") {
+		return ".", true
+	}
+	if strings.HasPrefix(full, base+"/") {
+		return full[len(base)+1:], true
+	}
+	return full, false
+}
+
+// displayPath returns path as output shows it: relative to o.relativeTo
+// when that is set, and with o.pathSep between its components. A path
+// outside relativeTo is shown in full, with a warning.
+func (o outputOptions) displayPath(path string) string {
+	if o.relativeTo != "" {
+		rel, ok := relativizePath(path, o.relativeTo)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: %s is not under -relative %s; printing it in full\n", path, o.relativeTo)
+		}
+		path = rel
+	}
+	if o.pathSep != "" {
+		path = strings.ReplaceAll(path, "/", o.pathSep)
+	}
+	return path
+}
+
+// jsonTimeFormat returns the mtime representation used in JSON output
+func (o outputOptions) jsonTimeFormat() timeFormat {
+	if o.timeAs == "" {
+		return timeFormatBoth
+	}
+	return o.timeAs
+}
+
+// csvTimeFormat returns the mtime representation used in CSV output
+func (o outputOptions) csvTimeFormat() timeFormat {
+	if o.timeAs == "" {
+		return timeFormatRFC3339
+	}
+	return o.timeAs
+}
+
+// csvHeader returns the header row written by printCSV
+func csvHeader(opts outputOptions) []string {
+	header := []string{"name", "path", "type", "size"}
+	switch opts.csvTimeFormat() {
+	case timeFormatUnix:
+		header = append(header, "mtime_ts")
+	case timeFormatBoth:
+		header = append(header, "mtime", "mtime_ts")
+	default:
+		header = append(header, "mtime")
+	}
+	header = append(header, "num_files", "num_folders")
+	if opts.scored {
+		header = append(header, "score")
+	}
+	return header
+}
+
+// openOutput returns the destination for results: stdout when path is empty,
+// otherwise the file at path, gzip-compressed if compress is set. The returned
+// close function flushes and closes the file and must be called to produce a
+// complete gzip stream; for stdout it does nothing.
+func openOutput(path string, compress bool) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !compress {
+		return f, f.Close, nil
+	}
+
+	gz := gzip.NewWriter(f)
+	closeAll := func() error {
+		if err := gz.Close(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return gz, closeAll, nil
 }
 
 // printResults prints search results in the specified format
-func printResults(result *db.SearchResult, format outputFormat) {
+func printResults(w io.Writer, result *db.SearchResult, format outputFormat, opts outputOptions) {
 	total := len(result.Files) + len(result.Folders)
 	if total == 0 {
 		switch format {
 		case outputFormatJSON:
-			fmt.Println("[]")
+			if opts.summary {
+				printJSONSummary(w, result, nil, opts.compactJSON)
+				return
+			}
+			fmt.Fprintln(w, "[]")
+		case outputFormatXML:
+			printXML(w, nil)
 		case outputFormatCSV:
 			// Print header only
-			w := csv.NewWriter(os.Stdout)
-			w.Write([]string{"name", "path", "type", "size", "mtime"})
-			w.Flush()
+			cw := csv.NewWriter(w)
+			cw.Write(csvHeader(opts))
+			cw.Flush()
+		case outputFormatRsyncFilter:
+			printRsyncFilter(w, nil)
+		case outputFormatJSONL, outputFormatJSON0, outputFormatNamesSorted, outputFormatShell, outputFormatNull, outputFormatPaths, outputFormatTemplate:
+			// No records, no output
 		default:
-			fmt.Println("No results found.")
+			if opts.alwaysCount {
+				printText(w, nil, opts)
+				return
+			}
+			fmt.Fprintln(w, "No results found.")
 		}
 		return
 	}
 
+	// Records are written as they are converted, without the full list
+	switch format {
+	case outputFormatJSONL:
+		printJSONRecords(w, result, opts, '\n')
+		return
+	case outputFormatJSON0:
+		printJSONRecords(w, result, opts, 0)
+		return
+	}
+
+	entries := collectEntries(result, opts)
+	for i := range entries {
+		entries[i].Path = opts.displayPath(entries[i].Path)
+	}
 	switch format {
 	case outputFormatJSON:
-		printJSON(result)
+		entries = withTimeFormat(entries, opts.jsonTimeFormat())
+		if opts.summary {
+			printJSONSummary(w, result, entries, opts.compactJSON)
+		} else {
+			printJSON(w, entries, opts.compactJSON)
+		}
+	case outputFormatXML:
+		printXML(w, withTimeFormat(entries, opts.jsonTimeFormat()))
 	case outputFormatCSV:
-		printCSV(result)
+		printCSV(w, entries, opts)
+	case outputFormatRsyncFilter:
+		printRsyncFilter(w, entries)
+	case outputFormatNamesSorted:
+		printNamesSorted(w, entries, opts.collator)
+	case outputFormatShell:
+		printShell(w, entries)
+	case outputFormatNull:
+		printPaths(w, entries, 0)
+	case outputFormatPaths:
+		printPaths(w, entries, '\n')
+	case outputFormatTemplate:
+		printTemplate(w, entries, opts.template)
 	default:
-		printText(result)
+		printText(w, entries, opts)
 	}
 }
 
-func printJSON(result *db.SearchResult) {
-	entries := make([]resultEntry, 0, len(result.Files)+len(result.Folders))
+// printNamesSorted writes each distinct name once, one per line. Names are
+// in byte order, as sort, comm, and join expect under LC_ALL=C, unless coll
+// is given. Names that differ only in bytes the collator ignores are still
+// listed separately, next to each other.
+func printNamesSorted(w io.Writer, entries []resultEntry, coll *collate.Collator) {
+	seen := make(map[string]bool, len(entries))
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name != "" && !seen[entry.Name] {
+			seen[entry.Name] = true
+			names = append(names, entry.Name)
+		}
+	}
 
-	// Add folders
-	for _, folder := range result.Folders {
-		path := folder.GetFullPath()
-		if path == "" {
-			path = "/"
+	if coll == nil {
+		sort.Strings(names)
+	} else {
+		var buf collate.Buffer
+		keys := make(map[string][]byte, len(names))
+		for _, name := range names {
+			keys[name] = coll.KeyFromString(&buf, name)
 		}
-		entry := resultEntry{
-			Name:    folder.Name,
-			Path:    path,
-			Type:    "folder",
-			MTime:   folder.MTime.Format(time.RFC3339),
-			MTimeTS: folder.MTime.Unix(),
+		sort.Slice(names, func(i, j int) bool {
+			if c := bytes.Compare(keys[names[i]], keys[names[j]]); c != 0 {
+				return c < 0
+			}
+			return names[i] < names[j]
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		bw.WriteString(name)
+		bw.WriteByte('\n')
+	}
+	bw.Flush()
+}
+
+// printShell writes each path on its own line, quoted for a POSIX shell so
+// it can be pasted into a command line as a single word
+func printShell(w io.Writer, entries []resultEntry) {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		bw.WriteString(shellQuote(entry.Path))
+		bw.WriteByte('\n')
+	}
+	bw.Flush()
+}
+
+// printPaths writes each path followed by sep and nothing else. With a NUL
+// sep, for -output null, there is not even a final newline, so paths with
+// spaces or newlines reach xargs -0 intact; -output paths uses a newline.
+func printPaths(w io.Writer, entries []resultEntry, sep byte) {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		bw.WriteString(entry.Path)
+		bw.WriteByte(sep)
+	}
+	bw.Flush()
+}
+
+// keepSizedFiles drops the folders of result when field is size and it has
+// files, so -first, -nth, and -max pick the smallest or largest file. Folders
+// are sorted by name under -sort size and would otherwise be picked first.
+func keepSizedFiles(result *db.SearchResult, field sortField) {
+	if field != sortFieldSize || len(result.Files) == 0 || len(result.Folders) == 0 {
+		return
+	}
+	result.Folders = nil
+	result.Truncated = true
+}
+
+// truncateResults reduces result to the first n entries in the order that
+// order lists them, for -max applied after sorting
+func truncateResults(result *db.SearchResult, n int, order entryOrder) {
+	if len(result.Folders)+len(result.Files) <= n {
+		return
+	}
+	result.Truncated = true
+	var folders []*db.Folder
+	var files []*db.Entry
+	eachInOrder(result, order, func(folder *db.Folder) {
+		if len(folders)+len(files) < n {
+			folders = append(folders, folder)
+		}
+	}, func(file *db.Entry) {
+		if len(folders)+len(files) < n {
+			files = append(files, file)
 		}
+	})
+	result.Folders, result.Files = folders, files
+}
+
+// keepNth reduces result to the single entry that would be listed nth,
+// counting from 1, in order, or to nothing if there are fewer than n
+func keepNth(result *db.SearchResult, n int, order entryOrder) {
+	if len(result.Folders)+len(result.Files) > 1 {
+		result.Truncated = true
+	}
+	var folders []*db.Folder
+	var files []*db.Entry
+	i := 0
+	eachInOrder(result, order, func(folder *db.Folder) {
+		if i++; i == n {
+			folders = append(folders, folder)
+		}
+	}, func(file *db.Entry) {
+		if i++; i == n {
+			files = append(files, file)
+		}
+	})
+	result.Folders, result.Files = folders, files
+}
+
+// collectEntries flattens a search result into output entries, in the
+// order opts gives them
+func collectEntries(result *db.SearchResult, opts outputOptions) []resultEntry {
+	entries := make([]resultEntry, 0, len(result.Files)+len(result.Folders))
+	eachEntry(result, opts, func(entry resultEntry) {
 		entries = append(entries, entry)
+	})
+	return entries
+}
+
+// eachEntry converts the entries of result one at a time and passes each to
+// fn, in the order collectEntries lists them
+func eachEntry(result *db.SearchResult, opts outputOptions, fn func(resultEntry)) {
+	eachInOrder(result, opts.order, func(folder *db.Folder) {
+		entry := newFolderResultEntry(folder, result.FullPath(&folder.Entry), result.Scores[&folder.Entry])
+		if opts.showIndex {
+			entry.setIndex(&folder.Entry, folder)
+		}
+		if r, ok := result.Ranges[&folder.Entry]; ok {
+			entry.setMatchRange(r)
+		}
+		fn(entry)
+	}, func(file *db.Entry) {
+		entry := newResultEntry(file, result.FullPath(file), result.Scores[file])
+		if opts.showIndex {
+			entry.setIndex(file, nil)
+		}
+		if r, ok := result.Ranges[file]; ok {
+			entry.setMatchRange(r)
+		}
+		fn(entry)
+	})
+}
+
+// eachInOrder passes the folders of result to folder and its files to file,
+// in the order that order lists them
+func eachInOrder(result *db.SearchResult, order entryOrder, folder func(*db.Folder), file func(*db.Entry)) {
+	switch {
+	case order.mode == groupDirsLast:
+		for _, f := range result.Files {
+			file(f)
+		}
+		for _, f := range result.Folders {
+			folder(f)
+		}
+	case order.mode == groupMixed && order.less != nil:
+		// A folder is kept alongside its entry for the child counts
+		type item struct {
+			entry  *db.Entry
+			folder *db.Folder
+		}
+		items := make([]item, 0, len(result.Folders)+len(result.Files))
+		for _, f := range result.Folders {
+			items = append(items, item{&f.Entry, f})
+		}
+		for _, f := range result.Files {
+			items = append(items, item{f, nil})
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return order.less(items[i].entry, items[j].entry)
+		})
+		for _, it := range items {
+			if it.folder != nil {
+				folder(it.folder)
+			} else {
+				file(it.entry)
+			}
+		}
+	default:
+		for _, f := range result.Folders {
+			folder(f)
+		}
+		for _, f := range result.Files {
+			file(f)
+		}
 	}
+}
 
-	// Add files
-	for _, file := range result.Files {
-		path := file.GetFullPath()
-		entry := resultEntry{
-			Name:    file.Name,
-			Path:    path,
-			Type:    "file",
-			Size:    file.Size,
-			MTime:   file.MTime.Format(time.RFC3339),
-			MTimeTS: file.MTime.Unix(),
+// newFolderResultEntry converts a folder to its output form, with the
+// counts of its children
+func newFolderResultEntry(folder *db.Folder, path string, score float64) resultEntry {
+	entry := newResultEntry(&folder.Entry, path, score)
+	numFiles, numFolders := folder.NumFiles, folder.NumFolders
+	entry.NumFiles, entry.NumFolders = &numFiles, &numFolders
+	return entry
+}
+
+// newResultEntry converts a file or folder, whose full path is path, to its
+// output form
+func newResultEntry(e *db.Entry, path string, score float64) resultEntry {
+	entry := resultEntry{
+		Name:    e.Name,
+		Path:    path,
+		Type:    "file",
+		Size:    e.Size,
+		MTime:   e.MTime.Format(time.RFC3339),
+		MTimeTS: e.MTime.Unix(),
+		Score:   score,
+
+		ATime:            formatOptionalTime(e.ATime),
+		CTime:            formatOptionalTime(e.CTime),
+		StatusChangeTime: formatOptionalTime(e.StatusChangeTime),
+	}
+	if e.Type == db.EntryTypeFolder {
+		entry.Type, entry.Size = "folder", 0
+		if entry.Path == "" {
+			entry.Path = "/"
 		}
-		entries = append(entries, entry)
 	}
+	return entry
+}
+
+// setIndex records, for -show-index, the position of e among the files or
+// folders of its database and, for a folder, its FSearch db_index
+func (entry *resultEntry) setIndex(e *db.Entry, folder *db.Folder) {
+	index := e.Index
+	entry.Index = &index
+	if folder != nil {
+		dbIndex := folder.DBIndex
+		entry.DBIndex = &dbIndex
+	}
+}
 
-	jsonData, err := json.MarshalIndent(entries, "", "  ")
+// setMatchRange records where the query matched the entry's name
+func (entry *resultEntry) setMatchRange(r db.MatchRange) {
+	start, end := r.Start, r.End
+	entry.MatchStart, entry.MatchEnd = &start, &end
+}
+
+// formatOptionalTime formats t as RFC 3339, or returns "" for the zero time
+// of a timestamp the database does not index
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// withTimeFormat clears the mtime fields not selected by format
+func withTimeFormat(entries []resultEntry, format timeFormat) []resultEntry {
+	for i := range entries {
+		switch format {
+		case timeFormatUnix:
+			entries[i].MTime = ""
+		case timeFormatRFC3339:
+			entries[i].MTimeTS = 0
+		}
+	}
+	return entries
+}
+
+// jsonSummary is -output json with -summary: the results together with
+// totals over them. TotalSize adds up the files only, as the text summary
+// line does.
+type jsonSummary struct {
+	Results   []resultEntry `json:"results"`
+	Count     int           `json:"count"`
+	Files     int           `json:"files"`
+	TotalSize int64         `json:"total_size"`
+	Truncated bool          `json:"truncated"`
+}
+
+func printJSONSummary(w io.Writer, result *db.SearchResult, entries []resultEntry, compact bool) {
+	files, size := totalFileSize(entries)
+	if entries == nil {
+		entries = []resultEntry{}
+	}
+	jsonData, err := marshalJSON(jsonSummary{
+		Results:   entries,
+		Count:     len(entries),
+		Files:     files,
+		TotalSize: size,
+		Truncated: result.Truncated,
+	}, compact)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
+
+func printJSON(w io.Writer, entries []resultEntry, compact bool) {
+	jsonData, err := marshalJSON(entries, compact)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println(string(jsonData))
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// xmlResults is the document -output xml writes: a <results> element
+// holding an <entry type="file"> or <entry type="folder"> element for each
+// result, with the fields of -output json as child elements
+type xmlResults struct {
+	XMLName xml.Name      `xml:"results"`
+	Entries []resultEntry `xml:"entry"`
 }
 
-func printCSV(result *db.SearchResult) {
-	w := csv.NewWriter(os.Stdout)
-	defer w.Flush()
+func printXML(w io.Writer, entries []resultEntry) {
+	data, err := xml.MarshalIndent(xmlResults{Entries: entries}, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal XML: %v\n", err)
+		os.Exit(1)
+	}
+	io.WriteString(w, xml.Header)
+	fmt.Fprintln(w, string(data))
+}
+
+// marshalJSON encodes v indented by two spaces, or with compact on one
+// line, which is smaller and quicker for another program to parse
+func marshalJSON(v any, compact bool) ([]byte, error) {
+	if compact {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// printJSONRecords writes each entry of result as a compact JSON object
+// followed by sep, converting and writing one at a time so memory does not
+// grow with the number of results. JSON escapes control characters inside
+// strings, so neither a newline nor a NUL separator can occur within a
+// record, whatever the file names.
+func printJSONRecords(w io.Writer, result *db.SearchResult, opts outputOptions, sep byte) {
+	eachEntry(result, opts, func(entry resultEntry) {
+		entry.Path = opts.displayPath(entry.Path)
+		writeJSONRecord(w, entry, opts.jsonTimeFormat(), sep)
+	})
+}
+
+// writeJSONRecord writes one entry for printJSONRecords
+func writeJSONRecord(w io.Writer, entry resultEntry, format timeFormat, sep byte) {
+	record, err := json.Marshal(withTimeFormat([]resultEntry{entry}, format)[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %v\n", err)
+		os.Exit(1)
+	}
+	w.Write(append(record, sep))
+}
+
+// streamFormats write each result on its own, so they can be printed as
+// the search finds them
+var streamFormats = []outputFormat{outputFormatText, outputFormatJSONL, outputFormatJSON0, outputFormatShell, outputFormatNull, outputFormatPaths, outputFormatTemplate}
+
+// matchWriter writes matches from database one at a time in one of
+// streamFormats, each exactly as printResults would write it among the
+// others. Text starts with the number of results, which is only known once
+// the search is done, so its lines are spooled to a temporary file until
+// close writes the header, copies them, and adds the footer. Memory then
+// still does not grow with the number of matches.
+type matchWriter struct {
+	w        io.Writer
+	database *db.Database
+	format   outputFormat
+	opts     outputOptions
+
+	// Text spool and the tallies for its header and footer
+	spool        *os.File
+	lines        *bufio.Writer
+	count, files int
+	size         int64
+}
+
+// write writes a single match. An error is only possible for text, whose
+// spool may fail.
+func (mw *matchWriter) write(m db.Match) error {
+	path := mw.database.FullPath(m.Entry)
+	entry := newResultEntry(m.Entry, path, m.Score)
+	if m.Folder != nil {
+		entry = newFolderResultEntry(m.Folder, path, m.Score)
+	}
+	if mw.opts.showIndex {
+		entry.setIndex(m.Entry, m.Folder)
+	}
+	if m.Range != nil {
+		entry.setMatchRange(*m.Range)
+	}
+	entry.Path = mw.opts.displayPath(entry.Path)
+	switch mw.format {
+	case outputFormatText:
+		if mw.spool == nil {
+			spool, err := os.CreateTemp("", "gsearch-cli-*.txt")
+			if err != nil {
+				return fmt.Errorf("failed to create spool file: %w", err)
+			}
+			mw.spool, mw.lines = spool, bufio.NewWriter(spool)
+		}
+		if _, err := fmt.Fprintln(mw.lines, textLine(entry, mw.opts.style)); err != nil {
+			return fmt.Errorf("failed to write spool file: %w", err)
+		}
+		mw.count++
+		if entry.Type == "file" {
+			mw.files++
+			mw.size += entry.Size
+		}
+	case outputFormatJSONL:
+		writeJSONRecord(mw.w, entry, mw.opts.jsonTimeFormat(), '\n')
+	case outputFormatJSON0:
+		writeJSONRecord(mw.w, entry, mw.opts.jsonTimeFormat(), 0)
+	case outputFormatShell:
+		io.WriteString(mw.w, shellQuote(entry.Path)+"\n")
+	case outputFormatNull:
+		io.WriteString(mw.w, entry.Path+"\x00")
+	case outputFormatPaths:
+		io.WriteString(mw.w, entry.Path+"\n")
+	case outputFormatTemplate:
+		writeTemplateRecord(mw.w, entry, mw.opts.template)
+	}
+	return nil
+}
+
+// close finishes text output as printResults writes it: the header, the
+// spooled lines, and the footer, or "No results found." for no matches.
+// The spool file is removed.
+func (mw *matchWriter) close() error {
+	if mw.format != outputFormatText {
+		return nil
+	}
+	if mw.spool != nil {
+		defer func() {
+			mw.spool.Close()
+			os.Remove(mw.spool.Name())
+		}()
+	}
+	if mw.count == 0 && !mw.opts.alwaysCount {
+		_, err := fmt.Fprintln(mw.w, "No results found.")
+		return err
+	}
+	if err := printTextHeader(mw.w, mw.count, ""); err != nil {
+		return err
+	}
+	if mw.spool != nil {
+		if err := mw.lines.Flush(); err != nil {
+			return fmt.Errorf("failed to write spool file: %w", err)
+		}
+		if _, err := mw.spool.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to read spool file: %w", err)
+		}
+		if _, err := io.Copy(mw.w, mw.spool); err != nil {
+			return err
+		}
+	}
+	return printTextFooter(mw.w, mw.files, mw.size, mw.opts)
+}
+
+// resultMeta summarises a run for the -meta footer
+type resultMeta struct {
+	Count     int   `json:"count"`
+	Truncated bool  `json:"truncated"`
+	ElapsedMS int64 `json:"elapsed_ms"`
+}
+
+// printMetaFooter writes the closing {"_meta":{...}} line of jsonl output.
+// Result objects never have a _meta key, so consumers can tell it apart.
+func printMetaFooter(w io.Writer, meta resultMeta) {
+	footer, err := json.Marshal(struct {
+		Meta resultMeta `json:"_meta"`
+	}{meta})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, string(footer))
+}
+
+func printCSV(w io.Writer, entries []resultEntry, opts outputOptions) {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
 
 	// Write header
-	w.Write([]string{"name", "path", "type", "size", "mtime"})
+	cw.Write(csvHeader(opts))
 
-	// Write folders
-	for _, folder := range result.Folders {
-		path := folder.GetFullPath()
-		if path == "" {
-			path = "/"
-		}
-		w.Write([]string{
-			folder.Name,
-			path,
-			"folder",
-			"",
-			folder.MTime.Format(time.RFC3339),
-		})
+	for _, entry := range entries {
+		cw.Write(csvRecord(entry, opts))
 	}
+}
 
-	// Write files
-	for _, file := range result.Files {
-		path := file.GetFullPath()
-		w.Write([]string{
-			file.Name,
-			path,
-			"file",
-			fmt.Sprintf("%d", file.Size),
-			file.MTime.Format(time.RFC3339),
-		})
+// csvRecord returns the CSV row for a single entry, matching csvHeader
+func csvRecord(entry resultEntry, opts outputOptions) []string {
+	size := ""
+	if entry.Type == "file" {
+		size = fmt.Sprintf("%d", entry.Size)
+	}
+	record := []string{
+		entry.Name,
+		entry.Path,
+		entry.Type,
+		size,
+	}
+	switch opts.csvTimeFormat() {
+	case timeFormatUnix:
+		record = append(record, fmt.Sprintf("%d", entry.MTimeTS))
+	case timeFormatBoth:
+		record = append(record, entry.MTime, fmt.Sprintf("%d", entry.MTimeTS))
+	default:
+		record = append(record, entry.MTime)
+	}
+	numFiles, numFolders := "", ""
+	if entry.NumFiles != nil && entry.NumFolders != nil {
+		numFiles = strconv.FormatUint(uint64(*entry.NumFiles), 10)
+		numFolders = strconv.FormatUint(uint64(*entry.NumFolders), 10)
 	}
+	record = append(record, numFiles, numFolders)
+	if opts.scored {
+		record = append(record, strconv.FormatFloat(entry.Score, 'f', -1, 64))
+	}
+	return record
 }
 
-func printText(result *db.SearchResult) {
-	total := len(result.Files) + len(result.Folders)
-	fmt.Printf("Found %d result(s):\n\n", total)
+func printText(w io.Writer, entries []resultEntry, opts outputOptions) {
+	prefix := ""
+	if opts.compactPaths {
+		paths := make([]string, len(entries))
+		for i, entry := range entries {
+			paths[i] = entry.Path
+		}
+		prefix = commonDir(paths)
+	}
+	printTextHeader(w, len(entries), prefix)
 
-	// Print folders first
-	for _, folder := range result.Folders {
-		path := folder.GetFullPath()
-		if path == "" {
-			path = "/"
+	var nameCounts map[string]int
+	if opts.flagDupes {
+		nameCounts = make(map[string]int, len(entries))
+		for _, entry := range entries {
+			nameCounts[entry.Name]++
 		}
-		fmt.Printf("📁 %s\n", path)
 	}
 
-	// Print files
-	for _, file := range result.Files {
-		path := file.GetFullPath()
-		fmt.Printf("📄 %s", path)
-		if file.Size > 0 {
-			fmt.Printf(" (%s)", formatSize(file.Size))
+	for _, entry := range entries {
+		if prefix != "" {
+			entry.Path = strings.TrimPrefix(entry.Path, prefix+"/")
+		}
+		line := textLine(entry, opts.style)
+		if nameCounts[entry.Name] > 1 {
+			line += " [dup]"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	files, size := totalFileSize(entries)
+	printTextFooter(w, files, size, opts)
+}
+
+// printTextHeader writes the count line that starts text output, naming
+// the directory shared by the results when prefix is set
+func printTextHeader(w io.Writer, count int, prefix string) error {
+	var err error
+	if prefix == "" {
+		_, err = fmt.Fprintf(w, "Found %d result(s):\n\n", count)
+	} else {
+		_, err = fmt.Fprintf(w, "Found %d result(s) in %s:\n\n", count, prefix)
+	}
+	return err
+}
+
+// printTextFooter writes the total size of the files that ends text output,
+// or nothing when there are no files
+func printTextFooter(w io.Writer, files int, size int64, opts outputOptions) error {
+	var err error
+	if files == 1 {
+		_, err = fmt.Fprintf(w, "\nTotal size: %s in 1 file\n", formatSize(size, opts.style.units))
+	} else if files > 1 {
+		_, err = fmt.Fprintf(w, "\nTotal size: %s across %d files\n", formatSize(size, opts.style.units), files)
+	}
+	return err
+}
+
+// totalFileSize counts the files among entries and adds up their sizes.
+// Folders are left out: their size is either unknown or already the total
+// of files that may be listed too.
+func totalFileSize(entries []resultEntry) (files int, size int64) {
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			files++
+			size += entry.Size
 		}
-		fmt.Println()
 	}
+	return files, size
 }
 
+// commonDir returns the deepest directory containing every path, compared
+// whole component by component so /data/app and /data/apple share only
+// /data. It returns "" when the paths share nothing below the root.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	common := strings.Split(path.Dir(paths[0]), "/")
+	for _, p := range paths[1:] {
+		dir := strings.Split(path.Dir(p), "/")
+		n := 0
+		for n < len(common) && n < len(dir) && common[n] == dir[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	prefix := strings.Join(common, "/")
+	if prefix == "/" || strings.Trim(prefix, "/") == "" {
+		return ""
+	}
+	return prefix
+}
+
+// childCounts describes how many files and folders a folder directly
+// contains, such as "3 files, 1 folder", or returns "" when it has none or
+// the counts are unknown
+func childCounts(entry resultEntry) string {
+	if entry.NumFiles == nil || entry.NumFolders == nil || *entry.NumFiles+*entry.NumFolders == 0 {
+		return ""
+	}
+	count := func(n uint32, noun string) string {
+		if n == 1 {
+			return "1 " + noun
+		}
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+	return count(*entry.NumFiles, "file") + ", " + count(*entry.NumFolders, "folder")
+}
+
+// textLine returns the human-readable line for a single entry, decorated
+// as style says
+func textLine(entry resultEntry, style textStyle) string {
+	if entry.Type == "folder" {
+		line := style.icon("📁") + style.decorate(entry, ansiFolder)
+		if style.noEmoji && !strings.HasSuffix(entry.Path, "/") {
+			line += "/"
+		}
+		if counts := childCounts(entry); counts != "" {
+			line += " (" + counts + ")"
+		}
+		return line
+	}
+	line := style.icon("📄") + style.decorate(entry, "")
+	if entry.Size > 0 {
+		size := "(" + formatSize(entry.Size, style.units) + ")"
+		if style.color {
+			size = ansiDim + size + ansiReset
+		}
+		line += " " + size
+	}
+	return line
+}
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -30,7 +39,7 @@ func TestSortResults(t *testing.T) {
 	}
 
 	// Test sort by name
-	sortResults(result, sortFieldName)
+	sortResults(result, sortFieldName, false, nil)
 	if result.Files[0].Name != "apple.txt" {
 		t.Errorf("Sort by name: expected first file 'apple.txt', got %q", result.Files[0].Name)
 	}
@@ -43,7 +52,7 @@ func TestSortResults(t *testing.T) {
 		Files:   []*db.Entry{files[0], files[1], files[2]},
 		Folders: folders,
 	}
-	sortResults(result2, sortFieldSize)
+	sortResults(result2, sortFieldSize, false, nil)
 	if result2.Files[0].Size != 50 {
 		t.Errorf("Sort by size: expected smallest file size 50, got %d", result2.Files[0].Size)
 	}
@@ -56,13 +65,45 @@ func TestSortResults(t *testing.T) {
 		Files:   []*db.Entry{files[0], files[1], files[2]},
 		Folders: []*db.Folder{folders[0], folders[1], folders[2]},
 	}
-	sortResults(result3, sortFieldMTime)
+	sortResults(result3, sortFieldMTime, false, nil)
 	// Oldest should be first (added -1 hour)
 	if !result3.Files[0].MTime.Before(result3.Files[1].MTime) {
 		t.Error("Sort by mtime: files not sorted correctly")
 	}
 }
 
+func TestSortResultsPathLenDesc(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: ""}}
+	home := &db.Folder{Entry: db.Entry{Name: "home", Parent: root}}
+	deep := &db.Folder{Entry: db.Entry{Name: "a-rather-long-folder-name", Parent: home}}
+
+	result := &db.SearchResult{
+		Files: []*db.Entry{
+			{Name: "short.txt", Parent: home},
+			{Name: "nested.txt", Parent: deep},
+			{Name: "x", Parent: root},
+		},
+		Folders: []*db.Folder{home, deep},
+	}
+
+	sortResults(result, sortFieldPathLen, true, nil)
+
+	if got := result.Files[0].GetFullPath(); got != "/home/a-rather-long-folder-name/nested.txt" {
+		t.Errorf("Sort by pathlen desc: expected longest path first, got %q", got)
+	}
+	if got := result.Files[len(result.Files)-1].GetFullPath(); got != "/x" {
+		t.Errorf("Sort by pathlen desc: expected shortest path last, got %q", got)
+	}
+	if result.Folders[0] != deep {
+		t.Errorf("Sort by pathlen desc: expected deepest folder first, got %q", result.Folders[0].GetFullPath())
+	}
+
+	sortResults(result, sortFieldPathLen, false, nil)
+	if got := result.Files[0].GetFullPath(); got != "/x" {
+		t.Errorf("Sort by pathlen asc: expected shortest path first, got %q", got)
+	}
+}
+
 func TestPrintJSON(t *testing.T) {
 	// We can't easily test printJSON directly without capturing stdout,
 	// but we can test the JSON structure by creating entries manually
@@ -208,6 +249,9 @@ func TestSortFieldValidation(t *testing.T) {
 		{"path", sortFieldPath, true},
 		{"size", sortFieldSize, true},
 		{"mtime", sortFieldMTime, true},
+		{"pathlen", sortFieldPathLen, true},
+		{"score", sortFieldScore, true},
+		{"ext", sortFieldExt, true},
 		{"invalid", "", false},
 		{"", "", true}, // empty is valid (no sorting)
 	}
@@ -222,3 +266,1099 @@ func TestSortFieldValidation(t *testing.T) {
 	}
 }
 
+func TestTimeFormatOutput(t *testing.T) {
+	mtime := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	newResult := func() *db.SearchResult {
+		return &db.SearchResult{
+			Files: []*db.Entry{{Name: "test.txt", Size: 1024, MTime: mtime}},
+		}
+	}
+
+	tests := []struct {
+		timeAs      timeFormat
+		wantRFC3339 bool
+		wantUnix    bool
+		csvHeader   string
+	}{
+		{"", true, true, "name,path,type,size,mtime,num_files,num_folders"},
+		{timeFormatBoth, true, true, "name,path,type,size,mtime,mtime_ts,num_files,num_folders"},
+		{timeFormatUnix, false, true, "name,path,type,size,mtime_ts,num_files,num_folders"},
+		{timeFormatRFC3339, true, false, "name,path,type,size,mtime,num_files,num_folders"},
+	}
+
+	for _, tt := range tests {
+		opts := outputOptions{timeAs: tt.timeAs}
+
+		var jsonOut strings.Builder
+		printResults(&jsonOut, newResult(), outputFormatJSON, opts)
+		var decoded []map[string]interface{}
+		if err := json.Unmarshal([]byte(jsonOut.String()), &decoded); err != nil {
+			t.Fatalf("time-as %q: invalid JSON: %v", tt.timeAs, err)
+		}
+		_, hasRFC3339 := decoded[0]["mtime"]
+		_, hasUnix := decoded[0]["mtime_ts"]
+		if hasRFC3339 != tt.wantRFC3339 || hasUnix != tt.wantUnix {
+			t.Errorf("time-as %q: JSON has mtime=%v mtime_ts=%v, want %v/%v",
+				tt.timeAs, hasRFC3339, hasUnix, tt.wantRFC3339, tt.wantUnix)
+		}
+
+		var csvOut strings.Builder
+		printResults(&csvOut, newResult(), outputFormatCSV, opts)
+		records, err := csv.NewReader(strings.NewReader(csvOut.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("time-as %q: invalid CSV: %v", tt.timeAs, err)
+		}
+		if got := strings.Join(records[0], ","); got != tt.csvHeader {
+			t.Errorf("time-as %q: CSV header %q, want %q", tt.timeAs, got, tt.csvHeader)
+		}
+		if len(records[1]) != len(records[0]) {
+			t.Errorf("time-as %q: CSV row has %d fields, header has %d", tt.timeAs, len(records[1]), len(records[0]))
+		}
+		if tt.timeAs == timeFormatUnix && records[1][4] != "1704283200" {
+			t.Errorf("time-as unix: expected CSV mtime_ts 1704283200, got %q", records[1][4])
+		}
+	}
+}
+
+func TestOpenOutputGzip(t *testing.T) {
+	for _, format := range []outputFormat{outputFormatJSON, outputFormatCSV, outputFormatText} {
+		t.Run(string(format), func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "results.gz")
+			result := &db.SearchResult{
+				Files: []*db.Entry{{Name: "test.txt", Size: 1024, MTime: time.Unix(1704283200, 0)}},
+			}
+
+			w, closeOut, err := openOutput(outPath, true)
+			if err != nil {
+				t.Fatalf("openOutput failed: %v", err)
+			}
+			printResults(w, result, format, outputOptions{})
+			if err := closeOut(); err != nil {
+				t.Fatalf("close failed: %v", err)
+			}
+
+			var want strings.Builder
+			printResults(&want, result, format, outputOptions{})
+
+			f, err := os.Open(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("Output is not a valid gzip stream: %v", err)
+			}
+			got, err := io.ReadAll(gz)
+			if err != nil {
+				t.Fatalf("Failed to decompress output: %v", err)
+			}
+			if string(got) != want.String() {
+				t.Errorf("Decompressed output = %q, want %q", got, want.String())
+			}
+		})
+	}
+}
+
+func TestScoreOutput(t *testing.T) {
+	database := &db.Database{Files: []*db.Entry{
+		{Name: "myreport-final.pdf", Type: db.EntryTypeFile},
+		{Name: "report.pdf", Type: db.EntryTypeFile},
+		{Name: "report", Type: db.EntryTypeFile},
+		{Name: "annual-report.pdf", Type: db.EntryTypeFile},
+	}}
+	result := mustSearch(t, database, db.SearchOptions{Query: "report", SearchInFiles: true, Score: true})
+	sortResults(result, sortFieldScore, false, nil)
+	opts := outputOptions{scored: true}
+
+	var jsonOut strings.Builder
+	printResults(&jsonOut, result, outputFormatJSON, opts)
+	var decoded []resultEntry
+	if err := json.Unmarshal([]byte(jsonOut.String()), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	wantOrder := []string{"report", "report.pdf", "annual-report.pdf", "myreport-final.pdf"}
+	for i, entry := range decoded {
+		if entry.Name != wantOrder[i] {
+			t.Errorf("Position %d: got %q, want %q", i, entry.Name, wantOrder[i])
+		}
+		if entry.Score <= 0 {
+			t.Errorf("%s: missing score", entry.Name)
+		}
+		if i > 0 && entry.Score > decoded[i-1].Score {
+			t.Errorf("%s: score %v ranks above previous %v", entry.Name, entry.Score, decoded[i-1].Score)
+		}
+	}
+
+	var csvOut strings.Builder
+	printResults(&csvOut, result, outputFormatCSV, opts)
+	records, err := csv.NewReader(strings.NewReader(csvOut.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if got := records[0][len(records[0])-1]; got != "score" {
+		t.Errorf("Expected score as last CSV column, got %q", got)
+	}
+	if got := records[1][len(records[1])-1]; got != "1" {
+		t.Errorf("Expected exact match to score 1, got %q", got)
+	}
+
+	// Unscored output carries no score field
+	jsonOut.Reset()
+	printResults(&jsonOut, &db.SearchResult{Files: result.Files}, outputFormatJSON, outputOptions{})
+	if strings.Contains(jsonOut.String(), "score") {
+		t.Errorf("Unscored JSON should omit score:\n%s", jsonOut.String())
+	}
+}
+
+func TestJSON0Output(t *testing.T) {
+	home := &db.Folder{Entry: db.Entry{Name: "home", Type: db.EntryTypeFolder}}
+	result := &db.SearchResult{
+		Files: []*db.Entry{
+			{Name: "line\nbreak.txt", Size: 10, Parent: home, Type: db.EntryTypeFile},
+			{Name: "plain.txt", Size: 20, Parent: home, Type: db.EntryTypeFile},
+		},
+		Folders: []*db.Folder{home},
+	}
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSON0, outputOptions{})
+
+	if !strings.HasSuffix(out.String(), "\x00") {
+		t.Fatal("Expected output to end with a NUL terminator")
+	}
+	records := strings.Split(strings.TrimSuffix(out.String(), "\x00"), "\x00")
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 NUL-separated records, got %d", len(records))
+	}
+
+	wantPaths := []string{"/home", "/home/line\nbreak.txt", "/home/plain.txt"}
+	for i, record := range records {
+		var entry resultEntry
+		if err := json.Unmarshal([]byte(record), &entry); err != nil {
+			t.Fatalf("Record %d is not valid JSON: %v\n%q", i, err, record)
+		}
+		if entry.Path != wantPaths[i] {
+			t.Errorf("Record %d: path %q, want %q", i, entry.Path, wantPaths[i])
+		}
+		if strings.Contains(record, "\n") {
+			t.Errorf("Record %d contains a raw newline: %q", i, record)
+		}
+	}
+
+	out.Reset()
+	printResults(&out, &db.SearchResult{}, outputFormatJSON0, outputOptions{})
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for no results, got %q", out.String())
+	}
+}
+
+func TestKeepNth(t *testing.T) {
+	docs := &db.Folder{Entry: db.Entry{Name: "docs"}}
+	a := &db.Entry{Name: "a.txt"}
+	b := &db.Entry{Name: "b.txt"}
+
+	result := &db.SearchResult{Files: []*db.Entry{a, b}, Folders: []*db.Folder{docs}}
+	keepNth(result, 1, entryOrder{})
+	if len(result.Folders) != 1 || len(result.Files) != 0 {
+		t.Errorf("Expected only the folder, got %d folders and %d files", len(result.Folders), len(result.Files))
+	}
+
+	result = &db.SearchResult{Files: []*db.Entry{b, a}}
+	keepNth(result, 1, entryOrder{})
+	if len(result.Files) != 1 || result.Files[0] != b {
+		t.Errorf("Expected only b.txt, got %v", result.Files)
+	}
+
+	empty := &db.SearchResult{}
+	keepNth(empty, 1, entryOrder{})
+	if len(empty.Files)+len(empty.Folders) != 0 {
+		t.Error("Expected empty result to stay empty")
+	}
+
+	// The nth entry is counted in the order the results are listed
+	result = &db.SearchResult{Files: []*db.Entry{a, b}, Folders: []*db.Folder{docs}}
+	keepNth(result, 2, entryOrder{})
+	if len(result.Folders) != 0 || len(result.Files) != 1 || result.Files[0] != a || !result.Truncated {
+		t.Errorf("Expected only a.txt, got %v %v", result.Folders, result.Files)
+	}
+
+	result = &db.SearchResult{Files: []*db.Entry{a, b}, Folders: []*db.Folder{docs}}
+	keepNth(result, 3, entryOrder{mode: groupDirsLast})
+	if len(result.Folders) != 1 || len(result.Files) != 0 {
+		t.Errorf("Expected only the folder with dirs-last, got %v %v", result.Folders, result.Files)
+	}
+
+	result = &db.SearchResult{Files: []*db.Entry{a, b}}
+	keepNth(result, 3, entryOrder{})
+	if len(result.Files)+len(result.Folders) != 0 {
+		t.Errorf("Expected nothing past the last result, got %v", result.Files)
+	}
+}
+
+func TestKeepSizedFilesFirst(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	docs := &db.Folder{Entry: db.Entry{Name: "docs", Parent: root, Type: db.EntryTypeFolder}}
+	big := &db.Entry{Name: "big.bin", Size: 4096, Parent: docs, Type: db.EntryTypeFile}
+	small := &db.Entry{Name: "small.txt", Size: 10, Parent: docs, Type: db.EntryTypeFile}
+
+	// -sort size -first without -files: the smallest file, not a folder
+	result := &db.SearchResult{Files: []*db.Entry{big, small}, Folders: []*db.Folder{root, docs}}
+	sortResults(result, sortFieldSize, false, nil)
+	keepSizedFiles(result, sortFieldSize)
+	keepNth(result, 1, entryOrder{})
+	if len(result.Folders) != 0 || len(result.Files) != 1 || result.Files[0] != small || !result.Truncated {
+		t.Errorf("Expected only small.txt, got %v %v", result.Folders, result.Files)
+	}
+
+	// Folders are kept when no file matched, and for other sort fields
+	result = &db.SearchResult{Folders: []*db.Folder{root, docs}}
+	keepSizedFiles(result, sortFieldSize)
+	if len(result.Folders) != 2 {
+		t.Errorf("Expected the folders to stay without files, got %v", result.Folders)
+	}
+	result = &db.SearchResult{Files: []*db.Entry{big}, Folders: []*db.Folder{docs}}
+	keepSizedFiles(result, sortFieldName)
+	if len(result.Folders) != 1 || result.Truncated {
+		t.Errorf("Expected the folder to stay when sorting by name, got %v", result.Folders)
+	}
+}
+
+func TestJSONLMetaFooter(t *testing.T) {
+	database := &db.Database{}
+	for i := 0; i < 5; i++ {
+		database.Files = append(database.Files, &db.Entry{Name: "report" + strings.Repeat("x", i) + ".txt", Type: db.EntryTypeFile})
+	}
+	result := mustSearch(t, database, db.SearchOptions{Query: "report", SearchInFiles: true, MaxResults: 3})
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSONL, outputOptions{})
+	printMetaFooter(&out, resultMeta{Count: len(result.Files), Truncated: result.Truncated, ElapsedMS: 12})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	var count int
+	var footer struct {
+		Meta *resultMeta `json:"_meta"`
+	}
+	for i, line := range lines {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v\n%s", i, err, line)
+		}
+		if _, ok := obj["_meta"]; !ok {
+			count++
+			continue
+		}
+		if i != len(lines)-1 {
+			t.Errorf("_meta line %d is not the last line", i)
+		}
+		if err := json.Unmarshal([]byte(line), &footer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if footer.Meta == nil {
+		t.Fatal("Missing _meta footer")
+	}
+	if count != 3 || footer.Meta.Count != count {
+		t.Errorf("Counted %d result lines, footer says %d, want 3", count, footer.Meta.Count)
+	}
+	if !footer.Meta.Truncated {
+		t.Error("Expected truncated=true when -max cut the search short")
+	}
+	if footer.Meta.ElapsedMS != 12 {
+		t.Errorf("Expected elapsed_ms 12, got %d", footer.Meta.ElapsedMS)
+	}
+
+	untruncated := mustSearch(t, database, db.SearchOptions{Query: "report", SearchInFiles: true})
+	if untruncated.Truncated {
+		t.Error("Expected truncated=false without limits")
+	}
+}
+
+func TestCompactPaths(t *testing.T) {
+	entries := []resultEntry{
+		{Name: "2024", Path: "/home/user/projects/report/2024", Type: "folder"},
+		{Name: "draft.md", Path: "/home/user/projects/report/2024/draft.md", Type: "file"},
+		{Name: "notes.txt", Path: "/home/user/projects/report/notes.txt", Type: "file", Size: 2048},
+	}
+
+	var buf strings.Builder
+	printText(&buf, entries, outputOptions{compactPaths: true})
+	want := "Found 3 result(s) in /home/user/projects/report:\n\n" +
+		"📁 2024\n" +
+		"📄 2024/draft.md\n" +
+		"📄 notes.txt (2.0 KiB)\n" +
+		"\nTotal size: 2.0 KiB across 2 files\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// Without a shared directory below the root, paths stay in full
+	buf.Reset()
+	printText(&buf, []resultEntry{
+		{Name: "a.txt", Path: "/home/a.txt", Type: "file"},
+		{Name: "b.txt", Path: "/srv/b.txt", Type: "file"},
+	}, outputOptions{compactPaths: true})
+	if !strings.HasPrefix(buf.String(), "Found 2 result(s):\n") || !strings.Contains(buf.String(), "📄 /srv/b.txt") {
+		t.Errorf("Expected full paths, got:\n%s", buf.String())
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"/data/x.txt"}, "/data"},
+		{[]string{"/data/app/a", "/data/apple/b"}, "/data"},
+		{[]string{"/data/app/a", "/data/app/sub/b"}, "/data/app"},
+		{[]string{"/data/x", "/other/y"}, ""},
+		{[]string{"/x"}, ""},
+		{[]string{"/"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonDir(tt.paths); got != tt.want {
+			t.Errorf("commonDir(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestTimeoutPartialJSON(t *testing.T) {
+	database := &db.Database{}
+	for i := 0; i < 5000; i++ {
+		database.Files = append(database.Files, &db.Entry{Name: fmt.Sprintf("report%04d.txt", i), Type: db.EntryTypeFile})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	result, err := database.SearchContext(ctx, db.SearchOptions{Query: "report", SearchInFiles: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the search to time out, got %v", err)
+	}
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSON, outputOptions{})
+	var entries []resultEntry
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil {
+		t.Fatalf("Partial output is not a valid JSON array: %v\n%s", err, out.String())
+	}
+	if len(entries) == 0 || len(entries) >= 5000 {
+		t.Errorf("Expected a partial result set, got %d of 5000", len(entries))
+	}
+
+	out.Reset()
+	printResults(&out, result, outputFormatJSONL, outputOptions{})
+	printMetaFooter(&out, resultMeta{Count: len(result.Files), Truncated: result.Truncated})
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	var footer struct {
+		Meta resultMeta `json:"_meta"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &footer); err != nil {
+		t.Fatal(err)
+	}
+	if !footer.Meta.Truncated || footer.Meta.Count != len(entries) {
+		t.Errorf("Expected truncated footer counting %d, got %+v", len(entries), footer.Meta)
+	}
+}
+
+func TestNamesSorted(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	src := &db.Folder{Entry: db.Entry{Name: "src", Parent: root, Type: db.EntryTypeFolder}}
+	result := &db.SearchResult{
+		Folders: []*db.Folder{root, src},
+		Files: []*db.Entry{
+			{Name: "main.go", Parent: src},
+			{Name: "README", Parent: root},
+			{Name: "main.go", Parent: root},
+			{Name: "Makefile", Parent: src},
+			{Name: "ändern.txt", Parent: src},
+		},
+	}
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatNamesSorted, outputOptions{})
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{"Makefile", "README", "main.go", "src", "ändern.txt"}
+	if strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected byte-ordered %v, got %v", want, lines)
+	}
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		if seen[line] {
+			t.Errorf("Name %q listed twice", line)
+		}
+		seen[line] = true
+		if i > 0 && lines[i-1] >= line {
+			t.Errorf("%q listed before %q", lines[i-1], line)
+		}
+	}
+
+	loc, err := newLocale("en", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	printResults(&out, result, outputFormatNamesSorted, outputOptions{collator: loc.coll})
+	want = []string{"ändern.txt", "main.go", "Makefile", "README", "src"}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected collated %v, got %v", want, got)
+	}
+}
+
+func TestAlwaysCount(t *testing.T) {
+	empty := &db.SearchResult{}
+
+	var out strings.Builder
+	printResults(&out, empty, outputFormatText, outputOptions{})
+	if out.String() != "No results found.\n" {
+		t.Errorf("Expected default empty output unchanged, got %q", out.String())
+	}
+
+	out.Reset()
+	printResults(&out, empty, outputFormatText, outputOptions{alwaysCount: true})
+	if out.String() != "Found 0 result(s):\n\n" {
+		t.Errorf("Expected count line for zero results, got %q", out.String())
+	}
+
+	out.Reset()
+	result := &db.SearchResult{Files: []*db.Entry{{Name: "a.txt", Type: db.EntryTypeFile}}}
+	printResults(&out, result, outputFormatText, outputOptions{alwaysCount: true})
+	if !strings.HasPrefix(out.String(), "Found 1 result(s):\n\n") {
+		t.Errorf("Expected count line, got %q", out.String())
+	}
+
+	// Streamed text writes the same count line
+	database := loadTestDatabase(t)
+	for _, opts := range []outputOptions{{}, {alwaysCount: true}} {
+		var want, got strings.Builder
+		printResults(&want, empty, outputFormatText, opts)
+		mw := &matchWriter{w: &got, database: database, format: outputFormatText, opts: opts}
+		database.SearchStream(db.SearchOptions{Query: "no-such-name", SearchInFiles: true, SearchInFolders: true}, func(m db.Match) bool {
+			mw.write(m)
+			return true
+		})
+		if err := mw.close(); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("alwaysCount %v: streamed %q, want %q", opts.alwaysCount, got.String(), want.String())
+		}
+	}
+}
+
+func TestFlagDupes(t *testing.T) {
+	entries := []resultEntry{
+		{Name: "config", Path: "/etc/app/config", Type: "folder"},
+		{Name: "config.yaml", Path: "/srv/a/config.yaml", Type: "file"},
+		{Name: "notes.txt", Path: "/srv/a/notes.txt", Type: "file"},
+		{Name: "config.yaml", Path: "/srv/b/config.yaml", Type: "file"},
+		{Name: "Notes.txt", Path: "/srv/b/Notes.txt", Type: "file"},
+	}
+
+	var out strings.Builder
+	printText(&out, entries, outputOptions{flagDupes: true})
+	want := "Found 5 result(s):\n\n" +
+		"📁 /etc/app/config\n" +
+		"📄 /srv/a/config.yaml [dup]\n" +
+		"📄 /srv/a/notes.txt\n" +
+		"📄 /srv/b/config.yaml [dup]\n" +
+		"📄 /srv/b/Notes.txt\n" +
+		"\nTotal size: 0 B across 4 files\n"
+	if out.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printText(&out, entries, outputOptions{})
+	if strings.Contains(out.String(), "[dup]") {
+		t.Errorf("Expected no markers without flagDupes:\n%s", out.String())
+	}
+}
+
+func TestReindexHint(t *testing.T) {
+	indexed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	database := &db.Database{
+		IndexFlags: db.IndexFlagName | db.IndexFlagModificationTime,
+		Files: []*db.Entry{
+			{Name: "old.txt", MTime: indexed.Add(-time.Hour)},
+			{Name: "new.txt", MTime: indexed.Add(time.Hour)},
+		},
+	}
+
+	var out strings.Builder
+	if err := showReindexHint(&out, database, indexed); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Newest file:   2024-03-01T13:00:00Z",
+		"Files modified after the index was written: 1 of 2",
+		"re-run fsearch",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := showReindexHint(&out, database, indexed.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "up to date") {
+		t.Errorf("Expected an up-to-date index, got:\n%s", out.String())
+	}
+
+	database.IndexFlags = db.IndexFlagName
+	if err := showReindexHint(&out, database, indexed); err == nil {
+		t.Error("Expected an error without indexed modification times")
+	}
+}
+
+func TestShellOutput(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	home := &db.Folder{Entry: db.Entry{Name: "my docs", Parent: root, Type: db.EntryTypeFolder}}
+	names := []string{"plain.txt", "it's $HOME.txt", `back\slash "quoted".txt`, "glob*?[x].txt", "semi;amp&pipe|.txt"}
+	result := &db.SearchResult{Folders: []*db.Folder{home}}
+	for _, name := range names {
+		result.Files = append(result.Files, &db.Entry{Name: name, Parent: home})
+	}
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatShell, outputOptions{})
+	want := `'/my docs'
+'/my docs/plain.txt'
+'/my docs/it'\''s $HOME.txt'
+'/my docs/back\slash "quoted".txt'
+'/my docs/glob*?[x].txt'
+'/my docs/semi;amp&pipe|.txt'
+`
+	if out.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	// Pasted into a shell, each line must be exactly one word: the path
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	cmd := exec.Command("/bin/sh", "-c", "printf '%s\\n' "+strings.Join(lines, " "))
+	got, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh failed: %v", err)
+	}
+	paths := []string{"/my docs"}
+	for _, name := range names {
+		paths = append(paths, "/my docs/"+name)
+	}
+	if string(got) != strings.Join(paths, "\n")+"\n" {
+		t.Errorf("Shell saw:\n%s\nwant:\n%s", got, strings.Join(paths, "\n"))
+	}
+
+	var empty strings.Builder
+	printResults(&empty, &db.SearchResult{}, outputFormatShell, outputOptions{})
+	if empty.Len() != 0 {
+		t.Errorf("Expected no output for no results, got %q", empty.String())
+	}
+}
+
+func TestSortResultsDescStable(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	a := &db.Folder{Entry: db.Entry{Name: "a", Parent: root, Type: db.EntryTypeFolder}}
+	b := &db.Folder{Entry: db.Entry{Name: "b", Parent: root, Type: db.EntryTypeFolder}}
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := func() []*db.Entry {
+		// Pairs that tie on every field except their position
+		return []*db.Entry{
+			{Name: "x", Size: 10, MTime: old, Parent: a},
+			{Name: "y", Size: 20, MTime: old.Add(time.Hour), Parent: a},
+			{Name: "x", Size: 10, MTime: old, Parent: b},
+			{Name: "y", Size: 20, MTime: old.Add(time.Hour), Parent: b},
+		}
+	}
+
+	for _, field := range []sortField{sortFieldName, sortFieldSize, sortFieldMTime} {
+		result := &db.SearchResult{Files: files()}
+		sortResults(result, field, true, nil)
+		var got []string
+		for _, f := range result.Files {
+			got = append(got, f.GetFullPath())
+		}
+		want := "/a/y,/b/y,/a/x,/b/x"
+		if strings.Join(got, ",") != want {
+			t.Errorf("-sort %s -reverse: got %v, want %s", field, got, want)
+		}
+	}
+
+	result := &db.SearchResult{Files: files()}
+	sortResults(result, sortFieldPath, true, nil)
+	if got := result.Files[0].GetFullPath(); got != "/b/y" {
+		t.Errorf("-sort path -reverse: expected /b/y first, got %s", got)
+	}
+}
+
+func TestNullOutput(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	dir := &db.Folder{Entry: db.Entry{Name: "two words", Parent: root, Type: db.EntryTypeFolder}}
+	result := &db.SearchResult{
+		Folders: []*db.Folder{dir},
+		Files: []*db.Entry{
+			{Name: "line\nbreak.txt", Parent: dir},
+			{Name: "plain.txt", Parent: root},
+		},
+	}
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatNull, outputOptions{})
+	want := "/two words\x00/two words/line\nbreak.txt\x00/plain.txt\x00"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+
+	var empty strings.Builder
+	printResults(&empty, &db.SearchResult{}, outputFormatNull, outputOptions{})
+	if empty.Len() != 0 {
+		t.Errorf("Expected no output for no results, got %q", empty.String())
+	}
+}
+
+func TestJSONLRecords(t *testing.T) {
+	database := loadTestDatabase(t)
+	result := mustSearch(t, database, db.SearchOptions{Query: "t", SearchInFiles: true, SearchInFolders: true})
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSONL, outputOptions{timeAs: timeFormatUnix})
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := collectEntries(result, outputOptions{})
+	if len(lines) != len(want) {
+		t.Fatalf("Got %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if strings.Contains(line, "\n") || strings.Contains(line, "  ") {
+			t.Errorf("Line %d is not compact: %s", i, line)
+		}
+		var entry resultEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v", i, err)
+		}
+		if entry.Path != want[i].Path || entry.MTime != "" || entry.MTimeTS != want[i].MTimeTS {
+			t.Errorf("Line %d = %+v, want %s with only mtime_ts", i, entry, want[i].Path)
+		}
+	}
+
+	out.Reset()
+	printResults(&out, &db.SearchResult{}, outputFormatJSONL, outputOptions{})
+	if out.Len() != 0 {
+		t.Errorf("Expected no output for no results, got %q", out.String())
+	}
+}
+
+func TestMatchWriterMatchesPrintResults(t *testing.T) {
+	database := loadTestDatabase(t)
+	opts := db.SearchOptions{Query: "e", SearchInFiles: true, SearchInFolders: true, Score: true}
+	result := mustSearch(t, database, opts)
+	tmpl, err := parseOutputTemplate(`{{.Name}}\t{{.Size}}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outOpts := outputOptions{template: tmpl}
+
+	// Folders first, in the order printResults lists them by default
+	opts.FoldersFirst = true
+	for _, format := range streamFormats {
+		var want, got strings.Builder
+		printResults(&want, result, format, outOpts)
+
+		mw := &matchWriter{w: &got, database: database, format: format, opts: outOpts}
+		database.SearchStream(opts, func(m db.Match) bool {
+			mw.write(m)
+			return true
+		})
+		if err := mw.close(); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s: streamed\n%q\nwant\n%q", format, got.String(), want.String())
+		}
+	}
+}
+
+func TestSortByExtension(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	result := &db.SearchResult{
+		Folders: []*db.Folder{
+			{Entry: db.Entry{Name: "zeta.d", Parent: root, Type: db.EntryTypeFolder}},
+			{Entry: db.Entry{Name: "alpha.z", Parent: root, Type: db.EntryTypeFolder}},
+		},
+		Files: []*db.Entry{
+			{Name: "b.txt", Parent: root},
+			{Name: "photo.JPG", Parent: root},
+			{Name: "Makefile", Parent: root},
+			{Name: "a.txt", Parent: root},
+			{Name: ".bashrc", Parent: root},
+			{Name: "archive.tar.gz", Parent: root},
+			{Name: "cover.jpg", Parent: root},
+		},
+	}
+
+	sortResults(result, sortFieldExt, false, nil)
+	var got []string
+	for _, f := range result.Files {
+		got = append(got, f.Name)
+	}
+	want := []string{".bashrc", "Makefile", "archive.tar.gz", "cover.jpg", "photo.JPG", "a.txt", "b.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Files sorted as %v, want %v", got, want)
+	}
+	if result.Folders[0].Name != "alpha.z" {
+		t.Errorf("Expected folders sorted by name, got %s first", result.Folders[0].Name)
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	ordered := []string{"", "img", "img1.png", "img2.png", "img02.png", "img10.png", "img10a.png", "img10b", "img100.png", "imgA"}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			if got := naturalLess(a, b); got != (i < j) {
+				t.Errorf("naturalLess(%q, %q) = %v, want %v", a, b, got, i < j)
+			}
+		}
+	}
+}
+
+func TestNaturalSort(t *testing.T) {
+	names := func(result *db.SearchResult) string {
+		var out []string
+		for _, f := range result.Files {
+			out = append(out, f.Name)
+		}
+		return strings.Join(out, " ")
+	}
+	newResult := func() *db.SearchResult {
+		root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+		result := &db.SearchResult{}
+		for _, name := range []string{"img10.png", "img2.png", "img1.png"} {
+			result.Files = append(result.Files, &db.Entry{Name: name, Parent: root})
+		}
+		return result
+	}
+
+	for _, natural := range []bool{true, false} {
+		loc, err := newLocale("und", false, natural)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range []sortField{sortFieldName, sortFieldPath} {
+			result := newResult()
+			sortResults(result, field, false, loc.coll)
+			want := "img1.png img2.png img10.png"
+			if !natural {
+				want = "img1.png img10.png img2.png"
+			}
+			if got := names(result); got != want {
+				t.Errorf("natural=%v, -sort %s: got %s, want %s", natural, field, got, want)
+			}
+		}
+	}
+
+	result := newResult()
+	sortResults(result, sortFieldName, false, nil)
+	if got := names(result); got != "img1.png img2.png img10.png" {
+		t.Errorf("Without a collator: got %s", got)
+	}
+}
+
+func TestFolderChildCounts(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "o", SearchInFolders: true})
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatText, outputOptions{})
+	want := "Found 3 result(s):\n\n" +
+		"📁 /home (0 files, 1 folder)\n" +
+		"📁 /Documents (2 files, 0 folders)\n" +
+		"📁 /Downloads (1 file, 0 folders)\n"
+	if out.String() != want {
+		t.Errorf("Text output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printResults(&out, result, outputFormatJSONL, outputOptions{})
+	if !strings.Contains(out.String(), `"path":"/Documents","type":"folder","num_files":2,"num_folders":0`) {
+		t.Errorf("Expected child counts in JSON, got:\n%s", out.String())
+	}
+
+	// Files have no counts
+	files := &db.SearchResult{Files: []*db.Entry{{Name: "a.txt", Size: 1}}}
+	out.Reset()
+	printResults(&out, files, outputFormatCSV, outputOptions{})
+	if !strings.HasSuffix(out.String(), ",,\n") {
+		t.Errorf("Expected empty count columns for a file, got:\n%s", out.String())
+	}
+}
+
+func TestJSONSummary(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "txt", SearchInFiles: true, SearchInFolders: true})
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSON, outputOptions{summary: true})
+	var got jsonSummary
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if got.Count != 2 || len(got.Results) != 2 || got.Files != 2 || got.TotalSize != 3072 {
+		t.Errorf("Unexpected summary: %+v", got)
+	}
+
+	out.Reset()
+	printResults(&out, &db.SearchResult{}, outputFormatJSON, outputOptions{summary: true})
+	if !strings.Contains(out.String(), `"results": []`) || !strings.Contains(out.String(), `"total_size": 0`) {
+		t.Errorf("Unexpected empty summary:\n%s", out.String())
+	}
+}
+
+func TestJSONCompact(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "txt", SearchInFiles: true})
+
+	var indented, compact strings.Builder
+	printResults(&indented, result, outputFormatJSON, outputOptions{})
+	printResults(&compact, result, outputFormatJSON, outputOptions{compactJSON: true})
+	if strings.Count(compact.String(), "\n") != 1 || !strings.HasPrefix(compact.String(), `[{"name":`) {
+		t.Errorf("Expected one compact line, got:\n%s", compact.String())
+	}
+	var a, b []resultEntry
+	if err := json.Unmarshal([]byte(indented.String()), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(compact.String()), &b); err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 2 || len(b) != len(a) || a[0] != b[0] || a[1] != b[1] {
+		t.Errorf("Compact JSON decodes differently:\n%v\n%v", a, b)
+	}
+
+	compact.Reset()
+	printResults(&compact, &db.SearchResult{}, outputFormatJSON, outputOptions{compactJSON: true})
+	if compact.String() != "[]\n" {
+		t.Errorf("Empty compact output = %q", compact.String())
+	}
+}
+
+func TestRelativizePath(t *testing.T) {
+	tests := []struct {
+		full, base, want string
+		ok               bool
+	}{
+		{"/home/user/a.txt", "/home/user", "a.txt", true},
+		{"/home/user/a.txt", "/home/user/", "a.txt", true},
+		{"/home/user", "/home/user/", ".", true},
+		{"/home/user/a.txt", "/", "home/user/a.txt", true},
+		{"/", "/", ".", true},
+		{"/home/username/a.txt", "/home/user", "/home/username/a.txt", false},
+		{"/srv/a.txt", "/home", "/srv/a.txt", false},
+	}
+	for _, tt := range tests {
+		got, ok := relativizePath(tt.full, tt.base)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("relativizePath(%q, %q) = %q, %v; want %q, %v", tt.full, tt.base, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "txt", SearchInFiles: true})
+	var out strings.Builder
+	printResults(&out, result, outputFormatNull, outputOptions{relativeTo: "/home"})
+	if want := "user/test.txt\x00user/readme.txt\x00"; out.String() != want {
+		t.Errorf("Relative output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	printResults(&out, result, outputFormatNull, outputOptions{relativeTo: "/home", pathSep: `\`})
+	if want := `user\test.txt` + "\x00" + `user\readme.txt` + "\x00"; out.String() != want {
+		t.Errorf("Relative output with -sep = %q, want %q", out.String(), want)
+	}
+}
+
+func TestXMLOutput(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "user", SearchInFiles: true, SearchInFolders: true})
+	result.Files = append(result.Files, &db.Entry{Name: "a<b>&c.txt", Size: 7, Type: db.EntryTypeFile})
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatXML, outputOptions{timeAs: timeFormatUnix})
+	var doc struct {
+		Entries []struct {
+			Type     string `xml:"type,attr"`
+			Name     string `xml:"name"`
+			Size     int64  `xml:"size"`
+			NumFiles int    `xml:"num_files"`
+			MTime    string `xml:"mtime"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("Invalid XML: %v\n%s", err, out.String())
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d:\n%s", len(doc.Entries), out.String())
+	}
+	if e := doc.Entries[0]; e.Type != "folder" || e.Name != "user" || e.NumFiles != 2 || e.MTime != "" {
+		t.Errorf("Unexpected folder entry: %+v", e)
+	}
+	if e := doc.Entries[1]; e.Type != "file" || e.Name != "a<b>&c.txt" || e.Size != 7 {
+		t.Errorf("Unexpected file entry: %+v", e)
+	}
+
+	out.Reset()
+	printResults(&out, &db.SearchResult{}, outputFormatXML, outputOptions{})
+	if !strings.HasSuffix(out.String(), "<results></results>\n") {
+		t.Errorf("Unexpected empty output: %q", out.String())
+	}
+}
+
+func TestPathsOutput(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "test", SearchInFiles: true})
+	sortResults(result, sortFieldPath, false, nil)
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatPaths, outputOptions{})
+	want := "/Documents/test.go\n/home/user/test.txt\n"
+	if out.String() != want {
+		t.Errorf("Paths output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	printResults(&out, &db.SearchResult{}, outputFormatPaths, outputOptions{})
+	if out.String() != "" {
+		t.Errorf("Expected no output for no results, got %q", out.String())
+	}
+}
+
+func TestGroupOrder(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "o", SearchInFiles: true, SearchInFolders: true})
+	sortResults(result, sortFieldPath, false, nil)
+	paths := func(order entryOrder) string {
+		var out strings.Builder
+		printResults(&out, result, outputFormatPaths, outputOptions{order: order})
+		return out.String()
+	}
+
+	folders := "/Documents\n/Downloads\n/home\n"
+	files := "/Documents/document.pdf\n/Documents/test.go\n"
+	if got := paths(entryOrder{}); got != folders+files {
+		t.Errorf("Default order = %q", got)
+	}
+	if got := paths(entryOrder{mode: groupDirsFirst}); got != folders+files {
+		t.Errorf("dirs-first order = %q", got)
+	}
+	if got := paths(entryOrder{mode: groupDirsLast}); got != files+folders {
+		t.Errorf("dirs-last order = %q", got)
+	}
+
+	less, _ := sortLess(result, sortFieldPath, false, nil)
+	want := "/Documents\n/Documents/document.pdf\n/Documents/test.go\n/Downloads\n/home\n"
+	if got := paths(entryOrder{mode: groupMixed, less: less}); got != want {
+		t.Errorf("mixed order = %q, want %q", got, want)
+	}
+}
+
+func TestShowIndex(t *testing.T) {
+	database := loadTestDatabase(t)
+	result := mustSearch(t, database, db.SearchOptions{Query: "user", SearchInFiles: true, SearchInFolders: true})
+	result.Folders[0].DBIndex = 3
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSONL, outputOptions{showIndex: true})
+	want := `{"name":"user","path":"/home/user","type":"folder","num_files":2,"num_folders":0,"mtime":`
+	if !strings.HasPrefix(out.String(), want) || !strings.Contains(out.String(), `"index":2,"db_index":3}`) {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	out.Reset()
+	printResults(&out, result, outputFormatJSONL, outputOptions{})
+	if strings.Contains(out.String(), "index") {
+		t.Errorf("Indices without -show-index: %s", out.String())
+	}
+}
+
+func TestMatchRangeOutput(t *testing.T) {
+	database := loadTestDatabase(t)
+	opts := db.SearchOptions{Query: "ME.t", SearchInFiles: true, MatchRanges: true}
+	result := mustSearch(t, database, opts)
+
+	var out strings.Builder
+	printResults(&out, result, outputFormatJSONL, outputOptions{})
+	if !strings.Contains(out.String(), `"name":"readme.txt"`) || !strings.HasSuffix(out.String(), `"match_start":4,"match_end":8}`+"\n") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	// Streamed records carry the same range
+	out.Reset()
+	mw := &matchWriter{w: &out, database: database, format: outputFormatJSONL}
+	database.SearchStream(opts, func(m db.Match) bool {
+		mw.write(m)
+		return true
+	})
+	if !strings.HasSuffix(out.String(), `"match_start":4,"match_end":8}`+"\n") {
+		t.Errorf("Unexpected streamed output: %s", out.String())
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		units sizeUnits
+		want  string
+	}{
+		{0, unitsBinary, "0 B"},
+		{1000, unitsBinary, "1000 B"},
+		{1023, unitsBinary, "1023 B"},
+		{1024, unitsBinary, "1.0 KiB"},
+		{1536, unitsBinary, "1.5 KiB"},
+		{1 << 20, unitsBinary, "1.0 MiB"},
+		{999, unitsSI, "999 B"},
+		{1000, unitsSI, "1.0 KB"},
+		{1023, unitsSI, "1.0 KB"},
+		{1024, unitsSI, "1.0 KB"},
+		{1500000, unitsSI, "1.5 MB"},
+		{1023, unitsBytes, "1023 B"},
+		{1024, unitsBytes, "1024 B"},
+		{1 << 30, unitsBytes, "1073741824 B"},
+		// The zero value is binary, as text output defaults to
+		{1024, "", "1.0 KiB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.bytes, tt.units); got != tt.want {
+			t.Errorf("formatSize(%d, %q) = %q, want %q", tt.bytes, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateAfterSort(t *testing.T) {
+	sizes := []int64{500, 20, 300, 10, 4000, 60}
+	result := &db.SearchResult{}
+	for i, size := range sizes {
+		result.Files = append(result.Files, &db.Entry{Name: fmt.Sprintf("f%d", i), Size: size, Type: db.EntryTypeFile})
+	}
+
+	// The smallest three are kept, not the first three found
+	sortResults(result, sortFieldSize, false, nil)
+	truncateResults(result, 3, entryOrder{})
+	if got := fileNames(result); got != "f3,f1,f5" || !result.Truncated {
+		t.Errorf("Kept %s (truncated %v), want f3,f1,f5", got, result.Truncated)
+	}
+
+	// Folders come first unless -group puts them last
+	docs := &db.Folder{Entry: db.Entry{Name: "docs", Type: db.EntryTypeFolder}}
+	result = &db.SearchResult{Files: result.Files, Folders: []*db.Folder{docs}}
+	truncateResults(result, 2, entryOrder{})
+	if len(result.Folders) != 1 || fileNames(result) != "f3" {
+		t.Errorf("dirs-first kept %v and %s", result.Folders, fileNames(result))
+	}
+	result = &db.SearchResult{Files: []*db.Entry{{Name: "a"}, {Name: "b"}}, Folders: []*db.Folder{docs}}
+	truncateResults(result, 2, entryOrder{mode: groupDirsLast})
+	if len(result.Folders) != 0 || len(result.Files) != 2 {
+		t.Errorf("dirs-last kept %v and %s", result.Folders, fileNames(result))
+	}
+
+	// Nothing is cut from a result within the limit
+	result = &db.SearchResult{Files: []*db.Entry{{Name: "a"}}}
+	truncateResults(result, 1, entryOrder{})
+	if len(result.Files) != 1 || result.Truncated {
+		t.Errorf("Result within the limit changed: %s, truncated %v", fileNames(result), result.Truncated)
+	}
+}
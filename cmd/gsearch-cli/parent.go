@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// resolveEntries finds the entries named by target: the one at that full
+// path if target contains a slash, otherwise every entry with that name
+func resolveEntries(database *db.Database, target string) ([]*db.Entry, error) {
+	if strings.Contains(target, "/") {
+		e, ok := database.EntryByPath(target)
+		if !ok {
+			return nil, fmt.Errorf("no entry at %s", target)
+		}
+		return []*db.Entry{e}, nil
+	}
+
+	var entries []*db.Entry
+	for _, folder := range database.Folders {
+		if folder.Name == target {
+			entries = append(entries, &folder.Entry)
+		}
+	}
+	for _, file := range database.Files {
+		if file.Name == target {
+			entries = append(entries, file)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entry named %q", target)
+	}
+	return entries, nil
+}
+
+// showParents prints the path of the folder containing each entry named by
+// target, one per line. With ancestors it prints every folder up to the
+// root instead, nearest first, with a blank line between entries.
+func showParents(w io.Writer, database *db.Database, target string, ancestors bool) error {
+	entries, err := resolveEntries(database, target)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		chain := e.Ancestors()
+		if len(chain) == 0 {
+			return fmt.Errorf("%s is the root folder and has no parent", database.FullPath(e))
+		}
+		if !ancestors {
+			chain = chain[:1]
+		} else if i > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, folder := range chain {
+			fmt.Fprintln(w, database.FullPath(&folder.Entry))
+		}
+	}
+	return nil
+}
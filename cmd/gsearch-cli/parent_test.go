@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShowParents(t *testing.T) {
+	database := loadTestDatabase(t)
+
+	tests := []struct {
+		target    string
+		ancestors bool
+		want      string
+	}{
+		{"/home/user/test.txt", false, "/home/user\n"},
+		{"/home/user/test.txt", true, "/home/user\n/home\n/\n"},
+		{"test.txt", false, "/home/user\n"},
+		{"/home", true, "/\n"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := showParents(&out, database, tt.target, tt.ancestors); err != nil {
+			t.Errorf("showParents(%q, %v): %v", tt.target, tt.ancestors, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("showParents(%q, %v) = %q, want %q", tt.target, tt.ancestors, out.String(), tt.want)
+		}
+	}
+
+	var out strings.Builder
+	if err := showParents(&out, database, "/", false); err == nil || !strings.Contains(err.Error(), "root") {
+		t.Errorf("Expected a root folder error, got %v", err)
+	}
+	if err := showParents(&out, database, "/no/such/file", false); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration parses a duration string. In addition to the units accepted
+// by time.ParseDuration it understands "d" (days) and "w" (weeks), e.g. "7d"
+// or "2w". Mixed forms such as "1d12h" are not supported.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return d, nil
+	}
+
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// parseSize parses a byte count with an optional binary unit suffix: K, M,
+// G, or T, optionally followed by B or iB and in either case, e.g. "512",
+// "10K", "1.5MB", or "2GiB". The result is rounded to whole bytes.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.ToUpper(s)
+	num = strings.TrimSuffix(num, "IB")
+	if len(num) == len(s) {
+		num = strings.TrimSuffix(num, "B")
+	}
+
+	unit := int64(1)
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("KMGT", num[n-1]); i >= 0 {
+			unit = int64(1) << (10 * (i + 1))
+			num = num[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 || num == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n*float64(unit) + 0.5), nil
+}
+
+// parseTimeBound parses a -after or -before value: an RFC 3339 timestamp, a
+// date (midnight UTC), or a duration counted back from now, written with or
+// without a leading minus, e.g. "-7d" or "24h"
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := parseFilterTime(s); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(strings.TrimPrefix(s, "-"))
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q: want an RFC 3339 timestamp, a date, or a duration such as -7d", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"1s", time.Second, true},
+		{"500ms", 500 * time.Millisecond, true},
+		{"24h", 24 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"30", 0, false},
+		{"3y", 0, false},
+		{"", 0, false},
+		{"d", 0, false},
+		{"abc", 0, false},
+		{"5x", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.input)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("parseDuration(%q) unexpected error: %v", tt.input, err)
+			} else if got != tt.expected {
+				t.Errorf("parseDuration(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		} else if err == nil {
+			t.Errorf("parseDuration(%q) expected error, got %v", tt.input, got)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		valid    bool
+	}{
+		{"0", 0, true},
+		{"512", 512, true},
+		{"512B", 512, true},
+		{"10K", 10 << 10, true},
+		{"10k", 10 << 10, true},
+		{"1.5M", 3 << 19, true},
+		{"1.5MB", 3 << 19, true},
+		{"2GiB", 2 << 30, true},
+		{"1T", 1 << 40, true},
+		{"", 0, false},
+		{"K", 0, false},
+		{"-1", 0, false},
+		{"10X", 0, false},
+		{"10KK", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.input)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("parseSize(%q) unexpected error: %v", tt.input, err)
+			} else if got != tt.expected {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.expected)
+			}
+		} else if err == nil {
+			t.Errorf("parseSize(%q) expected error, got %d", tt.input, got)
+		}
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+		valid    bool
+	}{
+		{"2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"2024-01-02T03:04:05+02:00", time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC), true},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"-7d", now.AddDate(0, 0, -7), true},
+		{"7d", now.AddDate(0, 0, -7), true},
+		{"-24h", now.Add(-24 * time.Hour), true},
+		{"-90m", now.Add(-90 * time.Minute), true},
+		{"", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+		{"2024-13-01", time.Time{}, false},
+		{"--7d", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.input, now)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("parseTimeBound(%q) unexpected error: %v", tt.input, err)
+			} else if !got.Equal(tt.expected) {
+				t.Errorf("parseTimeBound(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		} else if err == nil {
+			t.Errorf("parseTimeBound(%q) expected error, got %v", tt.input, got)
+		}
+	}
+}
+
+func TestSplitDBPaths(t *testing.T) {
+	got := splitDBPaths([]string{"a.db, b.db", "", "c.db,,"})
+	if strings.Join(got, "|") != "a.db|b.db|c.db" {
+		t.Errorf("splitDBPaths = %q, want a.db, b.db, c.db", got)
+	}
+	if got := splitDBPaths(nil); len(got) != 0 {
+		t.Errorf("splitDBPaths(nil) = %q, want none", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// rsyncFilterRules returns include rules for rsync --filter that select
+// exactly the given entries. rsync only descends into a directory that is
+// itself included, so every ancestor directory gets its own "+ /dir/" rule,
+// emitted before anything inside it. Matched folders are included with all of
+// their contents. A final "- *" excludes everything else.
+func rsyncFilterRules(entries []resultEntry) []string {
+	var rules []string
+	seen := make(map[string]bool)
+	add := func(rule string) {
+		if !seen[rule] {
+			seen[rule] = true
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.Path == "/" {
+			add("+ /***")
+			continue
+		}
+
+		// Ancestor directories, outermost first
+		parts := strings.Split(strings.TrimPrefix(entry.Path, "/"), "/")
+		dir := ""
+		for _, part := range parts[:len(parts)-1] {
+			dir += "/" + part
+			add("+ " + rsyncEscape(dir, false) + "/")
+		}
+
+		if entry.Type == "folder" {
+			add("+ " + rsyncEscape(entry.Path, false) + "/")
+			add("+ " + rsyncEscape(entry.Path, true) + "/***")
+		} else {
+			add("+ " + rsyncEscape(entry.Path, false))
+		}
+	}
+
+	return append(rules, "- *")
+}
+
+// rsyncEscape escapes wildcard characters in a path for use in a filter rule.
+// rsync only treats backslash as an escape when the pattern contains a
+// wildcard, so escaping is applied if the path itself contains one or if the
+// rule will have a wildcard suffix appended.
+func rsyncEscape(path string, wildSuffix bool) string {
+	if !wildSuffix && !strings.ContainsAny(path, "*?[") {
+		return path
+	}
+	var b strings.Builder
+	for _, r := range path {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func printRsyncFilter(w io.Writer, entries []resultEntry) {
+	for _, rule := range rsyncFilterRules(entries) {
+		fmt.Fprintln(w, rule)
+	}
+}
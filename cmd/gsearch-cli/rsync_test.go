@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRsyncFilterRules(t *testing.T) {
+	entries := []resultEntry{
+		{Name: "Documents", Path: "/Documents", Type: "folder"},
+		{Name: "test.txt", Path: "/home/user/test.txt", Type: "file"},
+		{Name: "readme.txt", Path: "/home/user/readme.txt", Type: "file"},
+	}
+
+	want := []string{
+		"+ /Documents/",
+		"+ /Documents/***",
+		"+ /home/",
+		"+ /home/user/",
+		"+ /home/user/test.txt",
+		"+ /home/user/readme.txt",
+		"- *",
+	}
+	if got := rsyncFilterRules(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("rsyncFilterRules() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRsyncFilterRulesAncestorsFirst(t *testing.T) {
+	entries := []resultEntry{
+		{Name: "a.txt", Path: "/x/y/z/a.txt", Type: "file"},
+	}
+	rules := rsyncFilterRules(entries)
+
+	index := make(map[string]int)
+	for i, rule := range rules {
+		index[rule] = i
+	}
+	for _, ancestor := range []string{"+ /x/", "+ /x/y/", "+ /x/y/z/"} {
+		i, ok := index[ancestor]
+		if !ok {
+			t.Fatalf("Expected ancestor rule %q in %v", ancestor, rules)
+		}
+		if i > index["+ /x/y/z/a.txt"] {
+			t.Errorf("Ancestor rule %q must come before the file rule", ancestor)
+		}
+	}
+	if rules[len(rules)-1] != "- *" {
+		t.Errorf("Expected trailing exclude-all rule, got %q", rules[len(rules)-1])
+	}
+}
+
+func TestRsyncFilterRulesEscaping(t *testing.T) {
+	entries := []resultEntry{
+		{Name: "b[1]", Path: "/a*/b[1]", Type: "folder"},
+		{Name: "plain", Path: "/plain", Type: "file"},
+	}
+	want := []string{
+		`+ /a\*/`,
+		`+ /a\*/b\[1]/`,
+		`+ /a\*/b\[1]/***`,
+		"+ /plain",
+		"- *",
+	}
+	if got := rsyncFilterRules(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("rsyncFilterRules() =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// stratifyKey selects how -sample groups results before sampling
+type stratifyKey string
+
+const (
+	stratifyNone stratifyKey = ""
+	stratifyExt  stratifyKey = "ext" // file extension, folders form their own group
+	stratifyDir  stratifyKey = "dir" // parent folder
+)
+
+// stratifyKeys lists the accepted -stratify values
+var stratifyKeys = []stratifyKey{stratifyExt, stratifyDir}
+
+// folderStratum groups folders when stratifying by extension
+const folderStratum = "(folder)"
+
+// sampleItem is a file or folder from a search result, with its position in
+// the result so the sample can be returned in the original order
+type sampleItem struct {
+	file   *db.Entry
+	folder *db.Folder
+	order  int
+}
+
+// stratum is a reservoir of sampled items sharing a key
+type stratum struct {
+	key       string
+	seen      int
+	reservoir []sampleItem
+}
+
+// add offers an item to the reservoir (Algorithm R), keeping a uniform random
+// sample of at most size items out of everything offered
+func (s *stratum) add(item sampleItem, size int, rng *rand.Rand) {
+	s.seen++
+	if len(s.reservoir) < size {
+		s.reservoir = append(s.reservoir, item)
+		return
+	}
+	if j := rng.Intn(s.seen); j < size {
+		s.reservoir[j] = item
+	}
+}
+
+// sampleResults reduces result to at most n randomly chosen entries. Without
+// a stratification key the sample is uniform. With one, entries are grouped
+// by the key and the n slots are spread evenly across groups, so a rare file
+// type is represented as well as a common one; groups too small to fill their
+// share give the remainder to the others.
+func sampleResults(result *db.SearchResult, n int, key stratifyKey, rng *rand.Rand) {
+	if n <= 0 || len(result.Files)+len(result.Folders) <= n {
+		return
+	}
+
+	strata := make(map[string]*stratum)
+	var keys []string
+	offer := func(k string, item sampleItem) {
+		s, ok := strata[k]
+		if !ok {
+			s = &stratum{key: k}
+			strata[k] = s
+			keys = append(keys, k)
+		}
+		s.add(item, n, rng)
+	}
+
+	order := 0
+	for _, folder := range result.Folders {
+		offer(stratumOf(result, &folder.Entry, key), sampleItem{folder: folder, order: order})
+		order++
+	}
+	for _, file := range result.Files {
+		offer(stratumOf(result, file, key), sampleItem{file: file, order: order})
+		order++
+	}
+
+	sort.Strings(keys)
+	quotas := allocateEvenly(keys, strata, n)
+
+	var picked []sampleItem
+	for _, k := range keys {
+		s := strata[k]
+		rng.Shuffle(len(s.reservoir), func(i, j int) {
+			s.reservoir[i], s.reservoir[j] = s.reservoir[j], s.reservoir[i]
+		})
+		picked = append(picked, s.reservoir[:quotas[k]]...)
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].order < picked[j].order })
+
+	result.Files = result.Files[:0]
+	result.Folders = result.Folders[:0]
+	result.Truncated = true
+	for _, item := range picked {
+		if item.folder != nil {
+			result.Folders = append(result.Folders, item.folder)
+		} else {
+			result.Files = append(result.Files, item.file)
+		}
+	}
+}
+
+// allocateEvenly hands out n slots one at a time to each stratum in turn,
+// skipping strata that have no items left
+func allocateEvenly(keys []string, strata map[string]*stratum, n int) map[string]int {
+	quotas := make(map[string]int, len(keys))
+	for n > 0 {
+		progressed := false
+		for _, k := range keys {
+			if n == 0 {
+				break
+			}
+			if quotas[k] < len(strata[k].reservoir) {
+				quotas[k]++
+				n--
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return quotas
+}
+
+// stratumOf returns the group an entry belongs to for the given key
+func stratumOf(result *db.SearchResult, e *db.Entry, key stratifyKey) string {
+	switch key {
+	case stratifyExt:
+		if e.Type == db.EntryTypeFolder {
+			return folderStratum
+		}
+		return strings.ToLower(db.Extension(e.Name))
+	case stratifyDir:
+		if e.Parent == nil {
+			return ""
+		}
+		return result.FullPath(&e.Parent.Entry)
+	default:
+		return ""
+	}
+}
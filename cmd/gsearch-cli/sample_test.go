@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// skewedResult returns 90 .txt files, 5 .go files and 5 .pdf files
+func skewedResult() *db.SearchResult {
+	result := &db.SearchResult{}
+	for i := 0; i < 90; i++ {
+		result.Files = append(result.Files, &db.Entry{Name: fmt.Sprintf("note%02d.txt", i), Type: db.EntryTypeFile})
+	}
+	for i := 0; i < 5; i++ {
+		result.Files = append(result.Files,
+			&db.Entry{Name: fmt.Sprintf("main%d.go", i), Type: db.EntryTypeFile},
+			&db.Entry{Name: fmt.Sprintf("paper%d.PDF", i), Type: db.EntryTypeFile},
+		)
+	}
+	return result
+}
+
+func TestSampleResultsStratifiedByExt(t *testing.T) {
+	result := skewedResult()
+	sampleResults(result, 30, stratifyExt, rand.New(rand.NewSource(1)))
+
+	if len(result.Files) != 30 {
+		t.Fatalf("Expected 30 sampled files, got %d", len(result.Files))
+	}
+	counts := make(map[string]int)
+	for _, file := range result.Files {
+		counts[strings.ToLower(db.Extension(file.Name))]++
+	}
+	// The two small strata are taken whole; .txt gets the remaining slots
+	if counts["go"] != 5 || counts["pdf"] != 5 || counts["txt"] != 20 {
+		t.Errorf("Expected go=5 pdf=5 txt=20, got %v", counts)
+	}
+}
+
+func TestSampleResultsEvenSplit(t *testing.T) {
+	result := skewedResult()
+	sampleResults(result, 6, stratifyExt, rand.New(rand.NewSource(7)))
+
+	counts := make(map[string]int)
+	for _, file := range result.Files {
+		counts[strings.ToLower(db.Extension(file.Name))]++
+	}
+	if counts["go"] != 2 || counts["pdf"] != 2 || counts["txt"] != 2 {
+		t.Errorf("Expected 2 of each extension, got %v", counts)
+	}
+}
+
+func TestSampleResultsStratifiedByDir(t *testing.T) {
+	root := &db.Folder{Entry: db.Entry{Name: "", Type: db.EntryTypeFolder}}
+	big := &db.Folder{Entry: db.Entry{Name: "big", Parent: root, Type: db.EntryTypeFolder}}
+	small := &db.Folder{Entry: db.Entry{Name: "small", Parent: root, Type: db.EntryTypeFolder}}
+
+	result := &db.SearchResult{}
+	for i := 0; i < 50; i++ {
+		result.Files = append(result.Files, &db.Entry{Name: fmt.Sprintf("b%d", i), Parent: big, Type: db.EntryTypeFile})
+	}
+	for i := 0; i < 3; i++ {
+		result.Files = append(result.Files, &db.Entry{Name: fmt.Sprintf("s%d", i), Parent: small, Type: db.EntryTypeFile})
+	}
+
+	sampleResults(result, 10, stratifyDir, rand.New(rand.NewSource(3)))
+
+	counts := make(map[*db.Folder]int)
+	for _, file := range result.Files {
+		counts[file.Parent]++
+	}
+	if counts[small] != 3 || counts[big] != 7 {
+		t.Errorf("Expected small=3 big=7, got small=%d big=%d", counts[small], counts[big])
+	}
+}
+
+func TestSampleResultsUniformKeepsOrder(t *testing.T) {
+	result := skewedResult()
+	original := make(map[*db.Entry]int)
+	for i, file := range result.Files {
+		original[file] = i
+	}
+
+	sampleResults(result, 10, stratifyNone, rand.New(rand.NewSource(5)))
+
+	if len(result.Files) != 10 {
+		t.Fatalf("Expected 10 sampled files, got %d", len(result.Files))
+	}
+	for i := 1; i < len(result.Files); i++ {
+		if original[result.Files[i-1]] > original[result.Files[i]] {
+			t.Error("Expected sampled files to keep their original order")
+		}
+	}
+}
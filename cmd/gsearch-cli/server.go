@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// serverRequest is one line of -server input. Fields mirror the flags of the
+// same name; files and folders restrict the results as -files and -folders
+// do. A request id, of any JSON type, is echoed in the response.
+type serverRequest struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Query   string          `json:"q"`
+	Path    string          `json:"path"`
+	Case    bool            `json:"case"`
+	Whole   bool            `json:"whole"`
+	Files   bool            `json:"files"`
+	Folders bool            `json:"folders"`
+	Max     int             `json:"max"`
+	Sort    string          `json:"sort"`
+	Desc    bool            `json:"desc"`
+}
+
+// serverResponse is one line of -server output. Error is set instead of the
+// results when the request could not be run.
+type serverResponse struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	Results   []resultEntry   `json:"results"`
+	Count     int             `json:"count"`
+	Truncated bool            `json:"truncated"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// serve answers newline-delimited JSON requests from r with one JSON line
+// each on w until r is exhausted. The database is loaded once by the
+// caller, so each query costs only the search. A malformed request gets an
+// error response rather than ending the session.
+func serve(r io.Reader, w io.Writer, database *db.Database, localeName string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req serverRequest
+		var resp serverResponse
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			resp = serverResponse{Results: []resultEntry{}, Error: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			resp = answer(database, localeName, req)
+		}
+		resp.ID = req.ID
+
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		// Flush per response: clients wait for each answer before asking again
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// answer runs a single -server request
+func answer(database *db.Database, localeName string, req serverRequest) serverResponse {
+	fail := func(format string, args ...any) serverResponse {
+		return serverResponse{Results: []resultEntry{}, Error: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case req.Query == "" && req.Path == "":
+		return fail("request needs q or path")
+	case req.Query != "" && req.Path != "":
+		return fail("request cannot have both q and path")
+	case req.Files && req.Folders:
+		return fail("files and folders cannot both be set")
+	case req.Max < 0:
+		return fail("max must not be negative")
+	case req.Desc && req.Sort == "":
+		return fail("desc requires sort")
+	}
+	field := sortField(strings.ToLower(req.Sort))
+	if req.Sort != "" && !oneOf(field, sortFields) {
+		return fail("invalid sort field %q. Must be: %s", req.Sort, choiceList(sortFields))
+	}
+	loc, err := newLocale(localeName, req.Case, true)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	var result *db.SearchResult
+	if req.Path != "" {
+		result, err = database.SearchPath(db.PathSearchOptions{
+			Pattern:       req.Path,
+			CaseSensitive: req.Case,
+			Fold:          loc.fold,
+		})
+		if err != nil {
+			return fail("%v", err)
+		}
+		// Path search has no type filter of its own
+		if req.Files {
+			result.Folders = nil
+		}
+		if req.Folders {
+			result.Files = nil
+		}
+	} else {
+		result, err = database.Search(db.SearchOptions{
+			Query:           req.Query,
+			CaseSensitive:   req.Case,
+			MatchWholeWord:  req.Whole,
+			Fold:            loc.fold,
+			SearchInFiles:   !req.Folders,
+			SearchInFolders: !req.Files,
+			MaxResults:      req.Max,
+			Score:           field == sortFieldScore,
+		})
+		if err != nil {
+			return fail("%v", err)
+		}
+	}
+	if req.Sort != "" {
+		sortResultsIndexed(database, result, field, req.Desc, loc.coll)
+	}
+
+	entries := collectEntries(result, outputOptions{})
+	return serverResponse{
+		Results:   entries,
+		Count:     len(entries),
+		Truncated: result.Truncated,
+	}
+}
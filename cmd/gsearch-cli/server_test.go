@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	database := loadTestDatabase(t)
+	requests := strings.Join([]string{
+		`{"id":1,"q":"txt"}`,
+		``,
+		`{"id":"two","q":"*","files":true,"sort":"size","desc":true}`,
+		`{"id":3,"path":"/home/*","folders":true}`,
+		`{"id":4,"q":"txt","bogus":true}`,
+		`not json`,
+		`{"id":6}`,
+		`{"id":7,"q":"nothing-matches-this"}`,
+	}, "\n") + "\n"
+
+	var out strings.Builder
+	if err := serve(strings.NewReader(requests), &out, database, "und"); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+
+	var responses []serverResponse
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var resp serverResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("Response is not valid JSON: %v\n%s", err, scanner.Text())
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 7 {
+		t.Fatalf("Expected one response per non-blank request (7), got %d:\n%s", len(responses), out.String())
+	}
+
+	names := func(resp serverResponse) string {
+		var out []string
+		for _, e := range resp.Results {
+			out = append(out, e.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if r := responses[0]; string(r.ID) != "1" || r.Error != "" || r.Count != 2 || names(r) != "test.txt,readme.txt" {
+		t.Errorf("Request 1: unexpected response %+v", r)
+	}
+	if r := responses[1]; string(r.ID) != `"two"` || r.Count != 5 || names(r) != "file.zip,test.go,document.pdf,readme.txt,test.txt" {
+		t.Errorf("Request two: expected files largest first, got %s (%+v)", names(r), r)
+	}
+	if r := responses[2]; r.Error != "" || names(r) != "user" {
+		t.Errorf("Request 3: expected only the user folder, got %s (%+v)", names(r), r)
+	}
+	for i, want := range map[int]string{3: "unknown field", 4: "invalid request", 5: "needs q or path"} {
+		if r := responses[i]; !strings.Contains(r.Error, want) || r.Count != 0 {
+			t.Errorf("Response %d: expected error containing %q, got %+v", i, want, r)
+		}
+	}
+	if r := responses[6]; r.Error != "" || r.Count != 0 || r.Results == nil {
+		t.Errorf("Request 7: expected an empty result list, got %+v", r)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// sunburstNode is one folder in the hierarchy emitted by -output sunburst-json.
+// Size is the aggregate size of all files beneath the folder, so a node's size
+// is at least the sum of its children's sizes.
+type sunburstNode struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path"`
+	Size     int64           `json:"size"`
+	Children []*sunburstNode `json:"children,omitempty"`
+}
+
+// buildSunburst builds a folder tree rooted at the matched folders. Folders
+// nested inside another matched folder are reached through their ancestor
+// rather than repeated at the top level. Folders deeper than maxDepth
+// (counted from the database root, 0 = unlimited) are omitted, though their
+// sizes still count towards their ancestors.
+func buildSunburst(database *db.Database, folders []*db.Folder, maxDepth int) *sunburstNode {
+	matched := make(map[*db.Folder]bool, len(folders))
+	for _, folder := range folders {
+		matched[folder] = true
+	}
+
+	root := &sunburstNode{Name: "", Path: ""}
+	for _, folder := range folders {
+		if hasMatchedAncestor(folder, matched) {
+			continue
+		}
+		if maxDepth > 0 && folder.Depth() > maxDepth {
+			continue
+		}
+		node := buildSunburstNode(database, folder, maxDepth)
+		root.Size += node.Size
+		root.Children = append(root.Children, node)
+	}
+	return root
+}
+
+func buildSunburstNode(database *db.Database, folder *db.Folder, maxDepth int) *sunburstNode {
+	node := &sunburstNode{
+		Name: folder.Name,
+		Path: database.FullPath(&folder.Entry),
+		Size: database.FolderSize(folder),
+	}
+	if maxDepth > 0 && folder.Depth() >= maxDepth {
+		return node
+	}
+	subfolders, _ := database.Children(folder)
+	for _, sub := range subfolders {
+		node.Children = append(node.Children, buildSunburstNode(database, sub, maxDepth))
+	}
+	return node
+}
+
+func hasMatchedAncestor(folder *db.Folder, matched map[*db.Folder]bool) bool {
+	for p := folder.Parent; p != nil; p = p.Parent {
+		if matched[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func printSunburst(w io.Writer, database *db.Database, result *db.SearchResult, maxDepth int) error {
+	tree := buildSunburst(database, result.Folders, maxDepth)
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
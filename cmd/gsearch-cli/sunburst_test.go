@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// loadTestDatabase creates and loads the standard test database
+func loadTestDatabase(t *testing.T) *db.Database {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	if err := db.CreateTestDatabase(dbPath); err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	database, err := db.Load(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	return database
+}
+
+// mustSearch runs database.Search, failing the test on an error
+func mustSearch(t testing.TB, database *db.Database, opts db.SearchOptions) *db.SearchResult {
+	t.Helper()
+	result, err := database.Search(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestBuildSunburst(t *testing.T) {
+	database := loadTestDatabase(t)
+	home, user, documents := database.Folders[1], database.Folders[2], database.Folders[3]
+
+	// user is nested in home, so it must only appear beneath home
+	tree := buildSunburst(database, []*db.Folder{home, user, documents}, 0)
+
+	if len(tree.Children) != 2 {
+		t.Fatalf("Expected 2 top-level nodes, got %d", len(tree.Children))
+	}
+	if tree.Size != 1024+2048+4096+8192 {
+		t.Errorf("Expected root size %d, got %d", 1024+2048+4096+8192, tree.Size)
+	}
+
+	homeNode := tree.Children[0]
+	if homeNode.Path != "/home" || homeNode.Size != 1024+2048 {
+		t.Errorf("Expected /home with size 3072, got %s with size %d", homeNode.Path, homeNode.Size)
+	}
+	if len(homeNode.Children) != 1 || homeNode.Children[0].Path != "/home/user" {
+		t.Fatalf("Expected /home/user beneath /home, got %+v", homeNode.Children)
+	}
+	if homeNode.Children[0].Size != 1024+2048 {
+		t.Errorf("Expected /home/user size 3072, got %d", homeNode.Children[0].Size)
+	}
+
+	docNode := tree.Children[1]
+	if docNode.Path != "/Documents" || docNode.Size != 4096+8192 {
+		t.Errorf("Expected /Documents with size 12288, got %s with size %d", docNode.Path, docNode.Size)
+	}
+}
+
+func TestBuildSunburstMaxDepth(t *testing.T) {
+	database := loadTestDatabase(t)
+	root := database.Folders[0]
+
+	tree := buildSunburst(database, []*db.Folder{root}, 1)
+
+	rootNode := tree.Children[0]
+	if len(rootNode.Children) != 3 {
+		t.Fatalf("Expected 3 children of root, got %d", len(rootNode.Children))
+	}
+	for _, child := range rootNode.Children {
+		if len(child.Children) != 0 {
+			t.Errorf("Expected %s to be cut off at depth 1, got %d children", child.Path, len(child.Children))
+		}
+	}
+	// Sizes below the cut-off still count
+	if rootNode.Children[0].Size != 1024+2048 {
+		t.Errorf("Expected /home size 3072, got %d", rootNode.Children[0].Size)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// templateEscapes turns the escapes a -template-str is likely to contain
+// into the characters they stand for, since a shell passes '\t' through
+// as a backslash and a t
+var templateEscapes = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\0`, "\x00")
+
+// parseOutputTemplate parses the -output template layout, given either as
+// text with backslash escapes or as the path of a file read as it is. The
+// template is tried on an empty entry so that a reference to a field that
+// does not exist fails here, before any result is printed.
+func parseOutputTemplate(text, file string) (*template.Template, error) {
+	name := "-template-str"
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text, name = string(data), file
+	} else {
+		text = templateEscapes.Replace(text)
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, resultEntry{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// SizeHuman returns the size as the text listing shows it by default, e.g.
+// "1.5 MiB", for use in -output template as {{.SizeHuman}}
+func (e resultEntry) SizeHuman() string {
+	return formatSize(e.Size, unitsBinary)
+}
+
+// printTemplate writes each entry through tmpl, each followed by a newline
+func printTemplate(w io.Writer, entries []resultEntry, tmpl *template.Template) {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		writeTemplateRecord(bw, entry, tmpl)
+	}
+	bw.Flush()
+}
+
+// writeTemplateRecord writes a single entry through tmpl and a newline
+func writeTemplateRecord(w io.Writer, entry resultEntry, tmpl *template.Template) {
+	if err := tmpl.Execute(w, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to execute template: %v\n", err)
+		os.Exit(1)
+	}
+	io.WriteString(w, "\n")
+}
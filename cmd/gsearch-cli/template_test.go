@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+func TestOutputTemplate(t *testing.T) {
+	result := mustSearch(t, loadTestDatabase(t), db.SearchOptions{Query: "txt", SearchInFiles: true})
+	sortResults(result, sortFieldSize, false, nil)
+
+	tmpl, err := parseOutputTemplate(`{{.Path}}\t{{.SizeHuman}}\t{{.Type}}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	printResults(&out, result, outputFormatTemplate, outputOptions{template: tmpl})
+	want := "/home/user/test.txt\t1.0 KiB\tfile\n/home/user/readme.txt\t2.0 KiB\tfile\n"
+	if out.String() != want {
+		t.Errorf("Template output = %q, want %q", out.String(), want)
+	}
+
+	// A file is taken as it is, escapes and all
+	file := filepath.Join(t.TempDir(), "line.tmpl")
+	if err := os.WriteFile(file, []byte(`{{.Name}}\t{{if eq .Type "file"}}{{.Size}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err = parseOutputTemplate("", file); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	printResults(&out, result, outputFormatTemplate, outputOptions{template: tmpl})
+	if want := "test.txt\\t1024\nreadme.txt\\t2048\n"; out.String() != want {
+		t.Errorf("Template file output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestOutputTemplateErrors(t *testing.T) {
+	for _, text := range []string{`{{.Path`, `{{.Owner}}`, `{{nosuchfunc .Path}}`} {
+		if _, err := parseOutputTemplate(text, ""); err == nil {
+			t.Errorf("parseOutputTemplate(%q) expected error", text)
+		}
+	}
+	if _, err := parseOutputTemplate("", filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Expected error for a missing template file")
+	}
+}
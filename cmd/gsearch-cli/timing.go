@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// phaseTiming is the duration of one named phase of a run
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// phaseTimer collects phase durations for -verbose. A disabled timer records
+// nothing, so callers don't need to guard each measurement.
+type phaseTimer struct {
+	enabled bool
+	phases  []phaseTiming
+}
+
+// since records the time elapsed since start under name
+func (t *phaseTimer) since(name string, start time.Time) {
+	if t.enabled {
+		t.phases = append(t.phases, phaseTiming{name: name, duration: time.Since(start)})
+	}
+}
+
+// addLoad records the phases measured while loading a database
+func (t *phaseTimer) addLoad(timings db.LoadTimings) {
+	if !t.enabled {
+		return
+	}
+	t.phases = append(t.phases,
+		phaseTiming{"open", timings.Open},
+		phaseTiming{"header/metadata", timings.Header},
+		phaseTiming{"folder block", timings.Folders},
+		phaseTiming{"file block", timings.Files},
+		phaseTiming{"sorted arrays", timings.SortedArrays},
+	)
+}
+
+// report writes the timing breakdown to w
+func (t *phaseTimer) report(w io.Writer) {
+	if !t.enabled {
+		return
+	}
+	var total time.Duration
+	fmt.Fprintf(w, "Timing:\n")
+	for _, p := range t.phases {
+		fmt.Fprintf(w, "  %-16s %v\n", p.name+":", p.duration)
+		total += p.duration
+	}
+	fmt.Fprintf(w, "  %-16s %v\n", "total:", total)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimerReport(t *testing.T) {
+	database := loadTestDatabase(t)
+
+	timer := &phaseTimer{enabled: true}
+	timer.addLoad(database.Timings)
+	timer.since("search", time.Now())
+	timer.since("sort", time.Now())
+	timer.since("output", time.Now())
+
+	var stderr strings.Builder
+	timer.report(&stderr)
+
+	for _, label := range []string{
+		"open:", "header/metadata:", "folder block:", "file block:",
+		"sorted arrays:", "search:", "sort:", "output:", "total:",
+	} {
+		if !strings.Contains(stderr.String(), label) {
+			t.Errorf("Expected timing report to contain %q, got:\n%s", label, stderr.String())
+		}
+	}
+}
+
+func TestPhaseTimerDisabled(t *testing.T) {
+	timer := &phaseTimer{}
+	timer.since("search", time.Now())
+
+	var stderr strings.Builder
+	timer.report(&stderr)
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no output from disabled timer, got %q", stderr.String())
+	}
+}
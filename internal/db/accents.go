@@ -0,0 +1,46 @@
+package db
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stripAccents removes combining marks from s, so "café" becomes "cafe".
+// The text is decomposed (NFD) first so precomposed letters give up their
+// marks, then recomposed. Letters that are distinct rather than accented,
+// such as ø or ß, are kept.
+func stripAccents(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	// Transformers carry state, so each call gets its own chain
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+// normalizeNFC returns s in Unicode normalization form C, so a name stored
+// decomposed, as macOS stores them, compares equal to the same name typed
+// with precomposed letters
+func normalizeNFC(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return norm.NFC.String(s)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UnreachableReason explains why an entry cannot be traced back to a root
+type UnreachableReason string
+
+const (
+	// ReasonMissingParent means an entry in the chain points to a parent
+	// index that does not exist
+	ReasonMissingParent UnreachableReason = "missing parent"
+	// ReasonParentCycle means the parent chain loops back on itself
+	ReasonParentCycle UnreachableReason = "parent cycle"
+)
+
+// UnreachableEntry is an entry whose parent chain never reaches a root folder
+type UnreachableEntry struct {
+	Entry *Entry
+	// PartialPath is the path as far as it could be resolved, prefixed with
+	// "?" where the missing ancestors would be
+	PartialPath string
+	Reason      UnreachableReason
+}
+
+// Unreachable returns all folders and files whose parent chain is broken,
+// either by a dangling parent index or by a cycle. Such entries indicate a
+// corrupt database; GetFullPath cannot produce a meaningful path for them.
+func (db *Database) Unreachable() []UnreachableEntry {
+	var broken []UnreachableEntry
+	for _, folder := range db.Folders {
+		if u, ok := checkReachable(&folder.Entry); !ok {
+			broken = append(broken, u)
+		}
+	}
+	for _, file := range db.Files {
+		if u, ok := checkReachable(file); !ok {
+			broken = append(broken, u)
+		}
+	}
+	return broken
+}
+
+// checkReachable walks the ancestors of e. It reports false, together with
+// the partial path and reason, if the walk ends at a dangling parent or
+// revisits a folder.
+func checkReachable(e *Entry) (UnreachableEntry, bool) {
+	components := []string{e.Name}
+	visited := map[*Entry]bool{e: true}
+
+	reason := UnreachableReason("")
+	if e.brokenParent {
+		reason = ReasonMissingParent
+	}
+	for p := e.Parent; p != nil && reason == ""; p = p.Parent {
+		if visited[&p.Entry] {
+			reason = ReasonParentCycle
+			break
+		}
+		visited[&p.Entry] = true
+		components = append(components, p.Name)
+		if p.brokenParent {
+			reason = ReasonMissingParent
+		}
+	}
+	if reason == "" {
+		return UnreachableEntry{}, true
+	}
+
+	// Components were collected leaf-first
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	return UnreachableEntry{
+		Entry:       e,
+		PartialPath: "?/" + strings.Join(components, "/"),
+		Reason:      reason,
+	}, false
+}
+
+// Staleness summarises how far the files in a database have moved on since
+// a given time, normally when the database was written
+type Staleness struct {
+	Newest  time.Time // latest file modification time
+	Newer   int       // files modified after the reference time
+	Indexed bool      // whether the database records modification times
+}
+
+// StalenessSince finds the newest file modification time and counts the
+// files modified after indexed, normally the database file's own mtime.
+// Without IndexFlagModificationTime there is nothing to compare and only
+// Indexed is set, to false.
+func (db *Database) StalenessSince(indexed time.Time) Staleness {
+	s := Staleness{Indexed: db.IndexFlags&IndexFlagModificationTime != 0}
+	if !s.Indexed {
+		return s
+	}
+	for _, file := range db.Files {
+		if file.MTime.After(s.Newest) {
+			s.Newest = file.MTime
+		}
+		if file.MTime.After(indexed) {
+			s.Newer++
+		}
+	}
+	return s
+}
+
+// Verify cross-checks the structure of the database and returns every
+// problem it finds, or nil if there are none: parent indices out of range,
+// folder parent chains that loop, and sorted arrays that are not an ordering
+// of every entry. Entry counts and block sizes that disagree with the header
+// need no check here, as Load already fails on them.
+func (db *Database) Verify() []error {
+	var problems []error
+	for _, folder := range db.Folders {
+		if folder.brokenParent {
+			problems = append(problems, fmt.Errorf("folder %d %q: parent index out of range", folder.Index, folder.Name))
+		}
+	}
+	for _, file := range db.Files {
+		if file.brokenParent {
+			problems = append(problems, fmt.Errorf("file %d %q: parent index out of range", file.Index, file.Name))
+		}
+	}
+	problems = append(problems, db.parentCycles()...)
+
+	for _, s := range db.CheckSortedArrays() {
+		if !s.Valid {
+			problems = append(problems, fmt.Errorf("sorted array %d: %s", s.ID, s.Problem))
+		}
+	}
+	return problems
+}
+
+// parentCycles reports each loop in the folder hierarchy once, naming the
+// folder at which a walk up the parents first came back on itself
+func (db *Database) parentCycles() []error {
+	const (
+		walking = 1
+		done    = 2
+	)
+	state := make(map[*Folder]int, len(db.Folders))
+	var problems []error
+	for _, start := range db.Folders {
+		var walked []*Folder
+		for f := start; f != nil && state[f] != done; f = f.Parent {
+			if state[f] == walking {
+				problems = append(problems, fmt.Errorf("folder %d %q: parent chain loops back to it", f.Index, f.Name))
+				break
+			}
+			state[f] = walking
+			walked = append(walked, f)
+		}
+		for _, f := range walked {
+			state[f] = done
+		}
+	}
+	return problems
+}
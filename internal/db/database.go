@@ -1,7 +1,10 @@
 package db
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -31,6 +34,38 @@ const (
 	IndexFlagStatusChangeTime  IndexFlags = 1 << 6
 )
 
+var indexFlagNames = []struct {
+	flag IndexFlags
+	name string
+}{
+	{IndexFlagName, "name"},
+	{IndexFlagPath, "path"},
+	{IndexFlagSize, "size"},
+	{IndexFlagModificationTime, "mtime"},
+	{IndexFlagAccessTime, "atime"},
+	{IndexFlagCreationTime, "ctime"},
+	{IndexFlagStatusChangeTime, "status-change-time"},
+}
+
+// String lists the set flags by name, such as "name|size|mtime". Bits with
+// no known meaning are shown in hex so that nothing is hidden.
+func (f IndexFlags) String() string {
+	var names []string
+	for _, n := range indexFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint64(f)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
 // EntryType represents the type of database entry
 type EntryType uint8
 
@@ -48,13 +83,27 @@ type Entry struct {
 	Parent   *Folder
 	Index    uint32
 	Type     EntryType
+
+	// ATime, CTime (creation), and StatusChangeTime are only set when the
+	// database indexes them
+	ATime            time.Time
+	CTime            time.Time
+	StatusChangeTime time.Time
+
+	// brokenParent is set when the on-disk parent index is out of range, so
+	// Parent is nil even though the entry is not a root
+	brokenParent bool
 }
 
 // Folder represents a folder entry with additional metadata
 type Folder struct {
 	Entry
-	DBIndex   uint32
-	NumFiles  uint32
+	// DBIndex is FSearch's db_index for the folder, which tells the
+	// indexed locations of a database apart, so folders share it
+	DBIndex uint32
+	// NumFiles and NumFolders count the folder's direct children. FSearch
+	// does not store them, so Load counts them from the parent indices.
+	NumFiles   uint32
 	NumFolders uint32
 }
 
@@ -64,8 +113,24 @@ type Database struct {
 	Folders      []*Folder
 	Files        []*Entry
 	SortedArrays map[uint32]*SortedArray
-	metadata     metadata
-	pathCache    sync.Map // map[*Entry]string - caches computed paths for performance
+	Timings      LoadTimings
+	// UnknownVersion is set when the file declares a newer minor version
+	// than MinorVersion. It loaded, but anything the newer format added is
+	// not understood.
+	UnknownVersion bool
+	metadata       metadata
+	pathCache      *pathCache // LRU of computed paths, see SetPathCacheSize
+	pathCacheOnce  sync.Once  // creates pathCache if Load or Merge did not
+	tree           treeIndex
+}
+
+// LoadTimings records how long each phase of Load took
+type LoadTimings struct {
+	Open         time.Duration // opening the file
+	Header       time.Duration // header and metadata
+	Folders      time.Duration // folder block parse
+	Files        time.Duration // file block parse
+	SortedArrays time.Duration // sorted array load
 }
 
 // SortedArray contains pre-sorted indices for efficient searching
@@ -73,22 +138,81 @@ type SortedArray struct {
 	ID       uint32
 	Folders  []uint32 // Indices into Folders array
 	Files    []uint32 // Indices into Files array
+
+	// Whether the orderings cover every entry, checked once by SortOrder
+	checkOnce sync.Once
+	valid     bool
 }
 
-// Load opens and reads an FSearch database file
-func Load(filePath string) (*Database, error) {
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// databaseFile is what a database is read through: the open file itself,
+// or the decompressed contents of a gzipped one
+type databaseFile interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+}
+
+// openDatabase opens the database at filePath for reading. A file that
+// starts with the gzip magic number, such as an archived fsearch.db.gz, is
+// decompressed whole into memory first, since the loaders seek and read at
+// offsets that a gzip stream cannot provide. The returned close function
+// releases the file.
+func openDatabase(filePath string) (databaseFile, func() error, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open database file: %w", err)
+	}
+
+	magic := make([]byte, len(gzipMagic))
+	if n, _ := file.ReadAt(magic, 0); n < len(magic) || !bytes.Equal(magic, gzipMagic) {
+		return file, file.Close, nil
 	}
 	defer file.Close()
 
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress database: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil, fmt.Errorf("failed to decompress database: gzip stream is truncated: %w", err)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress database: %w", err)
+	}
+	return bytes.NewReader(data), func() error { return nil }, nil
+}
+
+// Load opens and reads an FSearch database file. Gzip-compressed files are
+// decompressed transparently.
+func Load(filePath string) (*Database, error) {
+	var timings LoadTimings
+	phaseStart := time.Now()
+	// lap returns the time since the previous lap and starts the next phase
+	lap := func() time.Duration {
+		now := time.Now()
+		d := now.Sub(phaseStart)
+		phaseStart = now
+		return d
+	}
+
+	file, closeFile, err := openDatabase(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	timings.Open = lap()
+
 	// Try to acquire lock (non-blocking)
 	// Note: On Linux, we'd use syscall.Flock, but for portability we'll skip locking for read-only access
 	// The original code uses flock() with LOCK_EX|LOCK_NB, but for read-only we can proceed
 
 	db := &Database{
 		SortedArrays: make(map[uint32]*SortedArray),
+		pathCache:    newPathCache(DefaultPathCacheSize),
 	}
 
 	// Read and verify header
@@ -100,6 +224,7 @@ func Load(filePath string) (*Database, error) {
 	if err := db.readMetadata(file); err != nil {
 		return nil, err
 	}
+	timings.Header = lap()
 
 	// Pre-allocate folders
 	db.Folders = make([]*Folder, db.metadata.numFolders)
@@ -116,21 +241,96 @@ func Load(filePath string) (*Database, error) {
 	if err := db.loadFolders(file); err != nil {
 		return nil, err
 	}
+	timings.Folders = lap()
 
 	// Load files
 	if err := db.loadFiles(file); err != nil {
 		return nil, err
 	}
+	db.countChildren()
+	timings.Files = lap()
 
 	// Load sorted arrays
 	if err := db.loadSortedArrays(file); err != nil {
 		return nil, err
 	}
+	timings.SortedArrays = lap()
 
+	db.Timings = timings
 	return db, nil
 }
 
+// retrySleep waits between LoadWithRetry attempts. Tests replace it to
+// change the file between attempts without racing a timer.
+var retrySleep = time.Sleep
+
+// LoadWithRetry calls Load, retrying up to retries additional times with
+// interval between attempts. fsearch rewrites its database in place while
+// re-indexing, so a read that races the writer can fail transiently and
+// succeed moments later.
+func LoadWithRetry(filePath string, retries int, interval time.Duration) (*Database, error) {
+	db, err := Load(filePath)
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		retrySleep(interval)
+		db, err = Load(filePath)
+	}
+	if err != nil && retries > 0 {
+		return nil, fmt.Errorf("giving up after %d attempts: %w", retries+1, err)
+	}
+	return db, err
+}
+
+// Metadata describes a database file as declared by its header, without
+// reading any entries
+type Metadata struct {
+	Magic           string
+	MajorVersion    uint8
+	MinorVersion    uint8
+	IndexFlags      IndexFlags
+	NumFolders      uint32
+	NumFiles        uint32
+	FolderBlockSize uint64
+	FileBlockSize   uint64
+	NumIndexes      uint32
+	NumExcludes     uint32
+}
+
+// LoadMetadata reads only the header and metadata of the database at
+// filePath. It is far cheaper than Load and validates the same magic number
+// and version, so it doubles as a quick check of what a file is.
+func LoadMetadata(filePath string) (*Metadata, error) {
+	file, closeFile, err := openDatabase(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	db := &Database{}
+	if err := db.readHeader(file); err != nil {
+		return nil, err
+	}
+	if err := db.readMetadata(file); err != nil {
+		return nil, err
+	}
+
+	m := db.metadata
+	return &Metadata{
+		Magic:           MagicNumber,
+		MajorVersion:    m.majorVersion,
+		MinorVersion:    m.minorVersion,
+		IndexFlags:      m.indexFlags,
+		NumFolders:      m.numFolders,
+		NumFiles:        m.numFiles,
+		FolderBlockSize: m.folderBlockSize,
+		FileBlockSize:   m.fileBlockSize,
+		NumIndexes:      m.numIndexes,
+		NumExcludes:     m.numExcludes,
+	}, nil
+}
+
 type metadata struct {
+	majorVersion    uint8
+	minorVersion    uint8
 	indexFlags      IndexFlags
 	numFolders      uint32
 	numFiles        uint32
@@ -161,15 +361,17 @@ func (db *Database) readHeader(r io.Reader) error {
 	if err := binary.Read(r, binary.LittleEndian, &minorVer); err != nil {
 		return fmt.Errorf("failed to read minor version: %w", err)
 	}
-	if minorVer > MinorVersion {
-		return fmt.Errorf("unsupported minor version: got %d, expected <= %d", minorVer, MinorVersion)
-	}
+	// Minor versions keep the header and record layout, so a newer one is
+	// read as far as it is understood rather than refused
+	db.UnknownVersion = minorVer > MinorVersion
 
+	db.metadata.majorVersion = majorVer
+	db.metadata.minorVersion = minorVer
 	return nil
 }
 
 func (db *Database) readMetadata(r io.Reader) error {
-	var meta metadata
+	meta := &db.metadata
 
 	if err := binary.Read(r, binary.LittleEndian, &meta.indexFlags); err != nil {
 		return fmt.Errorf("failed to read index flags: %w", err)
@@ -200,11 +402,29 @@ func (db *Database) readMetadata(r io.Reader) error {
 	}
 
 	db.IndexFlags = meta.indexFlags
-	db.metadata = meta
 
 	return nil
 }
 
+// extraTimes are the timestamps a record holds after its mtime, in their
+// on-disk order, each present only when the database indexes it
+var extraTimes = []struct {
+	flag  IndexFlags
+	name  string
+	entry func(*Entry) *time.Time
+	raw   func(*RawRecord) *int64
+}{
+	{IndexFlagAccessTime, "atime",
+		func(e *Entry) *time.Time { return &e.ATime },
+		func(r *RawRecord) *int64 { return &r.ATime }},
+	{IndexFlagCreationTime, "ctime",
+		func(e *Entry) *time.Time { return &e.CTime },
+		func(r *RawRecord) *int64 { return &r.CTime }},
+	{IndexFlagStatusChangeTime, "status change time",
+		func(e *Entry) *time.Time { return &e.StatusChangeTime },
+		func(r *RawRecord) *int64 { return &r.StatusChangeTime }},
+}
+
 func (db *Database) loadFolders(r io.Reader) error {
 	// Read the entire folder block into memory
 	folderBlock := make([]byte, db.metadata.folderBlockSize)
@@ -222,7 +442,7 @@ func (db *Database) loadFolders(r io.Reader) error {
 		if offset+2 > len(folderBlock) {
 			return fmt.Errorf("folder block truncated at folder %d", i)
 		}
-		// db_index is currently unused, skip it
+		folder.DBIndex = uint32(binary.LittleEndian.Uint16(folderBlock[offset:]))
 		offset += 2
 
 		// Read name using delta compression
@@ -254,6 +474,18 @@ func (db *Database) loadFolders(r io.Reader) error {
 			offset += 8
 		}
 
+		// Read the other timestamps that are indexed
+		for _, t := range extraTimes {
+			if db.IndexFlags&t.flag == 0 {
+				continue
+			}
+			if offset+8 > len(folderBlock) {
+				return fmt.Errorf("folder block truncated at folder %d (%s)", i, t.name)
+			}
+			*t.entry(&folder.Entry) = time.Unix(int64(binary.LittleEndian.Uint64(folderBlock[offset:])), 0)
+			offset += 8
+		}
+
 		// Read parent index
 		if offset+4 > len(folderBlock) {
 			return fmt.Errorf("folder block truncated at folder %d (parent)", i)
@@ -264,6 +496,8 @@ func (db *Database) loadFolders(r io.Reader) error {
 		// Set parent (if not self-reference)
 		if parentIdx != folder.Index && parentIdx < uint32(len(db.Folders)) {
 			folder.Parent = db.Folders[parentIdx]
+		} else if parentIdx != folder.Index {
+			folder.brokenParent = true
 		}
 	}
 
@@ -319,6 +553,18 @@ func (db *Database) loadFiles(r io.Reader) error {
 			offset += 8
 		}
 
+		// Read the other timestamps that are indexed
+		for _, t := range extraTimes {
+			if db.IndexFlags&t.flag == 0 {
+				continue
+			}
+			if offset+8 > len(fileBlock) {
+				return fmt.Errorf("file block truncated at file %d (%s)", i, t.name)
+			}
+			*t.entry(entry) = time.Unix(int64(binary.LittleEndian.Uint64(fileBlock[offset:])), 0)
+			offset += 8
+		}
+
 		// Read parent index
 		if offset+4 > len(fileBlock) {
 			return fmt.Errorf("file block truncated at file %d (parent)", i)
@@ -329,6 +575,8 @@ func (db *Database) loadFiles(r io.Reader) error {
 		// Set parent
 		if parentIdx < uint32(len(db.Folders)) {
 			entry.Parent = db.Folders[parentIdx]
+		} else {
+			entry.brokenParent = true
 		}
 
 		db.Files[i] = entry
@@ -341,7 +589,11 @@ func (db *Database) loadFiles(r io.Reader) error {
 	return nil
 }
 
-// readDeltaName reads a delta-compressed name from the block
+// readDeltaName reads a delta-compressed name from the block. FSearch stores
+// the length of the prefix shared with the previous name and the length of
+// the suffix that follows as one byte each, as Linux names are at most 255
+// bytes; a name can still be longer than that when it shares a prefix with
+// the one before it.
 func (db *Database) readDeltaName(block []byte, offset int, previousName string) (string, int, error) {
 	if offset+2 > len(block) {
 		return "", offset, fmt.Errorf("block truncated at name header")
@@ -351,13 +603,11 @@ func (db *Database) readDeltaName(block []byte, offset int, previousName string)
 	nameLen := block[offset+1]
 	offset += 2
 
-	// Truncate previous name at offset
-	var name string
-	if int(nameOffset) < len(previousName) {
-		name = previousName[:nameOffset]
-	} else {
-		name = previousName
+	// Keep the shared prefix of the previous name
+	if int(nameOffset) > len(previousName) {
+		return "", offset, fmt.Errorf("name shares %d bytes with a previous name of %d bytes", nameOffset, len(previousName))
 	}
+	name := previousName[:nameOffset]
 
 	// Append new characters
 	if nameLen > 0 {
@@ -406,101 +656,127 @@ func (db *Database) loadSortedArrays(r io.Reader) error {
 }
 
 // GetFullPath returns the full path of an entry by traversing parent folders.
-// Paths are cached after first computation for performance.
+// Nothing is cached, since an entry cannot reach its database; code that
+// has the database should call Database.FullPath instead.
 func (e *Entry) GetFullPath() string {
-	// Check cache first (if we have access to the database)
-	// Note: We can't access the database from Entry, so we'll cache at the Entry level
-	// For now, we'll use a simpler approach: cache in the Entry itself if it has a parent reference
-	
-	// For entries without parent, return immediately (no caching needed)
-	if e.Parent == nil {
-		if e.Name == "" {
-			return "/"
-		}
-		return e.Name
+	// Names from e up to, but not including, its topmost ancestor
+	components := make([]string, 0, 10)
+	top := e
+	for top.Parent != nil {
+		components = append(components, top.Name)
+		top = &top.Parent.Entry
 	}
 
-	// Check if parent's path is cached (we'll use a different approach)
-	// Since Entry doesn't have direct access to Database, we'll optimize by
-	// building the path more efficiently and caching at the Database level
-	
-	// Build path efficiently using strings.Builder
 	var builder strings.Builder
-	builder.Grow(256) // Pre-allocate reasonable capacity
-	
-	// Collect path components (we'll build in reverse order)
-	components := make([]string, 0, 10)
-	components = append(components, e.Name)
-	
-	parent := e.Parent
-	isRoot := false
-	for parent != nil {
-		if parent.Name == "" {
-			isRoot = true
-			break
-		}
-		components = append(components, parent.Name)
-		parent = parent.Parent
-	}
-	
-	// Build path from components (reverse order)
-	if isRoot {
-		builder.WriteByte('/')
-	}
+	builder.Grow(256)
+	builder.WriteString(rootPath(top.Name))
 	for i := len(components) - 1; i >= 0; i-- {
-		if i < len(components)-1 {
+		if !strings.HasSuffix(builder.String(), "/") {
 			builder.WriteByte('/')
-		} else if isRoot && i == len(components)-1 {
-			// Already wrote '/' for root
 		}
 		builder.WriteString(components[i])
 	}
-	
 	return builder.String()
 }
 
-// getFullPathCached returns the full path using the database's path cache.
-// This is the optimized version that should be used when Database is available.
-func (db *Database) getFullPathCached(e *Entry) string {
+// rootPath returns the path of a topmost folder named name. FSearch names
+// the root of the filesystem "", and a folder indexed on its own, such as
+// /mnt/data, by its absolute path. Any other name is taken to be a folder
+// directly below the root.
+func rootPath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "/" + name
+}
+
+// joinPath returns the path of the entry named name in the folder at dir
+func joinPath(dir, name string) string {
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// SetPathCacheSize bounds the number of full paths FullPath caches,
+// evicting the least recently used beyond n. A size of 0 disables the cache.
+// The default is DefaultPathCacheSize. Changing the size empties the cache,
+// and must not be done while a search is running.
+func (db *Database) SetPathCacheSize(n int) {
+	db.pathCacheOnce.Do(func() {})
+	db.pathCache = newPathCache(n)
+}
+
+// paths returns the path cache, creating it with the default size the first
+// time for a database that was not loaded or merged, such as one built in a
+// test. sync.Once makes that safe when searches share the database.
+func (db *Database) paths() *pathCache {
+	db.pathCacheOnce.Do(func() {
+		if db.pathCache == nil {
+			db.pathCache = newPathCache(DefaultPathCacheSize)
+		}
+	})
+	return db.pathCache
+}
+
+// FullPath returns the full path of e, as GetFullPath does, but keeps the
+// paths it computes in the database's path cache: each entry's path is
+// built from its parent's, so the folders shared by many entries are walked
+// once rather than for every entry below them.
+func (db *Database) FullPath(e *Entry) string {
+	cache := db.paths()
+
 	// Check cache first
-	if cached, ok := db.pathCache.Load(e); ok {
-		return cached.(string)
+	if cached, ok := cache.get(e); ok {
+		return cached
 	}
-	
+
 	// For entries without parent, cache and return immediately
 	if e.Parent == nil {
-		var path string
-		if e.Name == "" {
-			path = "/"
-		} else {
-			path = e.Name
-		}
-		db.pathCache.Store(e, path)
+		path := rootPath(e.Name)
+		cache.add(e, path)
 		return path
 	}
-	
-	// Check if parent's path is cached
-	var parentPath string
-	if e.Parent != nil {
-		if cached, ok := db.pathCache.Load(e.Parent); ok {
-			parentPath = cached.(string)
-		} else {
-			// Recursively compute parent's path (will cache it)
-			parentPath = db.getFullPathCached(&e.Parent.Entry)
+
+	// Parent's path, from the cache or computed (and cached) recursively
+	fullPath := joinPath(db.FullPath(&e.Parent.Entry), e.Name)
+
+	// Cache it
+	cache.add(e, fullPath)
+	return fullPath
+}
+
+// FullPathSep returns the full path of e as FullPath does, but with sep
+// between its components instead of /, such as "\\" for a database indexed
+// from Windows paths. Names cannot contain /, so every / is a separator.
+func (db *Database) FullPathSep(e *Entry, sep string) string {
+	path := db.FullPath(e)
+	if sep == "/" {
+		return path
+	}
+	return strings.ReplaceAll(path, "/", sep)
+}
+
+// FolderByDBIndex returns the first folder, in database order, whose
+// DBIndex is idx. FSearch gives every folder of an indexed location the
+// same db_index, so many folders may share idx.
+func (db *Database) FolderByDBIndex(idx uint32) (*Folder, bool) {
+	for _, folder := range db.Folders {
+		if folder.DBIndex == idx {
+			return folder, true
 		}
 	}
-	
-	// Build this entry's path from parent
-	var fullPath string
-	if parentPath == "/" {
-		fullPath = "/" + e.Name
-	} else {
-		fullPath = parentPath + "/" + e.Name
+	return nil, false
+}
+
+// EntryByIndex returns the file whose Index is idx. That is its position in
+// Files, except in a database made by Merge, whose entries keep the indices
+// of their sources and so are not found here.
+func (db *Database) EntryByIndex(idx uint32) (*Entry, bool) {
+	if int64(idx) >= int64(len(db.Files)) || db.Files[idx].Index != idx {
+		return nil, false
 	}
-	
-	// Cache it
-	db.pathCache.Store(e, fullPath)
-	return fullPath
+	return db.Files[idx], true
 }
 
 // GetFullPath returns the full path of a folder
@@ -1,8 +1,21 @@
 package db
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // This file uses Go's standard testing package.
@@ -150,7 +163,7 @@ func TestSearch(t *testing.T) {
 		SearchInFolders: true,
 	}
 
-	result := db.Search(opts)
+	result := mustSearch(t, db, opts)
 	// Should find: test.txt, test.go
 	if len(result.Files) != 2 {
 		t.Errorf("Expected 2 files, got %d", len(result.Files))
@@ -171,7 +184,7 @@ func TestSearch(t *testing.T) {
 	// Test case-sensitive search
 	opts.CaseSensitive = true
 	opts.Query = "Test"
-	result = db.Search(opts)
+	result = mustSearch(t, db, opts)
 	if len(result.Files) != 0 {
 		t.Errorf("Expected 0 files (case-sensitive), got %d", len(result.Files))
 	}
@@ -179,7 +192,7 @@ func TestSearch(t *testing.T) {
 	// Test folder search for "doc"
 	opts.Query = "doc"
 	opts.CaseSensitive = false
-	result = db.Search(opts)
+	result = mustSearch(t, db, opts)
 	if len(result.Folders) != 1 {
 		t.Errorf("Expected 1 folder, got %d", len(result.Folders))
 	}
@@ -217,3 +230,2191 @@ func TestMatchWholeWord(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadWithRetry(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	// Simulate fsearch finishing a re-index between attempts: the database
+	// only appears once the writer renames it into place.
+	if _, err := Load(dbPath); err == nil {
+		t.Fatal("Expected initial load to fail while database is unavailable")
+	}
+	var slept []time.Duration
+	retrySleep = func(d time.Duration) {
+		if len(slept) == 0 {
+			staging := filepath.Join(tmpDir, "staging.db")
+			if err := CreateTestDatabase(staging); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(staging, dbPath); err != nil {
+				t.Fatal(err)
+			}
+		}
+		slept = append(slept, d)
+	}
+	defer func() { retrySleep = time.Sleep }()
+
+	db, err := LoadWithRetry(dbPath, 10, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected load to succeed on retry, got: %v", err)
+	}
+	if len(db.Files) != 5 {
+		t.Errorf("Expected 5 files, got %d", len(db.Files))
+	}
+	if len(slept) != 1 || slept[0] != 20*time.Millisecond {
+		t.Errorf("Expected one 20ms wait, got %v", slept)
+	}
+}
+
+func TestLoadWithRetryGivesUp(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "missing.db")
+
+	_, err := LoadWithRetry(dbPath, 2, time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected error for missing database")
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected attempt count in error, got: %v", err)
+	}
+}
+
+func TestLoadGzip(t *testing.T) {
+	raw, err := os.ReadFile(setupTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	gzPath := filepath.Join(dir, "test.db.gz")
+	if err := os.WriteFile(gzPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load(gzPath)
+	if err != nil {
+		t.Fatalf("Load of gzipped database failed: %v", err)
+	}
+	if len(db.Files) != 5 || len(db.Folders) != 5 {
+		t.Errorf("Expected 5 files and 5 folders, got %d and %d", len(db.Files), len(db.Folders))
+	}
+	if meta, err := LoadMetadata(gzPath); err != nil || meta.NumFiles != 5 {
+		t.Errorf("LoadMetadata = %+v, %v", meta, err)
+	}
+	if rec, err := DumpRecord(gzPath, EntryTypeFile, 0); err != nil || rec.Name == "" {
+		t.Errorf("DumpRecord = %+v, %v", rec, err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.db.gz")
+	if err := os.WriteFile(truncated, buf.Bytes()[:buf.Len()/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(truncated)
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Expected truncated gzip error, got: %v", err)
+	}
+}
+
+func TestLoadNewerMinorVersion(t *testing.T) {
+	dbPath := setupTestDB(t)
+	raw, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[5] = MinorVersion + 1
+	if err := os.WriteFile(dbPath, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(dbPath)
+	if err != nil {
+		t.Fatalf("Expected a newer minor version to load, got: %v", err)
+	}
+	if !db.UnknownVersion {
+		t.Error("Expected UnknownVersion to be set")
+	}
+	if len(db.Files) != 5 {
+		t.Errorf("Expected 5 files, got %d", len(db.Files))
+	}
+
+	db, err = Load(setupTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.UnknownVersion {
+		t.Error("Expected UnknownVersion to be clear for the supported version")
+	}
+}
+
+func TestLoadExtraTimes(t *testing.T) {
+	// Access and status-change times are indexed but creation time is not,
+	// so each record holds mtime, atime, then status-change time
+	flags := IndexFlagName | IndexFlagSize | IndexFlagModificationTime | IndexFlagAccessTime | IndexFlagStatusChangeTime
+	record := func(b []byte, name string, mtime, atime, stime int64, parent uint32) []byte {
+		b = append(b, 0, byte(len(name)))
+		b = append(b, name...)
+		b = binary.LittleEndian.AppendUint64(b, 42)
+		b = binary.LittleEndian.AppendUint64(b, uint64(mtime))
+		b = binary.LittleEndian.AppendUint64(b, uint64(atime))
+		b = binary.LittleEndian.AppendUint64(b, uint64(stime))
+		return binary.LittleEndian.AppendUint32(b, parent)
+	}
+	folderBlock := record([]byte{0, 0}, "", 100, 200, 300, 0)
+	fileBlock := record(nil, "a.txt", 1000, 2000, 3000, 0)
+
+	db := &Database{IndexFlags: flags}
+	db.metadata = metadata{
+		indexFlags:      flags,
+		numFolders:      1,
+		numFiles:        1,
+		folderBlockSize: uint64(len(folderBlock)),
+		fileBlockSize:   uint64(len(fileBlock)),
+	}
+	db.Folders = []*Folder{{Entry: Entry{Type: EntryTypeFolder}}}
+	if err := db.loadFolders(bytes.NewReader(folderBlock)); err != nil {
+		t.Fatalf("loadFolders: %v", err)
+	}
+	if err := db.loadFiles(bytes.NewReader(fileBlock)); err != nil {
+		t.Fatalf("loadFiles: %v", err)
+	}
+
+	root, file := db.Folders[0], db.Files[0]
+	if root.MTime.Unix() != 100 || root.ATime.Unix() != 200 || root.StatusChangeTime.Unix() != 300 {
+		t.Errorf("Folder times = %v, %v, %v", root.MTime, root.ATime, root.StatusChangeTime)
+	}
+	if file.Name != "a.txt" || file.Size != 42 || file.Parent != root {
+		t.Errorf("File = %+v", file)
+	}
+	if file.MTime.Unix() != 1000 || file.ATime.Unix() != 2000 || file.StatusChangeTime.Unix() != 3000 {
+		t.Errorf("File times = %v, %v, %v", file.MTime, file.ATime, file.StatusChangeTime)
+	}
+	if !file.CTime.IsZero() {
+		t.Errorf("Expected no creation time, got %v", file.CTime)
+	}
+
+	rec, _, err := db.scanRecord(fileBlock, 0, "", EntryTypeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ATime != 2000 || rec.StatusChangeTime != 3000 || rec.Parent != 0 {
+		t.Errorf("Raw record = %+v", rec)
+	}
+}
+
+func TestChildrenAndFolderSize(t *testing.T) {
+	db, err := Load(setupTestDB(t))
+	if err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	root, home, user := db.Folders[0], db.Folders[1], db.Folders[2]
+
+	folders, files := db.Children(root)
+	if len(folders) != 3 || len(files) != 0 {
+		t.Errorf("Expected root to have 3 folders and 0 files, got %d and %d", len(folders), len(files))
+	}
+
+	folders, files = db.Children(user)
+	if len(folders) != 0 || len(files) != 2 {
+		t.Errorf("Expected user to have 0 folders and 2 files, got %d and %d", len(folders), len(files))
+	}
+
+	if size := db.FolderSize(home); size != 1024+2048 {
+		t.Errorf("Expected /home size 3072, got %d", size)
+	}
+	if size := db.FolderSize(root); size != 1024+2048+4096+8192+16384 {
+		t.Errorf("Expected root size 31744, got %d", size)
+	}
+
+	// Counted at load from the parent indices
+	if root.NumFolders != 3 || root.NumFiles != 0 || user.NumFolders != 0 || user.NumFiles != 2 {
+		t.Errorf("Expected root 0 files, 3 folders and user 2 files, 0 folders; got %d, %d and %d, %d",
+			root.NumFiles, root.NumFolders, user.NumFiles, user.NumFolders)
+	}
+
+	if d := root.Depth(); d != 0 {
+		t.Errorf("Expected root depth 0, got %d", d)
+	}
+	if d := db.Files[0].Depth(); d != 3 {
+		t.Errorf("Expected /home/user/test.txt depth 3, got %d", d)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	db := buildDatabase("/a/", "/a/x.txt", "/y.txt")
+
+	var visited []string
+	db.Walk(func(e *Entry, isFolder bool) bool {
+		if isFolder != (e.Type == EntryTypeFolder) {
+			t.Errorf("%s: isFolder = %v", e.GetFullPath(), isFolder)
+		}
+		visited = append(visited, e.GetFullPath())
+		return true
+	})
+	want := []string{"/", "/a", "/a/x.txt", "/y.txt"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk visited %v, want %v", visited, want)
+	}
+
+	n := 0
+	db.Walk(func(e *Entry, isFolder bool) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Walk went on after fn returned false: %d calls", n)
+	}
+}
+
+func TestDuplicatesBySize(t *testing.T) {
+	db := buildDatabase("/a.txt", "/b.txt", "/c.txt", "/d.txt", "/e.txt")
+	for i, size := range []int64{10, 20, 10, 20, 10} {
+		db.Files[i].Size = size
+	}
+
+	groups := (&SearchResult{Files: db.Files}).DuplicatesBySize()
+	want := map[int64][]*Entry{
+		10: {db.Files[0], db.Files[2], db.Files[4]},
+		20: {db.Files[1], db.Files[3]},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("DuplicatesBySize() = %v, want %v", groups, want)
+	}
+
+	if groups := (&SearchResult{Files: db.Files[:2]}).DuplicatesBySize(); len(groups) != 0 {
+		t.Errorf("Expected no groups, got %v", groups)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	root := &Folder{Entry: Entry{Name: "", Type: EntryTypeFolder}}
+	home := &Folder{Entry: Entry{Name: "home", Parent: root, Type: EntryTypeFolder}}
+	// detached lost its parent index, so everything below it is cut off
+	detached := &Folder{Entry: Entry{Name: "detached", Type: EntryTypeFolder, brokenParent: true}}
+	loopA := &Folder{Entry: Entry{Name: "a", Type: EntryTypeFolder}}
+	loopB := &Folder{Entry: Entry{Name: "b", Parent: loopA, Type: EntryTypeFolder}}
+	loopA.Parent = loopB
+
+	db := &Database{
+		Folders: []*Folder{root, home, detached, loopA, loopB},
+		Files: []*Entry{
+			{Name: "ok.txt", Parent: home, Type: EntryTypeFile},
+			{Name: "lost.txt", Parent: detached, Type: EntryTypeFile},
+			{Name: "dangling.txt", Type: EntryTypeFile, brokenParent: true},
+		},
+	}
+
+	got := make(map[string]UnreachableReason)
+	for _, u := range db.Unreachable() {
+		got[u.PartialPath] = u.Reason
+	}
+
+	want := map[string]UnreachableReason{
+		"?/detached":          ReasonMissingParent,
+		"?/detached/lost.txt": ReasonMissingParent,
+		"?/dangling.txt":      ReasonMissingParent,
+		"?/b/a":               ReasonParentCycle,
+		"?/a/b":               ReasonParentCycle,
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d unreachable entries, got %d: %v", len(want), len(got), got)
+	}
+	for path, reason := range want {
+		if got[path] != reason {
+			t.Errorf("Expected %q to be unreachable (%s), got %q", path, reason, got[path])
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	db := buildDatabase(
+		"/a/b/c/deep.bin",
+		"/a/top.txt",
+		"/root.txt",
+		"/empty/",
+	)
+	sizes := map[string]int64{"deep.bin": 300, "top.txt": 100, "root.txt": 200}
+	for _, f := range db.Files {
+		f.Size = sizes[f.Name]
+	}
+	// A loop of two folders outside the hierarchy
+	loopA := &Folder{Entry: Entry{Name: "x", Type: EntryTypeFolder}}
+	loopB := &Folder{Entry: Entry{Name: "y", Parent: loopA, Type: EntryTypeFolder}}
+	loopA.Parent = loopB
+	db.Folders = append(db.Folders, loopA, loopB)
+	db.Files = append(db.Files, &Entry{Name: "lost", Size: 50, Parent: loopB, Type: EntryTypeFile})
+
+	s := db.Stats()
+	if s.Folders != 7 || s.Files != 4 {
+		t.Errorf("Expected 7 folders and 4 files, got %d and %d", s.Folders, s.Files)
+	}
+	// root; a, empty, root.txt; b, top.txt; c; deep.bin
+	if want := []int{1, 3, 2, 1, 1}; !reflect.DeepEqual(s.Depths, want) {
+		t.Errorf("Depths = %v, want %v", s.Depths, want)
+	}
+	var largest []string
+	for _, f := range s.Largest {
+		largest = append(largest, f.Name)
+	}
+	if want := []string{"deep.bin", "root.txt", "top.txt", "lost"}; !reflect.DeepEqual(largest, want) {
+		t.Errorf("Largest = %v, want %v", largest, want)
+	}
+
+	many := buildDatabase(manyFiles(25)...)
+	for i, f := range many.Files {
+		f.Size = int64(i % 12)
+	}
+	largest = largest[:0]
+	for _, f := range many.Stats().Largest {
+		largest = append(largest, fmt.Sprint(f.Size))
+	}
+	if want := []string{"11", "11", "10", "10", "9", "9", "8", "8", "7", "7"}; !reflect.DeepEqual(largest, want) {
+		t.Errorf("Largest sizes = %v, want %v", largest, want)
+	}
+}
+
+func TestUnreachableTestDatabase(t *testing.T) {
+	db, err := Load(setupTestDB(t))
+	if err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	if broken := db.Unreachable(); len(broken) != 0 {
+		t.Errorf("Expected no unreachable entries in test database, got %d", len(broken))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	db, err := Load(setupTestDB(t))
+	if err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	if problems := db.Verify(); len(problems) != 0 {
+		t.Errorf("Expected a clean test database, got %v", problems)
+	}
+
+	// Break it in every way Verify looks for
+	db.Folders[4].brokenParent = true
+	db.Files[0].brokenParent = true
+	db.Folders[2].Parent = db.Folders[3]
+	db.Folders[3].Parent = db.Folders[2]
+	db.SortedArrays[7] = &SortedArray{ID: 7, Folders: []uint32{0, 1, 2, 3, 4}, Files: []uint32{0, 0, 1, 2, 3}}
+
+	var got []string
+	for _, err := range db.Verify() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"folder 4 ",
+		"file 0 ",
+		"parent chain loops",
+		"sorted array 7: files: index 0 repeated",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d problems, got %d: %q", len(want), len(got), got)
+	}
+	for i, w := range want {
+		if !strings.Contains(got[i], w) {
+			t.Errorf("Problem %d = %q, want it to mention %q", i, got[i], w)
+		}
+	}
+}
+
+func TestSearchMaxPerExtension(t *testing.T) {
+	db := &Database{}
+	for _, name := range []string{"a.txt", "b.TXT", "c.txt", "d.go", "e.go", "f.go", "g.pdf", "Makefile"} {
+		db.Files = append(db.Files, &Entry{Name: name, Type: EntryTypeFile})
+	}
+
+	opts := SearchOptions{
+		Query:           "*",
+		SearchInFiles:   true,
+		MaxPerExtension: 2,
+	}
+	result := mustSearch(t, db, opts)
+
+	counts := make(map[string]int)
+	for _, file := range result.Files {
+		counts[strings.ToLower(Extension(file.Name))]++
+	}
+	for ext, n := range counts {
+		if n > 2 {
+			t.Errorf("Extension %q has %d results, cap is 2", ext, n)
+		}
+	}
+	if counts["txt"] != 2 || counts["go"] != 2 || counts["pdf"] != 1 || counts[""] != 1 {
+		t.Errorf("Unexpected per-extension counts: %v", counts)
+	}
+
+	// Composes with the global cap
+	opts.MaxResults = 3
+	if result := mustSearch(t, db, opts); len(result.Files) != 3 {
+		t.Errorf("Expected global -max to still apply, got %d files", len(result.Files))
+	}
+}
+
+func TestSearchMaxFilesAndFolders(t *testing.T) {
+	db := buildDatabase(
+		"/a/", "/b/", "/c/",
+		"/a/1.txt", "/a/2.txt", "/a/3.txt", "/a/4.txt",
+	)
+	search := func(maxFiles, maxFolders, maxResults int) (int, int, bool) {
+		result := mustSearch(t, db, SearchOptions{
+			Query:           "*",
+			SearchInFiles:   true,
+			SearchInFolders: true,
+			MaxFiles:        maxFiles,
+			MaxFolders:      maxFolders,
+			MaxResults:      maxResults,
+		})
+		return len(result.Files), len(result.Folders), result.Truncated
+	}
+
+	tests := []struct {
+		maxFiles, maxFolders, maxResults int
+		files, folders                   int
+		truncated                        bool
+	}{
+		// The root folder matches "*" too, so there are 4 folders
+		{0, 0, 0, 4, 4, false},
+		{2, 0, 0, 2, 4, true},
+		{0, 1, 0, 4, 1, true},
+		{2, 1, 0, 2, 1, true},
+		{4, 4, 0, 4, 4, false},
+		// -max is an overall ceiling on top of the per-type caps
+		{2, 3, 4, 2, 2, true},
+		{10, 10, 5, 4, 1, true},
+	}
+	for _, tt := range tests {
+		files, folders, truncated := search(tt.maxFiles, tt.maxFolders, tt.maxResults)
+		if files != tt.files || folders != tt.folders || truncated != tt.truncated {
+			t.Errorf("MaxFiles %d, MaxFolders %d, MaxResults %d: got %d files, %d folders, truncated %v; want %d, %d, %v",
+				tt.maxFiles, tt.maxFolders, tt.maxResults, files, folders, truncated, tt.files, tt.folders, tt.truncated)
+		}
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := map[string]string{
+		"file.txt":       "txt",
+		"archive.tar.gz": "gz",
+		"Makefile":       "",
+		".bashrc":        "",
+		"trailing.":      "",
+	}
+	for name, want := range tests {
+		if got := Extension(name); got != want {
+			t.Errorf("Extension(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// buildDatabase constructs an in-memory database from absolute paths. Paths
+// ending in "/" become folders, all others files; missing parent folders are
+// created automatically under an empty-named root.
+func buildDatabase(paths ...string) *Database {
+	root := &Folder{Entry: Entry{Name: "", Type: EntryTypeFolder}}
+	db := &Database{Folders: []*Folder{root}}
+	folders := map[string]*Folder{"": root}
+
+	var folderFor func(dir string) *Folder
+	folderFor = func(dir string) *Folder {
+		if f, ok := folders[dir]; ok {
+			return f
+		}
+		i := strings.LastIndexByte(dir, '/')
+		f := &Folder{Entry: Entry{
+			Name:   dir[i+1:],
+			Parent: folderFor(dir[:i]),
+			Index:  uint32(len(db.Folders)),
+			Type:   EntryTypeFolder,
+		}}
+		folders[dir] = f
+		db.Folders = append(db.Folders, f)
+		return f
+	}
+
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			folderFor(strings.TrimSuffix(p, "/"))
+			continue
+		}
+		i := strings.LastIndexByte(p, '/')
+		db.Files = append(db.Files, &Entry{
+			Name:   p[i+1:],
+			Parent: folderFor(p[:i]),
+			Index:  uint32(len(db.Files)),
+			Type:   EntryTypeFile,
+		})
+	}
+	return db
+}
+
+func TestSearchPathSegmentMatch(t *testing.T) {
+	db := buildDatabase(
+		"/home/user/notes.txt",
+		"/home/username/todo.txt",
+		"/srv/user",
+	)
+
+	paths := func(result *SearchResult) []string {
+		var out []string
+		for _, f := range result.Folders {
+			out = append(out, f.GetFullPath())
+		}
+		for _, f := range result.Files {
+			out = append(out, f.GetFullPath())
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		opts    PathSearchOptions
+		matches []string
+	}{
+		{
+			"substring matches username",
+			PathSearchOptions{Pattern: "user"},
+			[]string{"/home/user", "/home/user/notes.txt", "/home/username", "/home/username/todo.txt", "/srv/user"},
+		},
+		{
+			"segment excludes username",
+			PathSearchOptions{Pattern: "user", SegmentMatch: true},
+			[]string{"/home/user", "/home/user/notes.txt", "/srv/user"},
+		},
+		{
+			"segment with multiple components",
+			PathSearchOptions{Pattern: "/home/user/", SegmentMatch: true},
+			[]string{"/home/user", "/home/user/notes.txt"},
+		},
+		{
+			"segment with wildcard",
+			PathSearchOptions{Pattern: "user*", SegmentMatch: true},
+			[]string{"/home/user", "/home/user/notes.txt", "/home/username", "/home/username/todo.txt", "/srv/user"},
+		},
+		{
+			"segment is case-insensitive by default",
+			PathSearchOptions{Pattern: "USER", SegmentMatch: true},
+			[]string{"/home/user", "/home/user/notes.txt", "/srv/user"},
+		},
+		{
+			"segment honors case sensitivity",
+			PathSearchOptions{Pattern: "USER", SegmentMatch: true, CaseSensitive: true},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := paths(mustSearchPath(t, db, tt.opts))
+			if strings.Join(got, ",") != strings.Join(tt.matches, ",") {
+				t.Errorf("SearchPath(%+v) = %v, want %v", tt.opts, got, tt.matches)
+			}
+		})
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	db := buildDatabase(
+		"/a/README",
+		"/a/readme",
+		"/a/notes.txt",
+		"/a/Src/",
+		"/a/src",
+		"/b/README",
+		"/c/readme",
+	)
+	db.buildTree()
+
+	var got []string
+	for _, group := range db.CaseCollisions() {
+		var paths []string
+		for _, e := range group {
+			paths = append(paths, e.GetFullPath())
+		}
+		got = append(got, strings.Join(paths, " "))
+	}
+
+	want := []string{
+		"/a/Src /a/src",
+		"/a/README /a/readme",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CaseCollisions() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name, query string
+		want        float64
+	}{
+		{"report", "report", 1},
+		{"Report", "report", 1},
+		{"report.pdf", "report", 0.85},
+		{"annual-report.pdf", "report", 0.5882},
+		{"myreport.pdf", "report", 0.425},
+		{"notes.txt", "report", 0},
+		{"report.pdf", "*.pdf", 0.4},
+		{"report.txt", "*.pdf", 0},
+	}
+	for _, tt := range tests {
+		if got := Score(tt.name, tt.query, false); got != tt.want {
+			t.Errorf("Score(%q, %q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+
+	if got := Score("Report", "report", true); got != 0 {
+		t.Errorf("Case-sensitive Score(Report, report) = %v, want 0", got)
+	}
+}
+
+func TestSearchMinScore(t *testing.T) {
+	db := buildDatabase("/docs/report", "/docs/report.pdf", "/docs/myreport-final-v2.pdf")
+
+	all := mustSearch(t, db, SearchOptions{Query: "report", SearchInFiles: true, Score: true})
+	if len(all.Files) != 3 {
+		t.Fatalf("Expected 3 matches, got %d", len(all.Files))
+	}
+	for _, file := range all.Files {
+		if all.Scores[file] <= 0 || all.Scores[file] > 1 {
+			t.Errorf("Score for %s out of range: %v", file.Name, all.Scores[file])
+		}
+	}
+
+	strong := mustSearch(t, db, SearchOptions{Query: "report", SearchInFiles: true, MinScore: 0.8})
+	var names []string
+	for _, file := range strong.Files {
+		names = append(names, file.Name)
+	}
+	if got := strings.Join(names, ","); got != "report,report.pdf" {
+		t.Errorf("MinScore 0.8 kept %q, want report,report.pdf", got)
+	}
+
+	plain := mustSearch(t, db, SearchOptions{Query: "report", SearchInFiles: true})
+	if plain.Scores != nil {
+		t.Error("Expected no scores without Score or MinScore")
+	}
+}
+
+func TestSearchFollow(t *testing.T) {
+	db := buildDatabase(
+		"/home/photos/",
+		"/home/photos/2023/",
+		"/home/photos/2023/beach.jpg",
+		"/home/photos/2023/old-photos/",
+		"/home/photos/2023/old-photos/scan.png",
+		"/home/photos/cover.jpg",
+		"/home/docs/",
+		"/home/docs/notes.txt",
+	)
+
+	paths := func(result *SearchResult) []string {
+		var got []string
+		for _, folder := range result.Folders {
+			got = append(got, folder.GetFullPath()+"/")
+		}
+		for _, file := range result.Files {
+			got = append(got, file.GetFullPath())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{
+			name: "without follow",
+			opts: SearchOptions{Query: "photos", SearchInFiles: true, SearchInFolders: true},
+			want: []string{"/home/photos/", "/home/photos/2023/old-photos/"},
+		},
+		{
+			name: "follow",
+			opts: SearchOptions{Query: "photos", SearchInFiles: true, SearchInFolders: true, Follow: true},
+			want: []string{
+				"/home/photos/",
+				"/home/photos/2023/",
+				"/home/photos/2023/beach.jpg",
+				"/home/photos/2023/old-photos/",
+				"/home/photos/2023/old-photos/scan.png",
+				"/home/photos/cover.jpg",
+			},
+		},
+		{
+			name: "follow files only",
+			opts: SearchOptions{Query: "photos", SearchInFiles: true, Follow: true},
+			want: []string{
+				"/home/photos/2023/beach.jpg",
+				"/home/photos/2023/old-photos/scan.png",
+				"/home/photos/cover.jpg",
+			},
+		},
+		{
+			// The nested match restarts the depth budget for its own subtree
+			name: "follow depth",
+			opts: SearchOptions{Query: "photos", SearchInFiles: true, SearchInFolders: true, Follow: true, FollowDepth: 1},
+			want: []string{
+				"/home/photos/",
+				"/home/photos/2023/",
+				"/home/photos/2023/old-photos/",
+				"/home/photos/2023/old-photos/scan.png",
+				"/home/photos/cover.jpg",
+			},
+		},
+		{
+			// Followed entries count toward the limits on each kind
+			name: "follow limits",
+			opts: SearchOptions{Query: "photos", SearchInFiles: true, SearchInFolders: true, Follow: true, MaxFiles: 1, MaxFolders: 1},
+			want: []string{"/home/photos/", "/home/photos/cover.jpg"},
+		},
+		{
+			name: "follow max results",
+			opts: SearchOptions{Query: "photos", SearchInFolders: true, Follow: true, MaxResults: 2},
+			want: []string{"/home/photos/", "/home/photos/2023/old-photos/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustSearch(t, db, tt.opts)
+			limited := tt.opts.MaxFiles > 0 || tt.opts.MaxResults > 0
+			if result.Truncated != limited {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, limited)
+			}
+			got := paths(result)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i] == got[i-1] {
+					t.Errorf("%s listed more than once", got[i])
+				}
+			}
+		})
+	}
+}
+
+// manyFiles returns paths for n files spread over ten folders
+// mustSearch runs db.Search, failing the test on an error
+func mustSearch(t testing.TB, db *Database, opts SearchOptions) *SearchResult {
+	t.Helper()
+	result, err := db.Search(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// mustSearchPath runs db.SearchPath, failing the test on an error
+func mustSearchPath(t testing.TB, db *Database, opts PathSearchOptions) *SearchResult {
+	t.Helper()
+	result, err := db.SearchPath(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func manyFiles(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/data/dir%d/file%06d.txt", i%10, i)
+	}
+	return paths
+}
+
+func TestPathCacheBounded(t *testing.T) {
+	db := buildDatabase(manyFiles(1000)...)
+	db.SetPathCacheSize(50)
+
+	result := mustSearchPath(t, db, PathSearchOptions{Pattern: "/data/"})
+	if len(result.Files) != 1000 {
+		t.Fatalf("Expected 1000 matches, got %d", len(result.Files))
+	}
+	if n := db.pathCache.len(); n > 50 {
+		t.Errorf("Cache holds %d paths, want at most 50", n)
+	}
+
+	// Folder paths are refreshed by every file beneath them, so the shared
+	// ancestors survive eviction while individual file paths cycle through
+	hot := &db.Files[0].Parent.Entry
+	if _, ok := db.pathCache.get(hot); !ok {
+		t.Errorf("Expected hot folder %s to stay cached", hot.GetFullPath())
+	}
+	if _, ok := db.pathCache.get(db.Files[0]); ok {
+		t.Error("Expected least recently used file path to be evicted")
+	}
+
+	// Cached paths stay correct after eviction
+	for _, file := range db.Files[:20] {
+		if got, want := db.FullPath(file), file.GetFullPath(); got != want {
+			t.Errorf("Cached path %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPathCacheDisabled(t *testing.T) {
+	db := buildDatabase(manyFiles(100)...)
+	db.SetPathCacheSize(0)
+
+	result := mustSearchPath(t, db, PathSearchOptions{Pattern: "file00004*", SegmentMatch: true})
+	if len(result.Files) != 10 {
+		t.Errorf("Expected 10 matches, got %d", len(result.Files))
+	}
+	if n := db.pathCache.len(); n != 0 {
+		t.Errorf("Disabled cache holds %d paths", n)
+	}
+}
+
+func BenchmarkSearchPathCache(b *testing.B) {
+	paths := manyFiles(50000)
+	for _, size := range []int{0, 1000, DefaultPathCacheSize} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			db := buildDatabase(paths...)
+			db.SetPathCacheSize(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mustSearchPath(b, db, PathSearchOptions{Pattern: "dir3/file0001"})
+			}
+			b.ReportMetric(float64(db.pathCache.len()), "cached-paths")
+		})
+	}
+}
+
+func TestSearchMatchStem(t *testing.T) {
+	db := buildDatabase(
+		"/docs/report.pdf",
+		"/docs/Report.docx",
+		"/docs/quarterly-report.pdf",
+		"/docs/report.tar.gz",
+		"/docs/report",
+		"/docs/.report",
+	)
+	names := func(opts SearchOptions) []string {
+		opts.SearchInFiles = true
+		var out []string
+		for _, file := range mustSearch(t, db, opts).Files {
+			out = append(out, file.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		opts SearchOptions
+		want []string
+	}{
+		{SearchOptions{Query: "report", MatchStem: true}, []string{"report.pdf", "Report.docx", "report"}},
+		{SearchOptions{Query: "report", MatchStem: true, CaseSensitive: true}, []string{"report.pdf", "report"}},
+		{SearchOptions{Query: "report", MatchStem: true, MatchWholeWord: true}, []string{"report.pdf", "Report.docx", "quarterly-report.pdf", "report.tar.gz", "report", ".report"}},
+		{SearchOptions{Query: "report.tar", MatchStem: true}, []string{"report.tar.gz"}},
+		{SearchOptions{Query: "*report", MatchStem: true}, []string{"report.pdf", "Report.docx", "quarterly-report.pdf", "report", ".report"}},
+		{SearchOptions{Query: "pdf", MatchStem: true, MatchWholeWord: true}, nil},
+	}
+	for _, tt := range tests {
+		if got := names(tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestSearchDepth(t *testing.T) {
+	db := buildDatabase(
+		"/node_modules/",
+		"/app/node_modules/",
+		"/app/node_modules/pkg/node_modules/",
+		"/app/node_modules/pkg/node_modules.txt",
+	)
+	search := func(minDepth, maxDepth int) []string {
+		result := mustSearch(t, db, SearchOptions{
+			Query:           "node_modules",
+			SearchInFiles:   true,
+			SearchInFolders: true,
+			MinDepth:        minDepth,
+			MaxDepth:        maxDepth,
+		})
+		var paths []string
+		for _, file := range result.Files {
+			paths = append(paths, file.GetFullPath())
+		}
+		for _, folder := range result.Folders {
+			paths = append(paths, folder.GetFullPath())
+		}
+		return paths
+	}
+
+	tests := []struct {
+		minDepth, maxDepth int
+		want               []string
+	}{
+		{0, 0, []string{"/app/node_modules/pkg/node_modules.txt", "/node_modules", "/app/node_modules", "/app/node_modules/pkg/node_modules"}},
+		{0, 2, []string{"/node_modules", "/app/node_modules"}},
+		{2, 0, []string{"/app/node_modules/pkg/node_modules.txt", "/app/node_modules", "/app/node_modules/pkg/node_modules"}},
+		{4, 4, []string{"/app/node_modules/pkg/node_modules.txt", "/app/node_modules/pkg/node_modules"}},
+	}
+	for _, tt := range tests {
+		if got := search(tt.minDepth, tt.maxDepth); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Depth %d to %d: got %v, want %v", tt.minDepth, tt.maxDepth, got, tt.want)
+		}
+	}
+}
+
+func TestSearchUnicodeCaseFold(t *testing.T) {
+	db := buildDatabase("/docs/café.txt", "/docs/Straße.md", "/docs/ÅRSRAPPORT.pdf", "/docs/cafe.txt")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"CAFÉ.TXT", []string{"café.txt"}},
+		{"CAFÉ*", []string{"café.txt"}},
+		{"strasse", []string{"Straße.md"}},
+		{"årsrapport", []string{"ÅRSRAPPORT.pdf"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, file := range mustSearch(t, db, SearchOptions{Query: tt.query, SearchInFiles: true}).Files {
+			got = append(got, file.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Query %q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if got := mustSearchPath(t, db, PathSearchOptions{Pattern: "/DOCS/CAFÉ"}).Files; len(got) != 1 || got[0].Name != "café.txt" {
+		t.Errorf("Path search for /DOCS/CAFÉ found %d files", len(got))
+	}
+	if got := mustSearch(t, db, SearchOptions{Query: "CAFÉ.TXT", SearchInFiles: true, CaseSensitive: true}).Files; len(got) != 0 {
+		t.Errorf("Case-sensitive search for CAFÉ.TXT found %d files", len(got))
+	}
+}
+
+func TestSearchNormalize(t *testing.T) {
+	const composed, decomposed = "caf\u00e9", "cafe\u0301"
+	db := buildDatabase("/mac/"+decomposed+".txt", "/linux/"+composed+".md")
+
+	count := func(query string, noNormalize bool) int {
+		result := mustSearch(t, db, SearchOptions{Query: query, SearchInFiles: true, NoNormalize: noNormalize})
+		return len(result.Files)
+	}
+	for _, query := range []string{composed, decomposed, composed + "*", "CAF\u00c9"} {
+		if n := count(query, false); n != 2 {
+			t.Errorf("Query %+q found %d files, want both forms", query, n)
+		}
+	}
+	if n := count(composed, true); n != 1 {
+		t.Errorf("NoNormalize query found %d files, want only the composed one", n)
+	}
+
+	if n := len(mustSearchPath(t, db, PathSearchOptions{Pattern: "/mac/" + composed}).Files); n != 1 {
+		t.Errorf("Path search for the composed form found %d files", n)
+	}
+	if n := len(mustSearchPath(t, db, PathSearchOptions{Pattern: "/mac/" + composed, NoNormalize: true}).Files); n != 0 {
+		t.Errorf("NoNormalize path search found %d files", n)
+	}
+}
+
+func TestFullPath(t *testing.T) {
+	db := buildDatabase("/", "/a/b/c.txt", "/a/d.txt")
+	for _, e := range append([]*Entry{&db.Folders[0].Entry}, db.Files...) {
+		if got, want := db.FullPath(e), e.GetFullPath(); got != want {
+			t.Errorf("FullPath = %q, want %q", got, want)
+		}
+	}
+	if _, ok := db.pathCache.get(&db.Files[0].Parent.Entry); !ok {
+		t.Error("Expected the parent folder's path to be cached")
+	}
+
+	result := mustSearch(t, db, SearchOptions{Query: "c.txt", SearchInFiles: true})
+	if got := result.FullPath(result.Files[0]); got != "/a/b/c.txt" {
+		t.Errorf("SearchResult.FullPath = %q", got)
+	}
+	if got := (&SearchResult{}).FullPath(db.Files[1]); got != "/a/d.txt" {
+		t.Errorf("FullPath without a database = %q", got)
+	}
+}
+
+func TestFullPathNamedRoot(t *testing.T) {
+	tests := []struct {
+		root string
+		want []string
+	}{
+		{"/mnt/data", []string{"/mnt/data", "/mnt/data/photos", "/mnt/data/photos/a.jpg"}},
+		{"/", []string{"/", "/photos", "/photos/a.jpg"}},
+		{"data", []string{"/data", "/data/photos", "/data/photos/a.jpg"}},
+	}
+	for _, tt := range tests {
+		root := &Folder{Entry: Entry{Name: tt.root, Type: EntryTypeFolder}}
+		photos := &Folder{Entry: Entry{Name: "photos", Parent: root, Type: EntryTypeFolder}}
+		file := &Entry{Name: "a.jpg", Parent: photos, Type: EntryTypeFile}
+		db := &Database{Folders: []*Folder{root, photos}, Files: []*Entry{file}}
+
+		for i, e := range []*Entry{&root.Entry, &photos.Entry, file} {
+			if got := e.GetFullPath(); got != tt.want[i] {
+				t.Errorf("Root %q: GetFullPath = %q, want %q", tt.root, got, tt.want[i])
+			}
+			if got := db.FullPath(e); got != tt.want[i] {
+				t.Errorf("Root %q: FullPath = %q, want %q", tt.root, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFullPathSep(t *testing.T) {
+	db := buildDatabase("/home/user/a.txt", "/data/")
+	file, data := db.Files[0], &db.Folders[len(db.Folders)-1].Entry
+
+	tests := []struct {
+		e    *Entry
+		sep  string
+		want string
+	}{
+		{file, "/", "/home/user/a.txt"},
+		{file, `\`, `\home\user\a.txt`},
+		{file, "::", "::home::user::a.txt"},
+		{data, `\`, `\data`},
+		{&db.Folders[0].Entry, `\`, `\`},
+	}
+	for _, tt := range tests {
+		if got := db.FullPathSep(tt.e, tt.sep); got != tt.want {
+			t.Errorf("FullPathSep(%q, %q) = %q, want %q", tt.e.Name, tt.sep, got, tt.want)
+		}
+	}
+	if got := db.FullPath(file); got != "/home/user/a.txt" {
+		t.Errorf("FullPath after FullPathSep = %q", got)
+	}
+}
+
+func TestIndexLookups(t *testing.T) {
+	db := buildDatabase("/a/", "/b/", "/a/x.txt", "/b/y.txt")
+	for _, folder := range db.Folders {
+		folder.DBIndex = 1
+	}
+	db.Folders[2].DBIndex = 2
+
+	if folder, ok := db.FolderByDBIndex(1); !ok || folder != db.Folders[0] {
+		t.Errorf("FolderByDBIndex(1) = %v, %v; want the root", folder, ok)
+	}
+	if folder, ok := db.FolderByDBIndex(2); !ok || folder != db.Folders[2] {
+		t.Errorf("FolderByDBIndex(2) = %v, %v; want /b", folder, ok)
+	}
+	if _, ok := db.FolderByDBIndex(3); ok {
+		t.Error("FolderByDBIndex(3) found a folder")
+	}
+
+	if file, ok := db.EntryByIndex(1); !ok || file.Name != "y.txt" {
+		t.Errorf("EntryByIndex(1) = %v, %v; want y.txt", file, ok)
+	}
+	if _, ok := db.EntryByIndex(2); ok {
+		t.Error("EntryByIndex(2) found a file")
+	}
+	merged := Merge(buildDatabase("/c.txt"), db)
+	if _, ok := merged.EntryByIndex(1); ok {
+		t.Error("EntryByIndex found a merged file by its position")
+	}
+}
+
+// BenchmarkFullPath computes the path of every file of a scaled-up test
+// database, small enough to fit the default path cache, walking the parents
+// each time and through the cache
+func BenchmarkFullPath(b *testing.B) {
+	paths := make([]string, 0, 50000)
+	for i := 0; i < cap(paths); i++ {
+		paths = append(paths, fmt.Sprintf("/home/user/Documents/project%d/src/pkg%d/file%06d.go", i%20, i%7, i))
+	}
+	db := buildDatabase(paths...)
+	b.Run("GetFullPath", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, file := range db.Files {
+				_ = file.GetFullPath()
+			}
+		}
+	})
+	b.Run("FullPath", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, file := range db.Files {
+				_ = db.FullPath(file)
+			}
+		}
+	})
+}
+
+func TestDumpRecord(t *testing.T) {
+	dbPath := setupTestDB(t)
+	database, err := Load(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	mtime := uint64(database.Files[1].MTime.Unix())
+
+	// readme.txt follows test.txt and shares no prefix with it
+	rec, err := DumpRecord(dbPath, EntryTypeFile, 1)
+	if err != nil {
+		t.Fatalf("DumpRecord failed: %v", err)
+	}
+
+	want := []byte{0, 10}
+	want = append(want, "readme.txt"...)
+	want = binary.LittleEndian.AppendUint64(want, 2048)
+	want = binary.LittleEndian.AppendUint64(want, mtime)
+	want = binary.LittleEndian.AppendUint32(want, 2)
+	if hex.EncodeToString(rec.Bytes) != hex.EncodeToString(want) {
+		t.Errorf("Record bytes:\n%s\nwant:\n%s", hex.Dump(rec.Bytes), hex.Dump(want))
+	}
+
+	if rec.Name != "readme.txt" || rec.NameOffset != 0 || rec.NameLen != 10 ||
+		rec.Size != 2048 || uint64(rec.MTime) != mtime || rec.Parent != 2 {
+		t.Errorf("Unexpected decoded fields: %+v", rec)
+	}
+
+	// The offset points at the same bytes in the file
+	raw, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := raw[rec.Offset : rec.Offset+int64(len(want))]; hex.EncodeToString(got) != hex.EncodeToString(want) {
+		t.Errorf("Bytes at offset %d do not match the record", rec.Offset)
+	}
+
+	// Downloads follows Documents and keeps their shared "Do" prefix
+	folder, err := DumpRecord(dbPath, EntryTypeFolder, 4)
+	if err != nil {
+		t.Fatalf("DumpRecord folder failed: %v", err)
+	}
+	if folder.Name != "Downloads" || folder.NameOffset != 2 || folder.NameLen != 7 || folder.Parent != 0 {
+		t.Errorf("Unexpected folder record: %+v", folder)
+	}
+
+	if _, err := DumpRecord(dbPath, EntryTypeFile, 99); err == nil {
+		t.Error("Expected error for out-of-range index")
+	}
+}
+
+func TestSearchMatchPathWeights(t *testing.T) {
+	db := buildDatabase(
+		"/projects/report/data.csv",
+		"/misc/old-report-draft-v2.txt",
+		"/misc/unrelated.txt",
+	)
+
+	order := func(nameWeight, pathWeight float64) []string {
+		result := mustSearch(t, db, SearchOptions{
+			Query:         "report",
+			SearchInFiles: true,
+			Score:         true,
+			MatchPath:     true,
+			NameWeight:    nameWeight,
+			PathWeight:    pathWeight,
+		})
+		sort.SliceStable(result.Files, func(i, j int) bool {
+			return result.Scores[result.Files[i]] > result.Scores[result.Files[j]]
+		})
+		var names []string
+		for _, file := range result.Files {
+			names = append(names, file.Name)
+		}
+		return names
+	}
+
+	// Default weights favour the name hit
+	if got := strings.Join(order(0, 0), ","); got != "old-report-draft-v2.txt,data.csv" {
+		t.Errorf("Default weights: got %s", got)
+	}
+	if got := strings.Join(order(DefaultNameWeight, DefaultPathWeight), ","); got != "old-report-draft-v2.txt,data.csv" {
+		t.Errorf("Explicit default weights: got %s", got)
+	}
+
+	// Favouring paths lifts the file inside the "report" folder
+	if got := strings.Join(order(1, 3), ","); got != "data.csv,old-report-draft-v2.txt" {
+		t.Errorf("Path-heavy weights: got %s", got)
+	}
+
+	// Without MatchPath, path-only hits are not found
+	result := mustSearch(t, db, SearchOptions{Query: "report", SearchInFiles: true})
+	if len(result.Files) != 1 || result.Files[0].Name != "old-report-draft-v2.txt" {
+		t.Errorf("Name-only search found %d files", len(result.Files))
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	source := func(prefix string, folders, files int) *SearchResult {
+		r := &SearchResult{}
+		for i := 0; i < folders; i++ {
+			r.Folders = append(r.Folders, &Folder{Entry: Entry{Name: fmt.Sprintf("%s-dir%d", prefix, i)}})
+		}
+		for i := 0; i < files; i++ {
+			r.Files = append(r.Files, &Entry{Name: fmt.Sprintf("%s-file%d", prefix, i)})
+		}
+		return r
+	}
+	contributions := func(merged *SearchResult) map[string]int {
+		counts := make(map[string]int)
+		for _, folder := range merged.Folders {
+			counts[folder.Name[:1]]++
+		}
+		for _, file := range merged.Files {
+			counts[file.Name[:1]]++
+		}
+		return counts
+	}
+
+	sources := func() []*SearchResult {
+		return []*SearchResult{source("a", 2, 8), source("b", 0, 2), source("c", 1, 4)}
+	}
+
+	tests := []struct {
+		name         string
+		perDB, total int
+		want         map[string]int
+	}{
+		{"unlimited", 0, 0, map[string]int{"a": 10, "b": 2, "c": 5}},
+		{"per database", 3, 0, map[string]int{"a": 3, "b": 2, "c": 3}},
+		{"per database and total", 3, 5, map[string]int{"a": 2, "b": 2, "c": 1}},
+		{"total only", 0, 6, map[string]int{"a": 2, "b": 2, "c": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contributions(MergeResults(sources(), tt.perDB, tt.total))
+			for k, n := range tt.want {
+				if got[k] != n {
+					t.Errorf("Source %s contributed %d, want %d (all: %v)", k, got[k], n, got)
+				}
+			}
+		})
+	}
+
+	// Folders are taken before files within a source
+	merged := MergeResults([]*SearchResult{source("a", 2, 8)}, 3, 0)
+	if len(merged.Folders) != 2 || len(merged.Files) != 1 || merged.Files[0].Name != "a-file0" {
+		t.Errorf("Expected both folders and the first file, got %d folders, %d files", len(merged.Folders), len(merged.Files))
+	}
+}
+
+func TestLoadMetadata(t *testing.T) {
+	dbPath := setupTestDB(t)
+	database, err := Load(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	meta, err := LoadMetadata(dbPath)
+	if err != nil {
+		t.Fatalf("LoadMetadata failed: %v", err)
+	}
+	if meta.Magic != MagicNumber || meta.MajorVersion != MajorVersion || meta.MinorVersion != MinorVersion {
+		t.Errorf("Unexpected format %s %d.%d", meta.Magic, meta.MajorVersion, meta.MinorVersion)
+	}
+	if meta.IndexFlags != database.IndexFlags {
+		t.Errorf("Expected index flags %v, got %v", database.IndexFlags, meta.IndexFlags)
+	}
+	if got := meta.IndexFlags.String(); got != "name|size|mtime" {
+		t.Errorf("Expected decoded flags name|size|mtime, got %s", got)
+	}
+	if int(meta.NumFolders) != len(database.Folders) || int(meta.NumFiles) != len(database.Files) {
+		t.Errorf("Expected %d folders and %d files, got %d and %d",
+			len(database.Folders), len(database.Files), meta.NumFolders, meta.NumFiles)
+	}
+
+	// The blocks follow the fixed-size header and metadata and fill the
+	// file up to the sorted array section
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const headerAndMetadata = HeaderSize + 8 + 4 + 4 + 8 + 8 + 4 + 4
+	if meta.FolderBlockSize == 0 || meta.FileBlockSize == 0 ||
+		headerAndMetadata+int64(meta.FolderBlockSize+meta.FileBlockSize) > info.Size() {
+		t.Errorf("Implausible block sizes %d and %d for a %d byte file",
+			meta.FolderBlockSize, meta.FileBlockSize, info.Size())
+	}
+
+	if _, err := LoadMetadata(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestIndexFlagsString(t *testing.T) {
+	tests := []struct {
+		flags IndexFlags
+		want  string
+	}{
+		{0, "none"},
+		{IndexFlagName | IndexFlagPath, "name|path"},
+		{IndexFlagSize | 1<<10, "size|0x400"},
+	}
+	for _, tt := range tests {
+		if got := tt.flags.String(); got != tt.want {
+			t.Errorf("IndexFlags(%d).String() = %q, want %q", uint64(tt.flags), got, tt.want)
+		}
+	}
+}
+
+func TestSearchContextCanceled(t *testing.T) {
+	db := buildDatabase(manyFiles(5000)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The matches found before the first cancellation check are kept
+	result, err := db.SearchContext(ctx, SearchOptions{Query: "file", SearchInFiles: true, SearchInFolders: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if n := len(result.Files); n == 0 || n >= 5000 {
+		t.Errorf("Expected a partial set of files, got %d of 5000", n)
+	}
+	if !result.Truncated {
+		t.Error("Expected a canceled search to be marked truncated")
+	}
+
+	result, err = db.SearchPathContext(ctx, PathSearchOptions{Pattern: "/data/"})
+	if !errors.Is(err, context.Canceled) || len(result.Files) == 0 || len(result.Files) >= 5000 || !result.Truncated {
+		t.Errorf("Expected partial, truncated path results; got %d files, err %v", len(result.Files), err)
+	}
+
+	// An uncanceled search sees everything
+	result, err = db.SearchContext(context.Background(), SearchOptions{Query: "file", SearchInFiles: true})
+	if err != nil || len(result.Files) != 5000 || result.Truncated {
+		t.Errorf("Expected all 5000 files, got %d (truncated %v, err %v)", len(result.Files), result.Truncated, err)
+	}
+}
+
+func TestCheckSortedArrays(t *testing.T) {
+	db := buildDatabase("/a/x.txt", "/a/y.txt", "/b/z.txt")
+	db.SortedArrays = map[uint32]*SortedArray{
+		// size order, a permutation of every entry
+		2: {ID: 2, Folders: []uint32{2, 0, 1}, Files: []uint32{1, 2, 0}},
+		// file index 1 repeated, file 0 missing
+		3: {ID: 3, Folders: []uint32{0, 1, 2}, Files: []uint32{1, 1, 2}},
+		// too short and of no known property
+		40: {ID: 40, Folders: []uint32{0}, Files: []uint32{0, 1, 2}},
+	}
+
+	statuses := db.CheckSortedArrays()
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 statuses, got %d", len(statuses))
+	}
+	want := []SortedArrayStatus{
+		{ID: 2, Key: "size", Folders: 3, Files: 3, Valid: true},
+		{ID: 3, Key: "mtime", Folders: 3, Files: 3, Problem: "files: index 1 repeated at position 1"},
+		{ID: 40, Key: "", Folders: 1, Files: 3, Problem: "folders: 1 indices for 3 entries"},
+	}
+	for i, w := range want {
+		if statuses[i] != w {
+			t.Errorf("Status %d: got %+v, want %+v", i, statuses[i], w)
+		}
+	}
+
+	if key := SortKey(0); key != "name" {
+		t.Errorf("Expected ID 0 to be name, got %q", key)
+	}
+
+	if arr, ok := db.SortOrder("size"); !ok || arr.ID != 2 {
+		t.Errorf("Expected the size array, got %v, %v", arr, ok)
+	}
+	// Present but invalid, and absent
+	for _, key := range []string{"mtime", "path", ""} {
+		if _, ok := db.SortOrder(key); ok {
+			t.Errorf("Expected no usable order for %q", key)
+		}
+	}
+}
+
+func TestSearchFoldAccents(t *testing.T) {
+	db := buildDatabase(
+		"/menu/café.txt",
+		"/menu/cafe\u0301-decomposed.txt",
+		"/menu/Crème brûlée.md",
+		"/menu/cafeteria.txt",
+		"/menu/smørrebrød.txt",
+	)
+	names := func(r *SearchResult) []string {
+		var out []string
+		for _, f := range r.Files {
+			out = append(out, f.Name)
+		}
+		return out
+	}
+	search := func(query string, foldAccents, caseSensitive bool) []string {
+		return names(mustSearch(t, db, SearchOptions{
+			Query: query, SearchInFiles: true, FoldAccents: foldAccents, CaseSensitive: caseSensitive,
+		}))
+	}
+
+	if got := search("cafe.", false, false); len(got) != 0 {
+		t.Errorf("Expected no accent-insensitive matches without FoldAccents, got %v", got)
+	}
+
+	tests := []struct {
+		query         string
+		caseSensitive bool
+		want          []string
+	}{
+		// Unaccented query, precomposed and decomposed names
+		{"cafe", false, []string{"café.txt", "cafe\u0301-decomposed.txt", "cafeteria.txt"}},
+		// Accented query finds unaccented names too
+		{"café", false, []string{"café.txt", "cafe\u0301-decomposed.txt", "cafeteria.txt"}},
+		// Composes with case-insensitivity
+		{"CREME BRULEE", false, []string{"Crème brûlée.md"}},
+		{"CREME BRULEE", true, nil},
+		{"Creme*", true, []string{"Crème brûlée.md"}},
+		// ø is a letter of its own, not an accented o
+		{"smorrebrod", false, nil},
+	}
+	for _, tt := range tests {
+		got := search(tt.query, true, tt.caseSensitive)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Search(%q, case=%v): got %v, want %v", tt.query, tt.caseSensitive, got, tt.want)
+		}
+	}
+
+	// Scores see the folded names, so a filtered search keeps its matches
+	scored := mustSearch(t, db, SearchOptions{Query: "cafe.txt", SearchInFiles: true, FoldAccents: true, MinScore: 0.9})
+	if got := names(scored); len(got) != 1 || got[0] != "café.txt" {
+		t.Errorf("Expected café.txt to score as an exact match, got %v", got)
+	}
+
+	paths := names(mustSearchPath(t, db, PathSearchOptions{Pattern: "/menu/creme", FoldAccents: true}))
+	if len(paths) != 1 || paths[0] != "Crème brûlée.md" {
+		t.Errorf("Expected path search to ignore accents, got %v", paths)
+	}
+}
+
+func TestEntryByPathAndAncestors(t *testing.T) {
+	database, err := Load(setupTestDB(t))
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	e, ok := database.EntryByPath("/home/user/test.txt")
+	if !ok {
+		t.Fatal("Expected to resolve /home/user/test.txt")
+	}
+	if e.Name != "test.txt" || e.Type != EntryTypeFile {
+		t.Errorf("Resolved the wrong entry: %+v", e)
+	}
+	var chain []string
+	for _, folder := range e.Ancestors() {
+		chain = append(chain, folder.GetFullPath())
+	}
+	if got := strings.Join(chain, ","); got != "/home/user,/home,/" {
+		t.Errorf("Expected ancestors /home/user,/home,/, got %s", got)
+	}
+
+	if folder, ok := database.EntryByPath("/home/user/"); !ok || folder.Type != EntryTypeFolder {
+		t.Error("Expected a trailing slash to resolve the folder")
+	}
+	root, ok := database.EntryByPath("/")
+	if !ok || len(root.Ancestors()) != 0 {
+		t.Error("Expected / to resolve to the root, which has no ancestors")
+	}
+	if _, ok := database.EntryByPath("/home/user/missing.txt"); ok {
+		t.Error("Expected a missing path not to resolve")
+	}
+}
+
+func TestSearchExactSize(t *testing.T) {
+	db := buildDatabase("/logs/empty.log", "/logs/a.log", "/logs/b.log", "/logs/c.log")
+	for i, size := range []int64{0, 4096, 4096, 4097} {
+		db.Files[i].Size = size
+	}
+	search := func(size int64) []string {
+		result := mustSearch(t, db, SearchOptions{Query: "*", SearchInFiles: true, SearchInFolders: true, ExactSize: &size})
+		if len(result.Folders) != 0 {
+			t.Errorf("Size %d: expected no folders, got %d", size, len(result.Folders))
+		}
+		var names []string
+		for _, f := range result.Files {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	if got := search(0); strings.Join(got, ",") != "empty.log" {
+		t.Errorf("Expected only the empty file, got %v", got)
+	}
+	if got := search(4096); strings.Join(got, ",") != "a.log,b.log" {
+		t.Errorf("Expected the two 4096-byte files, got %v", got)
+	}
+	if got := search(1); len(got) != 0 {
+		t.Errorf("Expected no 1-byte files, got %v", got)
+	}
+
+	// Without ExactSize every size matches, including zero
+	if n := len(mustSearch(t, db, SearchOptions{Query: "*", SearchInFiles: true}).Files); n != 4 {
+		t.Errorf("Expected all 4 files without a size filter, got %d", n)
+	}
+}
+
+func TestStalenessSince(t *testing.T) {
+	db := buildDatabase("/src/a.go", "/src/b.go", "/src/c.go")
+	db.IndexFlags = IndexFlagName | IndexFlagModificationTime
+	indexed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	db.Files[0].MTime = indexed.Add(-time.Hour)
+	db.Files[1].MTime = indexed.Add(time.Minute)
+	db.Files[2].MTime = indexed.Add(48 * time.Hour)
+
+	s := db.StalenessSince(indexed)
+	if !s.Indexed || s.Newer != 2 || !s.Newest.Equal(db.Files[2].MTime) {
+		t.Errorf("Expected 2 newer files, newest %v; got %+v", db.Files[2].MTime, s)
+	}
+	if s := db.StalenessSince(indexed.Add(72 * time.Hour)); s.Newer != 0 {
+		t.Errorf("Expected no files newer than a later index, got %d", s.Newer)
+	}
+
+	db.IndexFlags = IndexFlagName
+	if s := db.StalenessSince(indexed); s.Indexed || s.Newer != 0 {
+		t.Errorf("Expected nothing to compare without mtimes, got %+v", s)
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	db := buildDatabase("/src/main.go", "/src/main_test.go", "/src/Makefile", "/docs/café.md", "/src/a*b.txt")
+	search := func(opts SearchOptions) []string {
+		t.Helper()
+		opts.UseRegex, opts.SearchInFiles = true, true
+		result, err := db.SearchContext(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Query %q: unexpected error: %v", opts.Query, err)
+		}
+		var names []string
+		for _, f := range result.Files {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		opts SearchOptions
+		want string
+	}{
+		// Unanchored: matches anywhere in the name
+		{SearchOptions{Query: `_test`}, "main_test.go"},
+		{SearchOptions{Query: `^main(_test)?\.go$`}, "main.go,main_test.go"},
+		// * is a quantifier, not a wildcard
+		{SearchOptions{Query: `a\*b`}, "a*b.txt"},
+		{SearchOptions{Query: `^m`}, "main.go,main_test.go,Makefile"},
+		{SearchOptions{Query: `^m`, CaseSensitive: true}, "main.go,main_test.go"},
+		{SearchOptions{Query: `cafe\.`, FoldAccents: true}, "café.md"},
+		{SearchOptions{Query: `cafe\.`}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(search(tt.opts), ","); got != tt.want {
+			t.Errorf("Query %q: got %q, want %q", tt.opts.Query, got, tt.want)
+		}
+	}
+
+	// An invalid pattern is an error, not a substring match
+	result, err := db.SearchContext(context.Background(), SearchOptions{Query: "main[", UseRegex: true, SearchInFiles: true})
+	if err == nil || !strings.Contains(err.Error(), "invalid regular expression") {
+		t.Errorf("Expected an invalid regular expression error, got %v", err)
+	}
+	if len(result.Files) != 0 {
+		t.Errorf("Expected no matches for an invalid pattern, got %d", len(result.Files))
+	}
+	if _, err := db.Search(SearchOptions{Query: "main[", UseRegex: true, SearchInFiles: true}); err == nil {
+		t.Error("Expected Search to return the invalid regular expression error")
+	}
+}
+
+func TestSearchSizeRange(t *testing.T) {
+	db := buildDatabase("/logs/small.log", "/logs/medium.log", "/logs/large.log", "/big/", "/tiny/")
+	for i, size := range []int64{10, 100 << 20, 200 << 20} {
+		db.Files[i].Size = size
+	}
+	folder := func(name string) *Folder {
+		for _, f := range db.Folders {
+			if f.Name == name {
+				return f
+			}
+		}
+		t.Fatalf("No folder %q", name)
+		return nil
+	}
+	folder("big").Size = 500 << 20
+	folder("tiny").Size = 1
+
+	search := func(min, max int64) (files, folders []string) {
+		result := mustSearch(t, db, SearchOptions{Query: "*", SearchInFiles: true, SearchInFolders: true, MinSize: min, MaxSize: max})
+		for _, f := range result.Files {
+			files = append(files, f.Name)
+		}
+		for _, f := range result.Folders {
+			folders = append(folders, f.Name)
+		}
+		return files, folders
+	}
+
+	files, folders := search(100<<20, 0)
+	if strings.Join(files, ",") != "medium.log,large.log" {
+		t.Errorf("MinSize 100M: got files %v", files)
+	}
+	// Folder sizes are not indexed, so every folder still matches
+	if len(folders) != len(db.Folders) {
+		t.Errorf("Expected every folder without indexed sizes, got %v", folders)
+	}
+
+	if files, _ := search(0, 100<<20); strings.Join(files, ",") != "small.log,medium.log" {
+		t.Errorf("MaxSize 100M: got files %v", files)
+	}
+	if files, _ := search(11, 200<<20-1); strings.Join(files, ",") != "medium.log" {
+		t.Errorf("Range: got files %v", files)
+	}
+
+	// With sizes indexed, folders are filtered by their total size too
+	db.IndexFlags = IndexFlagName | IndexFlagSize
+	if _, folders := search(100<<20, 0); strings.Join(folders, ",") != "big" {
+		t.Errorf("Indexed sizes, MinSize 100M: got folders %v", folders)
+	}
+	if _, folders := search(1, 1); strings.Join(folders, ",") != "tiny" {
+		t.Errorf("Indexed sizes, exactly 1 byte: got folders %v", folders)
+	}
+}
+
+func TestSearchMTimeRange(t *testing.T) {
+	db := buildDatabase("/src/old.go", "/src/mid.go", "/src/new.go", "/src/unknown.go")
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	db.Files[0].MTime = base.AddDate(0, 0, -30)
+	db.Files[1].MTime = base
+	db.Files[2].MTime = base.AddDate(0, 0, 30)
+	db.Files[3].MTime = time.Unix(0, 0)
+
+	search := func(after, before time.Time) ([]string, error) {
+		result, err := db.SearchContext(context.Background(), SearchOptions{Query: "*.go", SearchInFiles: true, MTimeAfter: after, MTimeBefore: before})
+		var names []string
+		for _, f := range result.Files {
+			names = append(names, f.Name)
+		}
+		return names, err
+	}
+
+	// Without modification times in the index, filtering is an error
+	if _, err := search(base, time.Time{}); !errors.Is(err, ErrNoModificationTime) {
+		t.Fatalf("Expected ErrNoModificationTime, got %v", err)
+	}
+	db.IndexFlags = IndexFlagName | IndexFlagModificationTime
+
+	tests := []struct {
+		after, before time.Time
+		want          string
+	}{
+		{time.Time{}, time.Time{}, "old.go,mid.go,new.go,unknown.go"},
+		{base.Add(-time.Second), time.Time{}, "mid.go,new.go"},
+		// Bounds are exclusive
+		{base, time.Time{}, "new.go"},
+		{time.Time{}, base, "old.go"},
+		{base.AddDate(0, 0, -31), base.AddDate(0, 0, 31), "old.go,mid.go,new.go"},
+		{base.Add(-time.Hour), base.Add(time.Hour), "mid.go"},
+	}
+	for _, tt := range tests {
+		got, err := search(tt.after, tt.before)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("After %v, before %v: got %v, want %s", tt.after, tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	home := buildDatabase("/home/u/notes.txt", "/home/u/todo.txt")
+	home.IndexFlags = IndexFlagName | IndexFlagSize | IndexFlagModificationTime
+	media := buildDatabase("/media/disk/notes.txt")
+	media.IndexFlags = IndexFlagName | IndexFlagSize
+	media.SortedArrays = map[uint32]*SortedArray{2: {ID: 2}}
+
+	merged := Merge(home, media)
+	if len(merged.Files) != 3 || len(merged.Folders) != len(home.Folders)+len(media.Folders) {
+		t.Fatalf("Expected every entry of both, got %d files, %d folders", len(merged.Files), len(merged.Folders))
+	}
+	if merged.IndexFlags != IndexFlagName|IndexFlagSize {
+		t.Errorf("Expected only the flags both index, got %s", merged.IndexFlags)
+	}
+	if len(merged.SortedArrays) != 0 {
+		t.Errorf("Expected no sorted arrays, got %d", len(merged.SortedArrays))
+	}
+
+	// Entries keep the paths of the database they came from
+	var paths []string
+	for _, f := range mustSearch(t, merged, SearchOptions{Query: "notes", SearchInFiles: true}).Files {
+		paths = append(paths, f.GetFullPath())
+	}
+	if strings.Join(paths, ",") != "/home/u/notes.txt,/media/disk/notes.txt" {
+		t.Errorf("Unexpected paths %v", paths)
+	}
+	if n := len(mustSearchPath(t, merged, PathSearchOptions{Pattern: "/media/*"}).Files); n != 1 {
+		t.Errorf("Expected 1 file under /media, got %d", n)
+	}
+}
+
+func TestSearchParallelMatchesSequential(t *testing.T) {
+	files := manyFiles(3 * parallelMinEntries)
+	for i := 0; i < len(files); i += 7 {
+		files[i] = strings.TrimSuffix(files[i], ".txt") + ".log"
+	}
+	db := buildDatabase(files...)
+
+	// In result order, which must not depend on the workers
+	paths := func(result *SearchResult) []string {
+		var out []string
+		for _, f := range result.Folders {
+			out = append(out, f.GetFullPath())
+		}
+		for _, f := range result.Files {
+			out = append(out, f.GetFullPath())
+		}
+		return out
+	}
+
+	for _, opts := range []SearchOptions{
+		{Query: "file*7*", SearchInFiles: true, SearchInFolders: true},
+		{Query: "dir3", SearchInFolders: true},
+		{Query: "file", SearchInFiles: true, MaxResults: 5000},
+		{Query: "file", SearchInFiles: true, MaxPerExtension: 100},
+		{Query: "file00", SearchInFiles: true, MinScore: 1},
+	} {
+		opts.Workers = 1
+		want := paths(mustSearch(t, db, opts))
+		opts.Workers = 8
+		got := paths(mustSearch(t, db, opts))
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%+v: %d parallel results differ from %d sequential", opts, len(got), len(want))
+		}
+	}
+}
+
+func TestEachMatchStopsEarly(t *testing.T) {
+	const workers = 2
+	n := 100 * workers * 8 * cancelCheckInterval
+	var matched atomic.Int64
+	err := eachMatch(context.Background(), n, workers, func(int) bool {
+		matched.Add(1)
+		return true
+	}, func(int) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := matched.Load(); got > int64(n/10) {
+		t.Errorf("Matched %d of %d entries after emit stopped", got, n)
+	}
+}
+
+// BenchmarkSearchParallel searches a million entries on one goroutine and
+// on one per CPU
+func BenchmarkSearchParallel(b *testing.B) {
+	db := buildDatabase(manyFiles(1000000)...)
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mustSearch(b, db, SearchOptions{Query: "file*42*", SearchInFiles: true, Workers: workers})
+			}
+		})
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		name, query string
+		want        float64
+	}{
+		{"readme.txt", "rdme", 0.1833},         // 4 of 6 characters, from the start
+		{"read-only mode.txt", "rdme", 0.1071}, // spread over 14 characters
+		{"my_readme.txt", "rdme", 0.1333},      // not from the start
+		{"README.md", "rdme", 0.1833},          // case ignored
+		{"readme.txt", "readme", Score("readme.txt", "readme", false)},
+		{"readme.txt", "rdmz", 0},
+		{"readme.txt", "emdr", 0},
+	}
+	for _, tt := range tests {
+		if got := FuzzyScore(tt.name, tt.query, false); got != tt.want {
+			t.Errorf("FuzzyScore(%q, %q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+	if got := FuzzyScore("README.md", "rdme", true); got != 0 {
+		t.Errorf("Case-sensitive FuzzyScore = %v, want 0", got)
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	db := buildDatabase("/docs/readme.txt", "/docs/read-only mode.txt", "/docs/notes.txt", "/rdme/")
+
+	result := mustSearch(t, db, SearchOptions{Query: "rdme", Fuzzy: true, SearchInFiles: true, SearchInFolders: true})
+	if len(result.Files) != 2 || len(result.Folders) != 1 {
+		t.Fatalf("Expected 2 files and 1 folder, got %d and %d", len(result.Files), len(result.Folders))
+	}
+	readme, readOnly := result.Files[0], result.Files[1]
+	if result.Scores[readme] <= result.Scores[readOnly] {
+		t.Errorf("Expected readme.txt (%v) to outscore %s (%v)", result.Scores[readme], readOnly.Name, result.Scores[readOnly])
+	}
+	// The substring match outranks every fuzzy one
+	if s := result.Scores[&result.Folders[0].Entry]; s != scoreExact {
+		t.Errorf("Exact folder match scored %v", s)
+	}
+
+	strong := mustSearch(t, db, SearchOptions{Query: "rdme", Fuzzy: true, SearchInFiles: true, MinScore: 0.15})
+	if len(strong.Files) != 1 || strong.Files[0].Name != "readme.txt" {
+		t.Errorf("MinScore 0.15 kept %d files, want only readme.txt", len(strong.Files))
+	}
+}
+
+func TestSearchBoolean(t *testing.T) {
+	db := buildDatabase(
+		"/docs/invoice-2024.pdf",
+		"/docs/invoice-2024-draft.pdf",
+		"/docs/invoice-2023.pdf",
+		"/docs/receipt-2024.txt",
+		"/docs/AND notes.txt",
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"invoice AND 2024 NOT draft", []string{"invoice-2024.pdf"}},
+		{"invoice 2024 NOT draft", []string{"invoice-2024.pdf"}},
+		{"invoice NOT 2024", []string{"invoice-2023.pdf"}},
+		{"receipt OR draft", []string{"invoice-2024-draft.pdf", "receipt-2024.txt"}},
+		// AND binds tighter than OR
+		{"receipt OR invoice 2023", []string{"invoice-2023.pdf", "receipt-2024.txt"}},
+		{"(receipt OR invoice) 2023", []string{"invoice-2023.pdf"}},
+		{"NOT NOT receipt", []string{"receipt-2024.txt"}},
+		// Wildcards within terms
+		{"*.pdf NOT *draft*", []string{"invoice-2024.pdf", "invoice-2023.pdf"}},
+		// Quoting makes operators and spaces literal
+		{`"AND notes"`, []string{"AND notes.txt"}},
+		{`"AND" OR receipt`, []string{"receipt-2024.txt", "AND notes.txt"}},
+		// Lower case words are terms
+		{"and notes", []string{"AND notes.txt"}},
+	}
+	for _, tt := range tests {
+		result, err := db.SearchContext(context.Background(), SearchOptions{Query: tt.query, Boolean: true, SearchInFiles: true})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, f := range result.Files {
+			got = append(got, f.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s matched %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"AND invoice", "invoice OR", "NOT", "(invoice", "invoice)", "()", `"invoice`, `""`} {
+		if _, err := db.SearchContext(context.Background(), SearchOptions{Query: query, Boolean: true, SearchInFiles: true}); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+
+	// Scores come from the best term outside NOT
+	scored := mustSearch(t, db, SearchOptions{Query: "receipt-2024.txt OR invoice NOT 2023", Boolean: true, SearchInFiles: true, Score: true})
+	for _, f := range scored.Files {
+		if f.Name == "receipt-2024.txt" && scored.Scores[f] != scoreExact {
+			t.Errorf("Exact term scored %v", scored.Scores[f])
+		}
+		if f.Name == "invoice-2023.pdf" {
+			t.Error("NOT term matched")
+		}
+	}
+}
+
+func TestSearchPathMaxDepth(t *testing.T) {
+	db := buildDatabase(
+		"/home/user/notes.txt",
+		"/home/todo.txt",
+		"/homework/essay.txt",
+		"/srv/data/",
+		"/srv/[a]/b.txt",
+	)
+	depth := func(n int) *int { return &n }
+
+	tests := []struct {
+		name string
+		opts PathSearchOptions
+		want []string
+	}{
+		{"prefix only", PathSearchOptions{Pattern: "/home", MaxDepth: depth(0)}, []string{"/home", "/homework"}},
+		{"direct children", PathSearchOptions{Pattern: "/home", MaxDepth: depth(1)},
+			[]string{"/home", "/home/todo.txt", "/home/user", "/homework", "/homework/essay.txt"}},
+		{"segments", PathSearchOptions{Pattern: "home", SegmentMatch: true, MaxDepth: depth(1)},
+			[]string{"/home", "/home/todo.txt", "/home/user"}},
+		{"trailing slash", PathSearchOptions{Pattern: "/home/", MaxDepth: depth(1)},
+			[]string{"/home/todo.txt", "/home/user"}},
+		{"wildcard", PathSearchOptions{Pattern: "/home/*", MaxDepth: depth(1)},
+			[]string{"/home/todo.txt", "/home/user"}},
+		{"class", PathSearchOptions{Pattern: "/home/[a-z]*", MaxDepth: depth(1)},
+			[]string{"/home/todo.txt", "/home/user"}},
+		{"escaped brackets", PathSearchOptions{Pattern: `/srv/\[a\]*`, MaxDepth: depth(0)},
+			[]string{"/srv/[a]"}},
+		{"root", PathSearchOptions{Pattern: "/", MaxDepth: depth(1)},
+			[]string{"/", "/home", "/homework", "/srv"}},
+		{"root only", PathSearchOptions{Pattern: "/", MaxDepth: depth(0)}, []string{"/"}},
+		{"unlimited", PathSearchOptions{Pattern: "/home/"}, []string{"/home/todo.txt", "/home/user", "/home/user/notes.txt"}},
+		{"below the root", PathSearchOptions{Pattern: "/home/", MaxRootDepth: 2}, []string{"/home/todo.txt", "/home/user"}},
+		{"both depths", PathSearchOptions{Pattern: "/", MaxDepth: depth(2), MaxRootDepth: 1},
+			[]string{"/", "/home", "/homework", "/srv"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustSearchPath(t, db, tt.opts)
+			var got []string
+			for _, f := range result.Folders {
+				got = append(got, f.GetFullPath())
+			}
+			for _, f := range result.Files {
+				got = append(got, f.GetFullPath())
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchBooleanFollow(t *testing.T) {
+	db := buildDatabase("/photos-2024/a.jpg", "/photos-2023/b.jpg", "/docs/c.txt")
+
+	result := mustSearch(t, db, SearchOptions{Query: "photos NOT 2023", Boolean: true, SearchInFiles: true, Follow: true})
+	if len(result.Files) != 1 || result.Files[0].Name != "a.jpg" {
+		t.Errorf("Expected only a.jpg from the followed folder, got %d files", len(result.Files))
+	}
+}
+
+func TestSearchExcludePatterns(t *testing.T) {
+	db := buildDatabase(
+		"/var/log/app.log",
+		"/var/tmp/app.log",
+		"/home/tmp/debug.LOG",
+		"/home/old.log",
+		"/home/keep/",
+	)
+	names := func(opts SearchOptions) []string {
+		var out []string
+		for _, f := range mustSearch(t, db, opts).Files {
+			out = append(out, f.GetFullPath())
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"path pattern", SearchOptions{Query: "*.log", ExcludePatterns: []string{"*/tmp/*"}},
+			[]string{"/var/log/app.log", "/home/old.log"}},
+		{"name pattern", SearchOptions{Query: "*.log", ExcludePatterns: []string{"old*", "debug*"}},
+			[]string{"/var/log/app.log", "/var/tmp/app.log"}},
+		{"case sensitive", SearchOptions{Query: "*.log", CaseSensitive: true, ExcludePatterns: []string{"*.LOG"}},
+			[]string{"/var/log/app.log", "/var/tmp/app.log", "/home/old.log"}},
+		{"match path", SearchOptions{Query: "*.log", ExcludePatterns: []string{"*home*"}, ExcludeMatchPath: true},
+			[]string{"/var/log/app.log", "/var/tmp/app.log"}},
+		{"follow", SearchOptions{Query: "tmp", Follow: true, ExcludePatterns: []string{"*.log"}}, nil},
+	}
+	for _, tt := range tests {
+		tt.opts.SearchInFiles = true
+		if got := names(tt.opts); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	db := buildDatabase(manyFiles(3 * parallelMinEntries)...)
+	opts := SearchOptions{Query: "*1*", SearchInFiles: true, SearchInFolders: true, Score: true}
+
+	want := mustSearch(t, db, opts)
+	var files []*Entry
+	var folders []*Folder
+	err := db.SearchStream(opts, func(m Match) bool {
+		if m.Folder != nil {
+			folders = append(folders, m.Folder)
+		} else {
+			if len(folders) > 0 {
+				t.Fatal("File streamed after a folder")
+			}
+			files = append(files, m.Entry)
+		}
+		if m.Score != want.Scores[m.Entry] {
+			t.Fatalf("%s scored %v, want %v", m.Entry.Name, m.Score, want.Scores[m.Entry])
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, want.Files) || !reflect.DeepEqual(folders, want.Folders) {
+		t.Errorf("Streamed %d files and %d folders, Search found %d and %d", len(files), len(folders), len(want.Files), len(want.Folders))
+	}
+
+	// Returning false stops the search
+	n := 0
+	db.SearchStream(opts, func(Match) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Errorf("Stream continued to %d matches after being stopped at 10", n)
+	}
+
+	// MaxResults counts files and folders together
+	limited := mustSearch(t, db, SearchOptions{Query: "1", SearchInFiles: true, SearchInFolders: true, MaxResults: 3})
+	if got := len(limited.Files) + len(limited.Folders); got != 3 || !limited.Truncated {
+		t.Errorf("MaxResults 3 gave %d results, truncated %v", got, limited.Truncated)
+	}
+
+	// Reaching MaxResults with nothing left over is not truncation
+	all := mustSearch(t, db, SearchOptions{Query: "1", SearchInFiles: true, SearchInFolders: true})
+	total := len(all.Files) + len(all.Folders)
+	exact := mustSearch(t, db, SearchOptions{Query: "1", SearchInFiles: true, SearchInFolders: true, MaxResults: total})
+	if got := len(exact.Files) + len(exact.Folders); got != total || exact.Truncated {
+		t.Errorf("MaxResults %d gave %d results, truncated %v", total, got, exact.Truncated)
+	}
+	filesOnly := mustSearch(t, db, SearchOptions{Query: "1", SearchInFiles: true, SearchInFolders: true, MaxResults: len(all.Files)})
+	if len(all.Folders) > 0 && !filesOnly.Truncated {
+		t.Errorf("MaxResults %d left out the folders but was not truncated", len(all.Files))
+	}
+
+	// FoldersFirst streams the folders ahead of the files, and MaxResults
+	// then keeps them first
+	opts.FoldersFirst = true
+	var order []*Entry
+	if err := db.SearchStream(opts, func(m Match) bool {
+		if m.Folder != nil && len(order) > 0 && order[len(order)-1].Type == EntryTypeFile {
+			t.Fatal("Folder streamed after a file")
+		}
+		order = append(order, m.Entry)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != len(want.Files)+len(want.Folders) || order[0] != &want.Folders[0].Entry {
+		t.Errorf("FoldersFirst streamed %d entries starting with %s", len(order), order[0].Name)
+	}
+	foldersFirst := mustSearch(t, db, SearchOptions{Query: "1", SearchInFiles: true, SearchInFolders: true, FoldersFirst: true, MaxResults: len(all.Folders)})
+	if !reflect.DeepEqual(foldersFirst.Folders, all.Folders) || len(foldersFirst.Files) != 0 || !foldersFirst.Truncated {
+		t.Errorf("FoldersFirst MaxResults %d kept %d folders and %d files", len(all.Folders), len(foldersFirst.Folders), len(foldersFirst.Files))
+	}
+}
+
+func TestMatchRange(t *testing.T) {
+	db := buildDatabase("/docs/", "/docs/My-Report.pdf", "/docs/reporting.txt", "/docs/ÄRGER.md")
+	byName := func(name string) *Entry {
+		for _, file := range db.Files {
+			if file.Name == name {
+				return file
+			}
+		}
+		t.Fatalf("no file %s", name)
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		file string
+		want *MatchRange
+	}{
+		{"substring", SearchOptions{Query: "report"}, "My-Report.pdf", &MatchRange{3, 9}},
+		{"case sensitive", SearchOptions{Query: "report", CaseSensitive: true}, "My-Report.pdf", nil},
+		{"whole word", SearchOptions{Query: "report", MatchWholeWord: true}, "My-Report.pdf", &MatchRange{3, 9}},
+		{"not a whole word", SearchOptions{Query: "report", MatchWholeWord: true}, "reporting.txt", nil},
+		{"multibyte", SearchOptions{Query: "ärger"}, "ÄRGER.md", &MatchRange{0, 6}},
+		{"wildcard spans the name", SearchOptions{Query: "*.pdf"}, "My-Report.pdf", &MatchRange{0, 13}},
+		{"regex", SearchOptions{Query: "p.r", UseRegex: true}, "My-Report.pdf", &MatchRange{5, 8}},
+		{"stem", SearchOptions{Query: "reporting", MatchStem: true}, "reporting.txt", &MatchRange{0, 9}},
+		{"no match", SearchOptions{Query: "zzz"}, "My-Report.pdf", nil},
+		{"fuzzy", SearchOptions{Query: "mrp", Fuzzy: true}, "My-Report.pdf", nil},
+	}
+	for _, tt := range tests {
+		r, ok := db.MatchRange(byName(tt.file), tt.opts)
+		if (tt.want == nil) != !ok || ok && r != *tt.want {
+			t.Errorf("%s: MatchRange = %v, %v, want %v", tt.name, r, ok, tt.want)
+		}
+	}
+
+	// Search reports the ranges when asked to, and only then
+	opts := SearchOptions{Query: "report", SearchInFiles: true}
+	if result := mustSearch(t, db, opts); result.Ranges != nil {
+		t.Errorf("Ranges set without MatchRanges: %v", result.Ranges)
+	}
+	opts.MatchRanges = true
+	result := mustSearch(t, db, opts)
+	want := map[*Entry]MatchRange{
+		byName("My-Report.pdf"): {3, 9},
+		byName("reporting.txt"): {0, 6},
+	}
+	if !reflect.DeepEqual(result.Ranges, want) {
+		t.Errorf("Ranges = %v, want %v", result.Ranges, want)
+	}
+	db.SearchStream(opts, func(m Match) bool {
+		if m.Range == nil || *m.Range != want[m.Entry] {
+			t.Errorf("Streamed %s with range %v, want %v", m.Entry.Name, m.Range, want[m.Entry])
+		}
+		return true
+	})
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		text, query   string
+		caseSensitive bool
+		start, end    int
+	}{
+		{"report.pdf", "port", false, 2, 6},
+		{"REPORT.pdf", "port", false, 2, 6},
+		{"REPORT.pdf", "port", true, -1, -1},
+		{"Straße.txt", "SSE", false, -1, -1},
+		{"ÄRGER.txt", "ärger", false, 0, 6},
+		{"a", "", false, -1, -1},
+	}
+	for _, tt := range tests {
+		start, end := IndexFold(tt.text, tt.query, tt.caseSensitive)
+		if start != tt.start || end != tt.end {
+			t.Errorf("IndexFold(%q, %q, %v) = %d, %d, want %d, %d",
+				tt.text, tt.query, tt.caseSensitive, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestSearchPathPattern(t *testing.T) {
+	db := buildDatabase("/var/", "/var/log/", "/var/log/syslog.log", "/var/app.log", "/home/", "/home/me/", "/home/me/build.log", "/home/me/var.log")
+	names := func(result *SearchResult) []string {
+		var got []string
+		for _, f := range result.Files {
+			got = append(got, f.Name)
+		}
+		for _, f := range result.Folders {
+			got = append(got, f.Name+"/")
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"wildcard path", SearchOptions{Query: "*.log", PathPattern: "/var/*"}, []string{"syslog.log", "app.log"}},
+		{"plain path", SearchOptions{Query: "*.log", PathPattern: "me/"}, []string{"build.log", "var.log"}},
+		{"case ignored", SearchOptions{Query: "*.log", PathPattern: "/VAR/LOG/*"}, []string{"syslog.log"}},
+		{"case sensitive", SearchOptions{Query: "*.log", PathPattern: "/VAR/*", CaseSensitive: true}, nil},
+		{"folders too", SearchOptions{Query: "log", PathPattern: "/var/*", SearchInFolders: true}, []string{"syslog.log", "app.log", "log/"}},
+		{"followed entries", SearchOptions{Query: "me", PathPattern: "*var*", Follow: true}, []string{"var.log"}},
+	}
+	for _, tt := range tests {
+		tt.opts.SearchInFiles = true
+		if got := names(mustSearch(t, db, tt.opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// RawRecord is the on-disk form of a single folder or file record together
+// with its decoded fields. It is meant for diagnosing format issues.
+type RawRecord struct {
+	Type   EntryType
+	Index  uint32
+	Offset int64  // position of the record from the start of the file
+	Bytes  []byte // the record exactly as stored
+
+	DBIndex    uint16 // folders only
+	NameOffset uint8  // bytes kept from the previous record's name
+	NameLen    uint8  // bytes appended to them
+	Name       string // the resulting full name
+	Size       int64  // only if Flags has IndexFlagSize
+	MTime      int64  // Unix seconds, only if Flags has IndexFlagModificationTime
+
+	// Unix seconds, each only if Flags has its index flag
+	ATime            int64
+	CTime            int64
+	StatusChangeTime int64
+
+	Parent uint32 // folder index; a folder's own index marks a root
+	Flags  IndexFlags
+}
+
+// DumpRecord re-reads the database at filePath and returns the raw record of
+// the folder or file at index. Records are variable-length and names are
+// delta-compressed against their predecessor, so every earlier record in the
+// block is decoded to find it.
+func DumpRecord(filePath string, typ EntryType, index uint32) (*RawRecord, error) {
+	file, closeFile, err := openDatabase(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	db := &Database{}
+	if err := db.readHeader(file); err != nil {
+		return nil, err
+	}
+	if err := db.readMetadata(file); err != nil {
+		return nil, err
+	}
+
+	count, blockSize := db.metadata.numFiles, db.metadata.fileBlockSize
+	if typ == EntryTypeFolder {
+		count, blockSize = db.metadata.numFolders, db.metadata.folderBlockSize
+	}
+	if index >= count {
+		return nil, fmt.Errorf("index %d out of range: database has %d entries of that type", index, count)
+	}
+
+	blockStart, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if typ == EntryTypeFile {
+		blockStart += int64(db.metadata.folderBlockSize)
+	}
+	block := make([]byte, blockSize)
+	if _, err := file.ReadAt(block, blockStart); err != nil {
+		return nil, fmt.Errorf("failed to read block: %w", err)
+	}
+
+	offset := 0
+	previousName := ""
+	for i := uint32(0); ; i++ {
+		rec, next, err := db.scanRecord(block, offset, previousName, typ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record %d: %w", i, err)
+		}
+		if i == index {
+			rec.Index = i
+			rec.Offset = blockStart + int64(offset)
+			return rec, nil
+		}
+		offset, previousName = next, rec.Name
+	}
+}
+
+// scanRecord decodes the record starting at offset in a folder or file
+// block, returning it and the offset of the record that follows
+func (db *Database) scanRecord(block []byte, offset int, previousName string, typ EntryType) (*RawRecord, int, error) {
+	start := offset
+	rec := &RawRecord{Type: typ, Flags: db.IndexFlags}
+
+	if typ == EntryTypeFolder {
+		if offset+2 > len(block) {
+			return nil, offset, fmt.Errorf("block truncated at db_index")
+		}
+		rec.DBIndex = binary.LittleEndian.Uint16(block[offset:])
+		offset += 2
+	}
+
+	if offset+2 <= len(block) {
+		rec.NameOffset, rec.NameLen = block[offset], block[offset+1]
+	}
+	var err error
+	rec.Name, offset, err = db.readDeltaName(block, offset, previousName)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	if db.IndexFlags&IndexFlagSize != 0 {
+		if offset+8 > len(block) {
+			return nil, offset, fmt.Errorf("block truncated at size")
+		}
+		rec.Size = int64(binary.LittleEndian.Uint64(block[offset:]))
+		offset += 8
+	}
+	if db.IndexFlags&IndexFlagModificationTime != 0 {
+		if offset+8 > len(block) {
+			return nil, offset, fmt.Errorf("block truncated at mtime")
+		}
+		rec.MTime = int64(binary.LittleEndian.Uint64(block[offset:]))
+		offset += 8
+	}
+	for _, t := range extraTimes {
+		if db.IndexFlags&t.flag == 0 {
+			continue
+		}
+		if offset+8 > len(block) {
+			return nil, offset, fmt.Errorf("block truncated at %s", t.name)
+		}
+		*t.raw(rec) = int64(binary.LittleEndian.Uint64(block[offset:]))
+		offset += 8
+	}
+
+	if offset+4 > len(block) {
+		return nil, offset, fmt.Errorf("block truncated at parent")
+	}
+	rec.Parent = binary.LittleEndian.Uint32(block[offset:])
+	offset += 4
+
+	rec.Bytes = block[start:offset]
+	return rec, offset, nil
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+)
+
+// foldCasers hands out Unicode case folders. A Caser keeps state between
+// calls and entries are matched from several goroutines, so each call
+// borrows one of its own.
+var foldCasers = sync.Pool{New: func() any {
+	c := cases.Fold()
+	return &c
+}}
+
+// foldCase is the case folding used for case-insensitive matching when
+// SearchOptions.Fold is nil. It applies full Unicode case folding, so
+// "CAFÉ" matches "café" and "STRASSE" matches "straße". ASCII text, which
+// folds to its lowercase form, skips the folder.
+func foldCase(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	c := foldCasers.Get().(*cases.Caser)
+	defer foldCasers.Put(c)
+	return c.String(s)
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchRange is the byte range [Start, End) of an entry's Name that a
+// query matched
+type MatchRange struct {
+	Start, End int
+}
+
+// MatchRange returns where opts.Query matches the name of e. A wildcard
+// pattern matches the whole name, a regular expression where it first
+// matches, and plain text where it first occurs, as a whole word with
+// MatchWholeWord; with MatchStem only the stem is matched. ok is false
+// when the name does not match or the match cannot be placed in it: for
+// fuzzy and boolean queries, brace alternatives, and FoldAccents, or where
+// only normalization or a custom Fold makes the name match.
+func (db *Database) MatchRange(e *Entry, opts SearchOptions) (MatchRange, bool) {
+	opts, err := db.prepareSearch(opts)
+	if err != nil {
+		return MatchRange{}, false
+	}
+	return db.matchRange(e, opts)
+}
+
+// matchRangeOf returns the range matchRange finds for prepared opts, or nil
+// without MatchRanges or a range
+func (db *Database) matchRangeOf(e *Entry, opts SearchOptions) *MatchRange {
+	if !opts.MatchRanges {
+		return nil
+	}
+	r, ok := db.matchRange(e, opts)
+	if !ok {
+		return nil
+	}
+	return &r
+}
+
+// matchRange is MatchRange for prepared opts
+func (db *Database) matchRange(e *Entry, opts SearchOptions) (MatchRange, bool) {
+	if opts.Fuzzy || opts.expr != nil || opts.FoldAccents || opts.Query == "" {
+		return MatchRange{}, false
+	}
+	text := e.Name
+	if opts.MatchStem {
+		text = stem(text)
+	}
+
+	switch {
+	case opts.re != nil:
+		if loc := opts.re.FindStringIndex(text); loc != nil {
+			return MatchRange{loc[0], loc[1]}, true
+		}
+	case hasWildcards(opts.Query):
+		if db.matches(text, opts.Query, opts) {
+			return MatchRange{0, len(text)}, true
+		}
+	case opts.MatchStem && !opts.MatchWholeWord:
+		if db.matchesStem(text, opts.Query, opts) {
+			return MatchRange{0, len(text)}, true
+		}
+	default:
+		for from := 0; from < len(text); {
+			start, end := IndexFold(text[from:], opts.Query, opts.CaseSensitive)
+			if start < 0 {
+				break
+			}
+			start, end = start+from, end+from
+			if !opts.MatchWholeWord || isWholeWord(text, start, end) {
+				return MatchRange{start, end}, true
+			}
+			_, size := utf8.DecodeRuneInString(text[start:])
+			from = start + size
+		}
+	}
+	return MatchRange{}, false
+}
+
+// IndexFold returns the byte range of the first occurrence of query in
+// text, ignoring case unless caseSensitive, or -1, -1 if there is none or
+// query is empty. Case is compared rune by rune, so the range is right even
+// where changing case changes the length of a rune. That is simple folding,
+// as strings.EqualFold does it: unlike the full folding of case-insensitive
+// search, it does not find "SSE" in "Straße".
+func IndexFold(text, query string, caseSensitive bool) (int, int) {
+	if query == "" {
+		return -1, -1
+	}
+	if caseSensitive {
+		i := strings.Index(text, query)
+		if i < 0 {
+			return -1, -1
+		}
+		return i, i + len(query)
+	}
+	n := utf8.RuneCountInString(query)
+	for start := 0; start < len(text); {
+		end := start
+		for k := 0; k < n && end < len(text); k++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		if strings.EqualFold(text[start:end], query) {
+			return start, end
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+	return -1, -1
+}
@@ -0,0 +1,131 @@
+package db
+
+// MergeResults combines the results of searching several databases into one.
+// Each source contributes at most maxPerSource entries (0 = unlimited), so a
+// single large database cannot crowd out the others. If the total still
+// exceeds maxTotal (0 = unlimited), the remaining slots are dealt out one per
+// source in turn. Within a source, entries are taken in output order:
+// folders first, then files.
+func MergeResults(results []*SearchResult, maxPerSource, maxTotal int) *SearchResult {
+	quotas := make([]int, len(results))
+	total := 0
+	for i, r := range results {
+		quotas[i] = len(r.Folders) + len(r.Files)
+		if maxPerSource > 0 && quotas[i] > maxPerSource {
+			quotas[i] = maxPerSource
+		}
+		total += quotas[i]
+	}
+	if maxTotal > 0 && total > maxTotal {
+		quotas = dealQuotas(quotas, maxTotal)
+	}
+
+	merged := &SearchResult{
+		Files:   make([]*Entry, 0),
+		Folders: make([]*Folder, 0),
+	}
+	for i, r := range results {
+		n := quotas[i]
+		if r.Truncated || n < len(r.Folders)+len(r.Files) {
+			merged.Truncated = true
+		}
+		folders := r.Folders
+		if len(folders) > n {
+			folders = folders[:n]
+		}
+		files := r.Files
+		if rest := n - len(folders); len(files) > rest {
+			files = files[:rest]
+		}
+		merged.Folders = append(merged.Folders, folders...)
+		merged.Files = append(merged.Files, files...)
+
+		if r.Scores != nil {
+			if merged.Scores == nil {
+				merged.Scores = make(map[*Entry]float64)
+			}
+			for _, folder := range folders {
+				merged.Scores[&folder.Entry] = r.Scores[&folder.Entry]
+			}
+			for _, file := range files {
+				merged.Scores[file] = r.Scores[file]
+			}
+		}
+		if r.Ranges != nil {
+			if merged.Ranges == nil {
+				merged.Ranges = make(map[*Entry]MatchRange)
+			}
+			for _, folder := range folders {
+				if rng, ok := r.Ranges[&folder.Entry]; ok {
+					merged.Ranges[&folder.Entry] = rng
+				}
+			}
+			for _, file := range files {
+				if rng, ok := r.Ranges[file]; ok {
+					merged.Ranges[file] = rng
+				}
+			}
+		}
+	}
+	// Paths go through a database's cache only when every entry is from it
+	if len(results) > 0 {
+		merged.db = results[0].db
+		for _, r := range results[1:] {
+			if r.db != merged.db {
+				merged.db = nil
+			}
+		}
+	}
+	return merged
+}
+
+// dealQuotas hands out n slots one per source in turn, never giving a
+// source more than its available count
+func dealQuotas(available []int, n int) []int {
+	quotas := make([]int, len(available))
+	for n > 0 {
+		progressed := false
+		for i := range quotas {
+			if n == 0 {
+				break
+			}
+			if quotas[i] < available[i] {
+				quotas[i]++
+				n--
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return quotas
+}
+
+// Merge combines several databases into one that lists the folders and
+// files of each in turn. Entries are shared with their source databases,
+// not copied, so each keeps its parent chain and full path; their Index
+// fields still refer to the source. The merged IndexFlags are the
+// properties every source indexes. Sorted arrays index a single database,
+// so the merged one has none.
+func Merge(dbs ...*Database) *Database {
+	merged := &Database{
+		SortedArrays: make(map[uint32]*SortedArray),
+		pathCache:    newPathCache(DefaultPathCacheSize),
+	}
+	for i, d := range dbs {
+		if i == 0 {
+			merged.IndexFlags = d.IndexFlags
+		} else {
+			merged.IndexFlags &= d.IndexFlags
+		}
+		merged.Folders = append(merged.Folders, d.Folders...)
+		merged.Files = append(merged.Files, d.Files...)
+		merged.Timings.Open += d.Timings.Open
+		merged.Timings.Header += d.Timings.Header
+		merged.Timings.Folders += d.Timings.Folders
+		merged.Timings.Files += d.Timings.Files
+		merged.Timings.SortedArrays += d.Timings.SortedArrays
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultPathCacheSize is the number of full paths kept by default
+const DefaultPathCacheSize = 100000
+
+// pathCache is a size-bounded least-recently-used cache of full paths.
+// Looking up an entry's path also refreshes its parents, so the folders
+// shared by many results stay cached while one-off file paths age out.
+type pathCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	items    map[*Entry]*list.Element
+}
+
+type pathCacheItem struct {
+	entry *Entry
+	path  string
+}
+
+// newPathCache returns a cache holding at most capacity paths; a capacity of
+// 0 disables caching
+func newPathCache(capacity int) *pathCache {
+	return &pathCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[*Entry]*list.Element),
+	}
+}
+
+func (c *pathCache) get(e *Entry) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[e]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*pathCacheItem).path, true
+}
+
+func (c *pathCache) add(e *Entry, path string) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[e]; ok {
+		el.Value.(*pathCacheItem).path = path
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*pathCacheItem).entry)
+	}
+	c.items[e] = c.order.PushFront(&pathCacheItem{entry: e, path: path})
+}
+
+func (c *pathCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
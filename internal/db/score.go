@@ -0,0 +1,198 @@
+package db
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Relevance scores range from 0 to 1. A name equal to the query scores 1.
+// Other matches get a base score from where the query occurs: 0.7 at the
+// start of the name, 0.5 at the start of a later word, 0.3 anywhere else.
+// Up to 0.25 is added for how much of the name the query covers, so "report"
+// ranks report.pdf above annual-report-2023-final.pdf. Wildcard matches use
+// the 0.3 base and count only the literal characters of the pattern. Scores are rounded to four decimal places so they compare
+// and print identically across runs.
+const (
+	scoreExact     = 1.0
+	scorePrefix    = 0.7  // name starts with the query
+	scoreWordStart = 0.5  // query starts a word inside the name
+	scoreInterior  = 0.3  // query occurs elsewhere, or via wildcards
+	scoreCoverage  = 0.25 // weight of len(query)/len(name)
+)
+
+// Fuzzy matches, where the query's characters appear in order but not next
+// to each other, score below every substring match: up to 0.2 for how
+// tightly the characters cluster, plus 0.05 when the first one starts the
+// name.
+const (
+	scoreFuzzy      = 0.2
+	scoreFuzzyStart = 0.05
+)
+
+// Default weights for SearchOptions.MatchPath: a hit in the name counts
+// twice as much as a hit elsewhere in the path
+const (
+	DefaultNameWeight = 2.0
+	DefaultPathWeight = 1.0
+)
+
+// weightedScore combines the name and full-path scores of an entry. Each is
+// scaled by its weight and the stronger one wins, normalised by the larger
+// weight so the result stays within 0 to 1.
+func weightedScore(nameScore, pathScore float64, opts SearchOptions) float64 {
+	wn, wp := opts.NameWeight, opts.PathWeight
+	if wn == 0 && wp == 0 {
+		wn, wp = DefaultNameWeight, DefaultPathWeight
+	}
+	top := math.Max(wn, wp)
+	return roundScore(math.Max(wn*nameScore, wp*pathScore) / top)
+}
+
+// Score returns the relevance of name for query, or 0 if it does not match.
+func Score(name, query string, caseSensitive bool) float64 {
+	if name == "" || query == "" {
+		return 0
+	}
+
+	if hasWildcards(query) {
+		pattern := convertWildcardToRegex(query)
+		if !caseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		if ok, _ := regexp.MatchString(pattern, name); !ok {
+			return 0
+		}
+		literal := strings.NewReplacer("*", "", "?", "").Replace(collapseCharClasses(query))
+		return roundScore(scoreInterior + scoreCoverage*coverage(literal, name))
+	}
+
+	if !caseSensitive {
+		name = strings.ToLower(name)
+		query = strings.ToLower(query)
+	}
+
+	pos := strings.Index(name, query)
+	switch {
+	case pos < 0:
+		return 0
+	case name == query:
+		return scoreExact
+	}
+
+	base := scoreInterior
+	if pos == 0 {
+		base = scorePrefix
+	} else {
+		for ; pos >= 0; pos = nextIndex(name, query, pos) {
+			r, _ := utf8.DecodeLastRuneInString(name[:pos])
+			if !isWordRune(r) {
+				base = scoreWordStart
+				break
+			}
+		}
+	}
+	return roundScore(base + scoreCoverage*coverage(query, name))
+}
+
+// FuzzyScore returns the relevance of name for query as a fuzzy match, or 0
+// if the characters of query do not all appear in name in order. A name
+// containing query as a substring scores as it would with Score; others
+// lose score for every character skipped between the first and last match,
+// so "rdme" ranks readme.txt above "read-only mode.txt".
+func FuzzyScore(name, query string, caseSensitive bool) float64 {
+	if name == "" || query == "" {
+		return 0
+	}
+	if !caseSensitive {
+		name = strings.ToLower(name)
+		query = strings.ToLower(query)
+	}
+	if strings.Contains(name, query) {
+		return Score(name, query, true)
+	}
+
+	text, pattern := []rune(name), []rune(query)
+	best := 0.0
+	// Matching greedily from each occurrence of the first character finds
+	// the tightest span that starts there
+	for start := range text {
+		if text[start] != pattern[0] {
+			continue
+		}
+		end, k := start, 1
+		for i := start + 1; i < len(text) && k < len(pattern); i++ {
+			if text[i] == pattern[k] {
+				end, k = i, k+1
+			}
+		}
+		if k < len(pattern) {
+			// No later start can fit the rest either
+			break
+		}
+		s := scoreFuzzy * float64(len(pattern)) / float64(end-start+1)
+		if start == 0 {
+			s += scoreFuzzyStart
+		}
+		best = math.Max(best, s)
+	}
+	return roundScore(best)
+}
+
+// isSubsequence reports whether the characters of query appear in text in
+// order, which is what a fuzzy match needs before it is scored
+func isSubsequence(text, query string) bool {
+	for _, r := range text {
+		if query == "" {
+			break
+		}
+		if q, size := utf8.DecodeRuneInString(query); r == q {
+			query = query[size:]
+		}
+	}
+	return query == ""
+}
+
+// nextIndex returns the position of the next occurrence of query in name
+// after the one at pos, or -1
+func nextIndex(name, query string, pos int) int {
+	next := strings.Index(name[pos+1:], query)
+	if next < 0 {
+		return -1
+	}
+	return pos + 1 + next
+}
+
+// coverage returns the fraction of name's characters accounted for by query
+func coverage(query, name string) float64 {
+	n := utf8.RuneCountInString(name)
+	if n == 0 {
+		return 0
+	}
+	return math.Min(1, float64(utf8.RuneCountInString(query))/float64(n))
+}
+
+// collapseCharClasses replaces each character class in the wildcard pattern
+// with a single character, since a class matches just one
+func collapseCharClasses(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if end := globClassEnd(pattern, i); pattern[i] == '[' && end >= 0 {
+			b.WriteByte('_')
+			i = end - 1
+			continue
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func roundScore(s float64) float64 {
+	return math.Round(s*10000) / 10000
+}
@@ -1,8 +1,15 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -15,76 +22,812 @@ type SearchOptions struct {
 	SearchInFiles   bool
 	SearchInFolders bool
 	MaxResults      int // 0 = unlimited
+	MaxPerExtension int // cap on matched files per extension, 0 = unlimited
+
+	// MaxFiles and MaxFolders cap the files and the folders matched, each
+	// on its own; 0 means no cap. MaxResults still limits the total, so
+	// with MaxFiles 50, MaxFolders 10, and MaxResults 40 there are at most
+	// 40 results, files first.
+	MaxFiles   int
+	MaxFolders int
+
+	// FoldersFirst searches the folders before the files, so SearchStream
+	// yields them first and MaxResults keeps folders ahead of files
+	FoldersFirst bool
+
+	// MinDepth and MaxDepth keep only entries at least and at most that
+	// many levels below the root, counting parents: the root is at depth 0,
+	// /home at 1, and /home/me/notes.txt at 3. 0 leaves that side open.
+	MinDepth int
+	MaxDepth int
+
+	// MatchStem matches the query against each name without its final
+	// extension, so "report" finds report.pdf and report.docx. A plain
+	// query must equal the whole stem; with MatchWholeWord it need only be
+	// a word in it, so quarterly-report.pdf matches too. Wildcards, UseRegex,
+	// and Fuzzy match the stem as they would a name. Paths checked for
+	// MatchPath keep their extensions.
+	MatchStem bool
+
+	// Contains lets a wildcard pattern match anywhere in a name, as plain
+	// text does, rather than the whole name: "rep*2024" then finds
+	// my-report-2024.pdf. Queries without wildcards match anywhere either
+	// way.
+	Contains bool
+
+	// UseRegex treats Query as an unanchored Go regular expression instead
+	// of a wildcard pattern. Case-insensitive matching uses (?i), so Fold
+	// and MatchWholeWord do not apply.
+	UseRegex bool
+
+	// ExactSize, when set, keeps only files of exactly that many bytes.
+	// Folders never match it.
+	ExactSize *int64
+
+	// MinSize and MaxSize keep only files whose size lies within the
+	// range, inclusive; MaxSize 0 means no upper bound. Folders are
+	// checked too when the database indexes sizes, since their size is
+	// then the total of their contents.
+	MinSize int64
+	MaxSize int64
+
+	// MTimeAfter and MTimeBefore keep only entries modified strictly after
+	// and strictly before them; a zero time leaves that side open. Entries
+	// with no recorded modification time never pass. Searching with either
+	// set fails if the database does not index modification times.
+	MTimeAfter  time.Time
+	MTimeBefore time.Time
+
+	// Fold maps text to a caseless form for case-insensitive matching, so
+	// language-specific rules such as Turkish dotted and dotless i can be
+	// applied. nil uses full Unicode case folding, under which "CAFÉ"
+	// matches "café" and "STRASSE" matches "straße". Entries are matched
+	// concurrently, so Fold must be safe for concurrent use.
+	Fold func(string) string
+
+	// NoNormalize compares the query and the text as they are. Otherwise
+	// both are brought to Unicode normalization form C first, so "café"
+	// typed with a precomposed é finds a name stored with e and a
+	// combining accent.
+	NoNormalize bool
+
+	// FoldAccents ignores combining marks in both the query and the text,
+	// so "cafe" and "café" match each other. It applies after Fold and
+	// regardless of CaseSensitive.
+	FoldAccents bool
+
+	// Score records each match's relevance in SearchResult.Scores.
+	// MinScore drops matches scoring below it and implies Score.
+	Score    bool
+	MinScore float64
+
+	// MatchRanges records in SearchResult.Ranges and Match.Range where
+	// Query matched each name; see Database.MatchRange.
+	MatchRanges bool
+
+	// Fuzzy matches entries containing the characters of Query in order,
+	// though not necessarily together, so "rdme" finds readme.txt.
+	// Wildcards are taken literally. Matches are scored with FuzzyScore,
+	// as if Score were set.
+	Fuzzy bool
+
+	// Boolean reads Query as terms combined with AND, OR, and NOT, such as
+	// "invoice AND 2024 NOT draft"; see parseBooleanQuery. Each term is
+	// matched as a Query of its own would be. It cannot be combined with
+	// UseRegex or Fuzzy.
+	Boolean bool
+
+	// Follow adds the contents of every folder whose name matches the
+	// query, down to FollowDepth levels below it (0 = unlimited)
+	Follow      bool
+	FollowDepth int
+
+	// MatchPath also matches the query against each entry's full path.
+	// NameWeight and PathWeight set how a name hit ranks against a path
+	// hit in the scores; both zero means DefaultNameWeight and
+	// DefaultPathWeight.
+	MatchPath  bool
+	NameWeight float64
+	PathWeight float64
+
+	// ExcludePatterns drops matches whose name matches any of these
+	// wildcard patterns. A pattern containing "/" is matched against the
+	// full path instead, as all are with ExcludeMatchPath, so "*/tmp/*"
+	// drops everything under a tmp folder. Case follows CaseSensitive.
+	ExcludePatterns  []string
+	ExcludeMatchPath bool
+
+	// PathPattern, when set, also requires the full path of each match to
+	// match it, as PathSearchOptions.Pattern would: a wildcard pattern the
+	// whole path and plain text anywhere in it. Case, Fold, FoldAccents,
+	// and NoNormalize apply as they do to Query, so Query "*.log" with
+	// PathPattern "/var/*" finds the log files under /var in one pass.
+	PathPattern string
+
+	// Workers is how many goroutines match entries at once; 0 uses
+	// runtime.NumCPU() and 1 matches on the calling goroutine. Results are
+	// in database order whatever the number.
+	Workers int
+
+	// re is Query compiled once by prepareSearch when UseRegex is set, and
+	// glob its wildcard pattern compiled once otherwise. Both are shared by
+	// the matching goroutines.
+	re   *regexp.Regexp
+	glob *regexp.Regexp
+
+	// expr is the parsed Query when Boolean is set
+	expr queryNode
+
+	// exclude is ExcludePatterns compiled by prepareSearch
+	exclude []excludeRule
+
+	// pathMatch is PathPattern compiled by prepareSearch, or nil
+	pathMatch func(string) (int, bool)
+
+	// foldDefault is set when prepareSearch filled in a nil Fold with
+	// foldCase, which for ASCII text a (?i) glob applies by itself
+	foldDefault bool
+}
+
+// excludeRule is a compiled exclude pattern and whether it applies to the
+// full path rather than the name
+type excludeRule struct {
+	re   *regexp.Regexp
+	path bool
 }
 
 // SearchResult contains the results of a search
 type SearchResult struct {
 	Files   []*Entry
 	Folders []*Folder
+
+	// Scores holds the relevance of each match when SearchOptions.Score
+	// or MinScore is set; see Score for the scale
+	Scores map[*Entry]float64
+
+	// Ranges holds where the query matched the name of each match when
+	// SearchOptions.MatchRanges is set and the match could be placed
+	Ranges map[*Entry]MatchRange
+
+	// Truncated is set when a limit such as MaxResults or MaxPerExtension
+	// left out matches, or stopped the search before it saw every entry
+	Truncated bool
+
+	// db is the database searched, whose path cache FullPath uses
+	db *Database
+}
+
+// FullPath returns the full path of e, one of the entries of r, through the
+// path cache of the database searched when r came from a search
+func (r *SearchResult) FullPath(e *Entry) string {
+	if r.db == nil {
+		return e.GetFullPath()
+	}
+	return r.db.FullPath(e)
 }
 
-// Search performs a search on the database
-func (db *Database) Search(opts SearchOptions) *SearchResult {
+// cancelCheckInterval is how many entries are matched between checks for
+// cancellation, keeping the check off the per-entry hot path
+const cancelCheckInterval = 1024
+
+// parallelMinEntries is the fewest entries worth splitting between workers
+const parallelMinEntries = 4 * cancelCheckInterval
+
+// DuplicatesBySize groups the files of r by size and returns the groups of
+// more than one file, each in result order. Files of the same size are only
+// candidate duplicates, since the database records no content hashes.
+func (r *SearchResult) DuplicatesBySize() map[int64][]*Entry {
+	bySize := make(map[int64][]*Entry)
+	for _, file := range r.Files {
+		bySize[file.Size] = append(bySize[file.Size], file)
+	}
+	for size, group := range bySize {
+		if len(group) < 2 {
+			delete(bySize, size)
+		}
+	}
+	return bySize
+}
+
+// eachMatch calls emit, in ascending order, with each index below n for
+// which match is true, stopping early if emit returns false. Large ranges
+// are split into chunks that up to workers goroutines (0 =
+// runtime.NumCPU()) match concurrently, so match must be safe for
+// concurrent use. The fixed-size chunks are matched a round at a time, and
+// each round is emitted before the next starts, so at most one round of
+// indices is buffered and a false from emit skips the rounds after it. If
+// ctx is done first, the indices found so far are emitted and ctx.Err() is
+// returned.
+func eachMatch(ctx context.Context, n, workers int, match func(i int) bool, emit func(i int) bool) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 || n < parallelMinEntries {
+		for i := 0; i < n; i++ {
+			if canceled(ctx, i) {
+				return ctx.Err()
+			}
+			if match(i) && !emit(i) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	// Several chunks per worker even out uneven matching costs
+	const chunk = cancelCheckInterval
+	chunks := (n + chunk - 1) / chunk
+	round := workers * 8
+	found := make([][]int, min(round, chunks))
+	for first := 0; first < chunks; first += round {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		last := min(first+round, chunks)
+		var next atomic.Int64
+		var stopped atomic.Bool
+		var wg sync.WaitGroup
+		for w := 0; w < min(workers, last-first); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					c := int(next.Add(1) - 1)
+					if c >= last-first {
+						return
+					}
+					if ctx.Err() != nil {
+						stopped.Store(true)
+						return
+					}
+					local := found[c][:0]
+					for i := (first + c) * chunk; i < min((first+c+1)*chunk, n); i++ {
+						if match(i) {
+							local = append(local, i)
+						}
+					}
+					found[c] = local
+				}
+			}()
+		}
+		wg.Wait()
+
+		for c := range found[:last-first] {
+			for _, i := range found[c] {
+				if !emit(i) {
+					return nil
+				}
+			}
+			found[c] = found[c][:0]
+		}
+		if stopped.Load() {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// canceled reports whether ctx is done, checking only every
+// cancelCheckInterval entries
+func canceled(ctx context.Context, i int) bool {
+	return i%cancelCheckInterval == cancelCheckInterval-1 && ctx.Err() != nil
+}
+
+// Match is one entry found by SearchStream
+type Match struct {
+	Entry *Entry
+	// Folder is the matched folder, whose Entry is Entry, or nil for a file
+	Folder *Folder
+	// Score is the relevance of the match when SearchOptions.Score,
+	// MinScore, or Fuzzy is set
+	Score float64
+	// Range is where the query matched the name when
+	// SearchOptions.MatchRanges is set, or nil if that cannot be placed
+	Range *MatchRange
+}
+
+// Search performs a search on the database. An invalid Query, such as a
+// malformed UseRegex, Boolean, or character class pattern, is reported as
+// an error along with an empty result.
+func (db *Database) Search(opts SearchOptions) (*SearchResult, error) {
+	return db.SearchContext(context.Background(), opts)
+}
+
+// SearchContext is like Search but stops early when ctx is done. The matches
+// found so far are still returned, with Truncated set, along with ctx.Err().
+// With UseRegex or Boolean, an invalid Query is reported as an error before
+// any entry is matched.
+func (db *Database) SearchContext(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
 	result := &SearchResult{
 		Files:   make([]*Entry, 0),
 		Folders: make([]*Folder, 0),
+		db:      db,
 	}
 
 	if opts.Query == "" {
-		return result
+		return result, nil
+	}
+	opts, err := db.prepareSearch(opts)
+	if err != nil {
+		return result, err
+	}
+
+	if opts.scored() {
+		result.Scores = make(map[*Entry]float64)
+	}
+	if opts.MatchRanges {
+		result.Ranges = make(map[*Entry]MatchRange)
+	}
+	result.Truncated, err = db.searchStream(ctx, opts, func(m Match) bool {
+		if m.Folder != nil {
+			result.Folders = append(result.Folders, m.Folder)
+		} else {
+			result.Files = append(result.Files, m.Entry)
+		}
+		if result.Scores != nil {
+			result.Scores[m.Entry] = m.Score
+		}
+		if m.Range != nil {
+			result.Ranges[m.Entry] = *m.Range
+		}
+		return true
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if opts.Follow {
+		db.follow(result, opts.Query, opts)
+	}
+
+	return result, nil
+}
+
+// SearchStream searches as Search does but calls fn with each match as it
+// is found, files before folders unless FoldersFirst is set, instead of
+// collecting them, so memory does not grow with the number of matches. Returning false from fn stops
+// the search. With Follow the matches are collected first, as the folders
+// they lead to must not repeat them.
+func (db *Database) SearchStream(opts SearchOptions, fn func(Match) bool) error {
+	return db.SearchStreamContext(context.Background(), opts, fn)
+}
+
+// SearchStreamContext is like SearchStream but stops early when ctx is
+// done, returning ctx.Err() after the matches found so far
+func (db *Database) SearchStreamContext(ctx context.Context, opts SearchOptions, fn func(Match) bool) error {
+	if opts.Query == "" {
+		return nil
+	}
+	if opts.Follow {
+		result, err := db.SearchContext(ctx, opts)
+		rangeOf := func(e *Entry) *MatchRange {
+			if r, ok := result.Ranges[e]; ok {
+				return &r
+			}
+			return nil
+		}
+		files := func() bool {
+			for _, file := range result.Files {
+				if !fn(Match{Entry: file, Score: result.Scores[file], Range: rangeOf(file)}) {
+					return false
+				}
+			}
+			return true
+		}
+		folders := func() bool {
+			for _, folder := range result.Folders {
+				if !fn(Match{Entry: &folder.Entry, Folder: folder, Score: result.Scores[&folder.Entry], Range: rangeOf(&folder.Entry)}) {
+					return false
+				}
+			}
+			return true
+		}
+		if opts.FoldersFirst {
+			files, folders = folders, files
+		}
+		if files() {
+			folders()
+		}
+		return err
+	}
+
+	opts, err := db.prepareSearch(opts)
+	if err != nil {
+		return err
+	}
+	_, err = db.searchStream(ctx, opts, fn)
+	return err
+}
+
+// prepareSearch checks opts against the database and compiles the query
+// and exclude patterns it needs, shared by the matching goroutines
+func (db *Database) prepareSearch(opts SearchOptions) (SearchOptions, error) {
+	if opts.Fold == nil {
+		opts.Fold, opts.foldDefault = foldCase, true
+	}
+	if !opts.NoNormalize {
+		opts.Query = normalizeNFC(opts.Query)
+	}
+	if (!opts.MTimeAfter.IsZero() || !opts.MTimeBefore.IsZero()) && db.IndexFlags&IndexFlagModificationTime == 0 {
+		return opts, ErrNoModificationTime
+	}
+
+	if opts.Boolean {
+		if opts.UseRegex || opts.Fuzzy {
+			return opts, errors.New("boolean queries cannot be combined with regular expressions or fuzzy matching")
+		}
+		expr, err := parseBooleanQuery(opts)
+		if err != nil {
+			return opts, err
+		}
+		opts.expr = expr
+	} else if opts.UseRegex {
+		re, err := compileQueryRegex(opts)
+		if err != nil {
+			return opts, err
+		}
+		opts.re = re
+	} else if !opts.Fuzzy {
+		if opts.Contains || escapesOnly(opts.Query) {
+			opts.Query = unanchored(opts.Query)
+		}
+		node, err := braceNode(opts)
+		if err != nil {
+			return opts, err
+		}
+		if node != nil {
+			opts.expr = node
+		} else {
+			opts.Query = expandBraces(opts.Query)[0]
+			if opts.glob, err = compileQueryGlob(opts); err != nil {
+				return opts, err
+			}
+		}
 	}
+	exclude, err := compileExcludes(opts)
+	if err != nil {
+		return opts, err
+	}
+	opts.exclude = exclude
+	if opts.PathPattern != "" {
+		match, err := pathMatcher(PathSearchOptions{
+			Pattern:       opts.PathPattern,
+			CaseSensitive: opts.CaseSensitive,
+			Fold:          opts.Fold,
+			FoldAccents:   opts.FoldAccents,
+			NoNormalize:   opts.NoNormalize,
+		})
+		if err != nil {
+			return opts, fmt.Errorf("invalid path pattern %q: %w", opts.PathPattern, err)
+		}
+		opts.pathMatch = match
+	}
+	return opts, nil
+}
 
+// scored reports whether matches get relevance scores
+func (opts SearchOptions) scored() bool {
+	return opts.Score || opts.MinScore > 0 || opts.Fuzzy
+}
+
+// searchStream matches the entries of the database against prepared opts
+// and calls fn with each match that passes MinScore and the limits, files
+// before folders unless FoldersFirst is set. It reports whether the limits or ctx cut the matches
+// short; fn returning false just stops.
+func (db *Database) searchStream(ctx context.Context, opts SearchOptions, fn func(Match) bool) (bool, error) {
 	// Keep original query for wildcard detection (case conversion happens in matches())
 	query := opts.Query
 
-	// Search files
-	if opts.SearchInFiles {
-		for _, file := range db.Files {
-			if db.matches(file.Name, query, opts) {
-				result.Files = append(result.Files, file)
-				if opts.MaxResults > 0 && len(result.Files) >= opts.MaxResults {
-					break
-				}
+	// Relevance filter and scores for Score/MinScore
+	scored := opts.scored()
+	score := Score
+	if opts.Fuzzy {
+		score = FuzzyScore
+	}
+	// A boolean query scores as its best term outside NOT
+	scoredQueries := []string{query}
+	if opts.expr != nil {
+		scoredQueries = opts.expr.terms(nil, false)
+	}
+	keep := func(e *Entry) (float64, bool) {
+		if !scored {
+			return 0, true
+		}
+		name, path := e.Name, ""
+		if opts.MatchPath {
+			path = db.FullPath(e)
+		}
+		if opts.FoldAccents {
+			name, path = stripAccents(name), stripAccents(path)
+		}
+		s := 0.0
+		for _, q := range scoredQueries {
+			if opts.FoldAccents {
+				q = stripAccents(q)
+			}
+			qs := score(name, q, opts.CaseSensitive)
+			if opts.MatchPath {
+				qs = weightedScore(qs, score(path, q, opts.CaseSensitive), opts)
 			}
+			s = max(s, qs)
 		}
+		return s, s >= opts.MinScore
 	}
 
-	// Search folders
-	if opts.SearchInFolders {
-		for _, folder := range db.Folders {
-			if db.matches(folder.Name, query, opts) {
-				result.Folders = append(result.Folders, folder)
-				if opts.MaxResults > 0 && len(result.Folders)+len(result.Files) >= opts.MaxResults {
-					break
+	// Per-extension match counts for MaxPerExtension
+	var extCounts map[string]int
+	if opts.MaxPerExtension > 0 {
+		extCounts = make(map[string]int)
+	}
+
+	count, fileCount, folderCount := 0, 0, 0
+	truncated, stopped := false, false
+	full := func() bool {
+		return opts.MaxResults > 0 && count >= opts.MaxResults
+	}
+	// A match past MaxResults is what shows the results were cut short, so
+	// the limit is checked before each match rather than after the last
+	emit := func(m Match) bool {
+		if full() {
+			truncated = true
+			return false
+		}
+		count++
+		if !fn(m) {
+			stopped = true
+			return false
+		}
+		return true
+	}
+
+	// Search files. Matching runs concurrently; scoring and the limits are
+	// then applied in database order, so the results do not depend on it.
+	searchFiles := func() error {
+		if !opts.SearchInFiles {
+			return nil
+		}
+		return eachMatch(ctx, len(db.Files), opts.Workers, func(i int) bool {
+			file := db.Files[i]
+			return opts.fileSizeMatches(file.Size) && opts.mtimeMatches(file.MTime) && opts.depthMatches(file) &&
+				db.matchesEntry(file, query, opts) && !db.excluded(file, opts) && db.pathMatches(file, opts)
+		}, func(i int) bool {
+			file := db.Files[i]
+			s, ok := keep(file)
+			if !ok {
+				return true
+			}
+			if opts.MaxFiles > 0 && fileCount >= opts.MaxFiles {
+				truncated = true
+				return false
+			}
+			if extCounts != nil {
+				ext := strings.ToLower(Extension(file.Name))
+				if extCounts[ext] >= opts.MaxPerExtension {
+					truncated = true
+					return true
 				}
+				extCounts[ext]++
 			}
+			fileCount++
+			return emit(Match{Entry: file, Score: s, Range: db.matchRangeOf(file, opts)})
+		})
+	}
+
+	// Search folders
+	searchFolders := func() error {
+		if !opts.SearchInFolders || opts.ExactSize != nil {
+			return nil
 		}
+		return eachMatch(ctx, len(db.Folders), opts.Workers, func(i int) bool {
+			folder := db.Folders[i]
+			return db.folderSizeMatches(folder, opts) && opts.mtimeMatches(folder.MTime) && opts.depthMatches(&folder.Entry) &&
+				db.matchesEntry(&folder.Entry, query, opts) && !db.excluded(&folder.Entry, opts) && db.pathMatches(&folder.Entry, opts)
+		}, func(i int) bool {
+			folder := db.Folders[i]
+			s, ok := keep(&folder.Entry)
+			if !ok {
+				return true
+			}
+			if opts.MaxFolders > 0 && folderCount >= opts.MaxFolders {
+				truncated = true
+				return false
+			}
+			folderCount++
+			return emit(Match{Entry: &folder.Entry, Folder: folder, Score: s, Range: db.matchRangeOf(&folder.Entry, opts)})
+		})
 	}
 
-	return result
+	first, second := searchFiles, searchFolders
+	if opts.FoldersFirst {
+		first, second = searchFolders, searchFiles
+	}
+	if err := first(); err != nil {
+		return true, err
+	}
+	// The second kind is searched at MaxResults too, to find out whether
+	// any match is left out
+	if !stopped && !(full() && truncated) {
+		if err := second(); err != nil {
+			return true, err
+		}
+	}
+
+	return truncated, nil
 }
 
-// hasWildcards checks if a string contains wildcard characters (* or ?)
+// depthMatches reports whether e lies within MinDepth and MaxDepth
+func (opts SearchOptions) depthMatches(e *Entry) bool {
+	if opts.MinDepth == 0 && opts.MaxDepth == 0 {
+		return true
+	}
+	depth := e.Depth()
+	return depth >= opts.MinDepth && (opts.MaxDepth == 0 || depth <= opts.MaxDepth)
+}
+
+// fileSizeMatches reports whether a file of the given size passes the
+// ExactSize, MinSize, and MaxSize filters
+func (opts SearchOptions) fileSizeMatches(size int64) bool {
+	if opts.ExactSize != nil && size != *opts.ExactSize {
+		return false
+	}
+	return size >= opts.MinSize && (opts.MaxSize == 0 || size <= opts.MaxSize)
+}
+
+// folderSizeMatches applies MinSize and MaxSize to a folder when the
+// database indexes sizes; otherwise folder sizes are unknown and every
+// folder passes
+func (db *Database) folderSizeMatches(folder *Folder, opts SearchOptions) bool {
+	if db.IndexFlags&IndexFlagSize == 0 {
+		return true
+	}
+	size := folder.Size
+	return size >= opts.MinSize && (opts.MaxSize == 0 || size <= opts.MaxSize)
+}
+
+// ErrNoModificationTime is returned when a search filters on modification
+// time in a database that was indexed without it
+var ErrNoModificationTime = errors.New("database does not index modification times; re-index with them enabled to filter by time")
+
+// mtimeMatches reports whether mtime lies within MTimeAfter and MTimeBefore
+func (opts SearchOptions) mtimeMatches(mtime time.Time) bool {
+	if opts.MTimeAfter.IsZero() && opts.MTimeBefore.IsZero() {
+		return true
+	}
+	if mtime.IsZero() || mtime.Unix() == 0 {
+		return false
+	}
+	return (opts.MTimeAfter.IsZero() || mtime.After(opts.MTimeAfter)) &&
+		(opts.MTimeBefore.IsZero() || mtime.Before(opts.MTimeBefore))
+}
+
+// Extension returns the part of name after its final dot, without the dot.
+// Names without a dot, and dotfiles such as ".bashrc", have no extension.
+func Extension(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 {
+		return ""
+	}
+	return name[i+1:]
+}
+
+// hasWildcards checks if a string contains wildcard characters (* or ?),
+// a character class such as [a-z], or a bracket escaped as \[ or \], which
+// only the wildcard translation unescapes
 func hasWildcards(s string) bool {
-	return strings.Contains(s, "*") || strings.Contains(s, "?")
+	if strings.ContainsAny(s, "*?") || strings.Contains(s, `\[`) || strings.Contains(s, `\]`) {
+		return true
+	}
+	for i := strings.IndexByte(s, '['); i >= 0; i = nextByte(s, '[', i) {
+		if globClassEnd(s, i) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bracketEscapes removes escaped brackets, leaving the rest of a pattern
+var bracketEscapes = strings.NewReplacer(`\[`, "", `\]`, "")
+
+// escapesOnly reports whether the only pattern syntax in query is escaped
+// brackets, so that it is plain text and should match anywhere in a name
+func escapesOnly(query string) bool {
+	return hasWildcards(query) && !hasWildcards(bracketEscapes.Replace(query))
+}
+
+// nextByte returns the index of the next c in s after index i, or -1
+func nextByte(s string, c byte, i int) int {
+	j := strings.IndexByte(s[i+1:], c)
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// globClassEnd returns the index just past the ] that closes the character
+// class opened by the [ at pattern[i], or -1 if there is none and the [ is
+// literal. As in the shell, a ] right after the [ or its negating ! or ^ is
+// part of the class rather than its end, as is a ] escaped as \]. A [
+// escaped as \[ opens no class.
+func globClassEnd(pattern string, i int) int {
+	if i > 0 && pattern[i-1] == '\\' {
+		return -1
+	}
+	j := i + 1
+	if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+		j++
+	}
+	if j < len(pattern) && pattern[j] == ']' {
+		j++
+	}
+	for ; j < len(pattern); j++ {
+		switch pattern[j] {
+		case '\\':
+			j++
+		case ']':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// unanchored returns the wildcard pattern query with * added at either end
+// that lacks one, so that it matches anywhere in a name, for Contains. A
+// query without wildcards is returned as it is, as it matches anywhere
+// already.
+func unanchored(query string) string {
+	if !hasWildcards(query) {
+		return query
+	}
+	if !strings.HasPrefix(query, "*") {
+		query = "*" + query
+	}
+	if !strings.HasSuffix(query, "*") {
+		query += "*"
+	}
+	return query
 }
 
 // convertWildcardToRegex converts a wildcard pattern to a regex pattern
 // * becomes .* (matches any sequence)
 // ? becomes . (matches single character)
+// [abc], [a-z], and [!x] or [^x] become regex character classes
+// \[ and \] become literal brackets
 // Special regex characters are escaped
 // Pattern is anchored with ^ and $ for full string matching
 func convertWildcardToRegex(pattern string) string {
+	return "^" + wildcardToRegexBody(pattern) + "$"
+}
+
+// wildcardToRegexBody converts a wildcard pattern to an unanchored regex
+func wildcardToRegexBody(pattern string) string {
 	var result strings.Builder
-	result.WriteString("^") // Anchor start
-	
-	for _, char := range pattern {
+
+	skip := 0
+	for i, char := range pattern {
+		if i < skip {
+			continue
+		}
 		switch char {
 		case '*':
 			result.WriteString(".*")
 		case '?':
 			result.WriteString(".")
-		case '.', '^', '$', '+', '(', ')', '[', ']', '{', '}', '\\', '|':
+		case '[':
+			end := globClassEnd(pattern, i)
+			if end < 0 {
+				result.WriteString(`\[`)
+				continue
+			}
+			writeCharClass(&result, pattern[i+1:end-1])
+			skip = end
+		case '\\':
+			if i+1 < len(pattern) && (pattern[i+1] == '[' || pattern[i+1] == ']') {
+				result.WriteString(pattern[i : i+2])
+				skip = i + 2
+				continue
+			}
+			result.WriteString(`\\`)
+		case '.', '^', '$', '+', '(', ')', ']', '{', '}', '|':
 			// Escape special regex characters
 			result.WriteRune('\\')
 			result.WriteRune(char)
@@ -92,34 +835,538 @@ func convertWildcardToRegex(pattern string) string {
 			result.WriteRune(char)
 		}
 	}
-	
-	result.WriteString("$") // Anchor end
+
 	return result.String()
 }
 
-// matches checks if a string matches the query based on the search options
-func (db *Database) matches(text, query string, opts SearchOptions) bool {
-	// Check for wildcard patterns (before case conversion)
-	if hasWildcards(query) {
-		regexPattern := convertWildcardToRegex(query)
-		var re *regexp.Regexp
-		var err error
-		if opts.CaseSensitive {
-			re, err = regexp.Compile(regexPattern)
-		} else {
-			// Compile case-insensitive regex
-			re, err = regexp.Compile("(?i)" + regexPattern)
+// writeCharClass writes the glob character class body class, the text
+// between its brackets, as a regex character class. A leading ! negates the
+// class as ^ does; ranges such as a-z carry over as they are, and \[ and \]
+// stand for the brackets.
+func writeCharClass(b *strings.Builder, class string) {
+	b.WriteByte('[')
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		b.WriteByte('^')
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		c := class[i]
+		if c == '\\' && i+1 < len(class) && (class[i+1] == '[' || class[i+1] == ']') {
+			i++
+			c = class[i]
+		}
+		switch c {
+		case '\\', '[', ']', '^':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(']')
+}
+
+// matchesEntry checks the query against an entry's name and, with
+// opts.MatchPath, its full path
+func (db *Database) matchesEntry(e *Entry, query string, opts SearchOptions) bool {
+	if opts.expr != nil {
+		return opts.expr.eval(db, e)
+	}
+	if opts.MatchStem {
+		if db.matchesStem(stem(e.Name), query, opts) {
+			return true
+		}
+	} else if db.matches(e.Name, query, opts) {
+		return true
+	}
+	return opts.MatchPath && db.matches(db.FullPath(e), query, opts)
+}
+
+// stem returns name without its final extension, as Extension splits it
+func stem(name string) string {
+	if ext := Extension(name); ext != "" {
+		return name[:len(name)-len(ext)-1]
+	}
+	return name
+}
+
+// matchesStem checks the query against the stem of a name for MatchStem. A
+// plain query must equal it, with case and accents handled as matches
+// handles them; any other kind of query is left to matches.
+func (db *Database) matchesStem(stem, query string, opts SearchOptions) bool {
+	if opts.re != nil || opts.Fuzzy || opts.MatchWholeWord || hasWildcards(query) {
+		return db.matches(stem, query, opts)
+	}
+	if !opts.NoNormalize {
+		stem = normalizeNFC(stem)
+	}
+	if opts.Fold != nil && !opts.CaseSensitive {
+		stem, query = opts.Fold(stem), opts.Fold(query)
+	} else if !opts.CaseSensitive {
+		stem, query = strings.ToLower(stem), strings.ToLower(query)
+	}
+	if opts.FoldAccents {
+		stem, query = stripAccents(stem), stripAccents(query)
+	}
+	return stem == query
+}
+
+// compileQueryRegex compiles opts.Query for UseRegex
+func compileQueryRegex(opts SearchOptions) (*regexp.Regexp, error) {
+	pattern := opts.Query
+	if opts.FoldAccents {
+		pattern = stripAccents(pattern)
+	}
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", opts.Query, err)
+	}
+	return re, nil
+}
+
+// queryNode is a node of a parsed boolean query
+type queryNode interface {
+	eval(db *Database, e *Entry) bool
+	// terms appends the terms not under an odd number of NOTs
+	terms(out []string, negated bool) []string
+}
+
+// queryTerm matches its own Query, with the options of the whole search
+type queryTerm struct {
+	opts SearchOptions
+}
+
+type (
+	queryAnd []queryNode
+	queryOr  []queryNode
+	queryNot struct{ node queryNode }
+)
+
+func (t queryTerm) eval(db *Database, e *Entry) bool {
+	return db.matchesEntry(e, t.opts.Query, t.opts)
+}
+
+func (n queryAnd) eval(db *Database, e *Entry) bool {
+	for _, c := range n {
+		if !c.eval(db, e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n queryOr) eval(db *Database, e *Entry) bool {
+	for _, c := range n {
+		if c.eval(db, e) {
+			return true
 		}
+	}
+	return false
+}
+
+func (n queryNot) eval(db *Database, e *Entry) bool {
+	return !n.node.eval(db, e)
+}
+
+func (t queryTerm) terms(out []string, negated bool) []string {
+	if negated {
+		return out
+	}
+	return append(out, t.opts.Query)
+}
+
+func (n queryAnd) terms(out []string, negated bool) []string {
+	for _, c := range n {
+		out = c.terms(out, negated)
+	}
+	return out
+}
+
+func (n queryOr) terms(out []string, negated bool) []string {
+	for _, c := range n {
+		out = c.terms(out, negated)
+	}
+	return out
+}
+
+func (n queryNot) terms(out []string, negated bool) []string {
+	return n.node.terms(out, !negated)
+}
+
+// queryToken is a lexed boolean query token. Quoted words are terms even
+// when they spell an operator.
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// isOp reports whether t is the unquoted operator or parenthesis op
+func (t queryToken) isOp(op string) bool {
+	return !t.quoted && t.text == op
+}
+
+// lexBooleanQuery splits a query at spaces and around parentheses. Double
+// quotes group words, spaces and parentheses included, into one term.
+func lexBooleanQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: query[i : i+1]})
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quote")
+			}
+			tokens = append(tokens, queryToken{text: query[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(query[i:], " \t()\"")
+			if end < 0 {
+				end = len(query) - i
+			}
+			tokens = append(tokens, queryToken{text: query[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// booleanParser is a recursive descent parser over the tokens of a query
+type booleanParser struct {
+	tokens []queryToken
+	pos    int
+	opts   SearchOptions
+}
+
+// parseBooleanQuery parses opts.Query as a boolean query. Operators are
+// upper case: NOT binds tightest, then AND, then OR, and terms next to each
+// other are joined by AND, so "a b OR c" means (a AND b) OR c. Parentheses
+// group, and double quotes make a term of an operator word or of text with
+// spaces. Each term is matched with opts as though it were the whole query,
+// so wildcards work within it.
+func parseBooleanQuery(opts SearchOptions) (queryNode, error) {
+	tokens, err := lexBooleanQuery(opts.Query)
+	if err == nil && len(tokens) == 0 {
+		err = errors.New("no terms")
+	}
+	var node queryNode
+	if err == nil {
+		p := &booleanParser{tokens: tokens, opts: opts}
+		node, err = p.parseOr()
+		if err == nil && p.pos < len(p.tokens) {
+			err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid boolean query %q: %w", opts.Query, err)
+	}
+	return node, nil
+}
+
+func (p *booleanParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *booleanParser) parseOr() (queryNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := queryOr{node}
+	for t, ok := p.peek(); ok && t.isOp("OR"); t, ok = p.peek() {
+		p.pos++
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		or = append(or, node)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *booleanParser) parseAnd() (queryNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	and := queryAnd{node}
+	for {
+		t, ok := p.peek()
+		if !ok || t.isOp("OR") || t.isOp(")") {
+			break
+		}
+		if t.isOp("AND") {
+			p.pos++
+		}
+		if node, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		and = append(and, node)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *booleanParser) parseUnary() (queryNode, error) {
+	t, ok := p.peek()
+	switch {
+	case !ok:
+		return nil, errors.New("expected a term at end of query")
+	case t.isOp("NOT"):
+		p.pos++
+		node, err := p.parseUnary()
 		if err != nil {
-			// If regex compilation fails, fall back to substring match
-			if !opts.CaseSensitive {
-				text = strings.ToLower(text)
-				query = strings.ToLower(query)
+			return nil, err
+		}
+		return queryNot{node}, nil
+	case t.isOp("("):
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || !t.isOp(")") {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return node, nil
+	case t.isOp(")") || t.isOp("AND") || t.isOp("OR"):
+		return nil, fmt.Errorf("expected a term before %q", t.text)
+	case t.text == "":
+		return nil, errors.New("empty quoted term")
+	}
+	p.pos++
+	opts := p.opts
+	opts.Query, opts.Boolean = t.text, false
+	if opts.Contains || escapesOnly(opts.Query) {
+		opts.Query = unanchored(opts.Query)
+	}
+	node, err := braceNode(opts)
+	if node != nil || err != nil {
+		return node, err
+	}
+	opts.Query = expandBraces(opts.Query)[0]
+	if opts.glob, err = compileQueryGlob(opts); err != nil {
+		return nil, err
+	}
+	return queryTerm{opts}, nil
+}
+
+// braceNode returns a node matching any of the patterns opts.Query expands
+// to, or nil if it has no brace group to expand
+func braceNode(opts SearchOptions) (queryNode, error) {
+	alternatives := expandBraces(opts.Query)
+	if len(alternatives) < 2 {
+		return nil, nil
+	}
+	or := make(queryOr, len(alternatives))
+	for i, alternative := range alternatives {
+		term := opts
+		term.Query = alternative
+		var err error
+		if term.glob, err = compileQueryGlob(term); err != nil {
+			return nil, err
+		}
+		or[i] = queryTerm{term}
+	}
+	return or, nil
+}
+
+// braceUnescaper removes the backslashes escaping braces and commas. An
+// escaped backslash is kept as it is, so that it cannot escape a brace.
+var braceUnescaper = strings.NewReplacer(`\\`, `\\`, `\{`, "{", `\}`, "}", `\,`, ",")
+
+// expandBraces expands each {a,b,c} group in pattern into one pattern per
+// alternative, as the shell does, so "*.{jpg,png}" gives "*.jpg" and
+// "*.png". A group needs a comma: braces without one, or escaped with a
+// backslash, are literal. Nested groups expand from the inside out. The
+// backslashes escaping braces and commas are removed from the patterns
+// returned.
+func expandBraces(pattern string) []string {
+	start, end := braceGroup(pattern)
+	if start < 0 {
+		return []string{braceUnescaper.Replace(pattern)}
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, alternative := range splitBraceGroup(pattern[start+1 : end]) {
+		for _, p := range expandBraces(pattern[:start] + alternative + pattern[end+1:]) {
+			if !seen[p] {
+				seen[p] = true
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+// braceGroup returns the indices of the braces of the first group in
+// pattern that holds a comma and no other brace, or -1, -1 if there is none
+func braceGroup(pattern string) (int, int) {
+	open, comma := -1, false
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			open, comma = i, false
+		case ',':
+			comma = comma || open >= 0
+		case '}':
+			if open >= 0 && comma {
+				return open, i
 			}
-			return strings.Contains(text, query)
+			open = -1
+		}
+	}
+	return -1, -1
+}
+
+// splitBraceGroup splits the text between a group's braces at its
+// unescaped commas
+func splitBraceGroup(body string) []string {
+	var parts []string
+	last := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, body[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, body[last:])
+}
+
+// compileExcludes compiles opts.ExcludePatterns
+func compileExcludes(opts SearchOptions) ([]excludeRule, error) {
+	var rules []excludeRule
+	for _, pattern := range opts.ExcludePatterns {
+		expr := convertWildcardToRegex(pattern)
+		if !opts.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, excludeRule{re: re, path: opts.ExcludeMatchPath || strings.Contains(pattern, "/")})
+	}
+	return rules, nil
+}
+
+// excludesPaths reports whether any exclude rule needs full paths
+func (opts SearchOptions) excludesPaths() bool {
+	for _, rule := range opts.exclude {
+		if rule.path {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether e matches one of the compiled exclude rules
+func (db *Database) excluded(e *Entry, opts SearchOptions) bool {
+	for _, rule := range opts.exclude {
+		text := e.Name
+		if rule.path {
+			text = db.FullPath(e)
+		}
+		if rule.re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMatches reports whether the full path of e matches the compiled
+// PathPattern, or whether there is none
+func (db *Database) pathMatches(e *Entry, opts SearchOptions) bool {
+	if opts.pathMatch == nil {
+		return true
+	}
+	_, ok := opts.pathMatch(db.FullPath(e))
+	return ok
+}
+
+// compileQueryGlob compiles a wildcard Query, prepared as matches prepares
+// it, so it is not recompiled for every entry. It returns nil for a query
+// without wildcards, and an error for one that does not compile, such as
+// one with the range [z-a].
+func compileQueryGlob(opts SearchOptions) (*regexp.Regexp, error) {
+	query, caseSensitive := opts.Query, opts.CaseSensitive
+	if opts.Fold != nil && !caseSensitive {
+		// The default folding keeps (?i), so matches can skip folding
+		// ASCII names
+		query, caseSensitive = opts.Fold(query), !opts.foldDefault
+	}
+	if opts.FoldAccents {
+		query = stripAccents(query)
+	}
+	if !hasWildcards(query) {
+		return nil, nil
+	}
+	pattern := convertWildcardToRegex(query)
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wildcard pattern %q: %w", opts.Query, err)
+	}
+	return re, nil
+}
+
+// matches checks if a string matches the query based on the search options
+func (db *Database) matches(text, query string, opts SearchOptions) bool {
+	ascii := isASCII(text)
+	if !opts.NoNormalize && !ascii {
+		// The query was normalized by prepareSearch
+		text = normalizeNFC(text)
+	}
+	if opts.glob != nil && opts.foldDefault && ascii {
+		// Lowercasing is all the default folding does to ASCII, and the
+		// glob ignores case itself unless CaseSensitive
+		return opts.glob.MatchString(text)
+	}
+	if opts.re != nil {
+		if opts.FoldAccents {
+			text = stripAccents(text)
 		}
-		// Regex handles case sensitivity internally
-		return re.MatchString(text)
+		return opts.re.MatchString(text)
+	}
+	if opts.Fold != nil && !opts.CaseSensitive {
+		// Compare the folded forms exactly
+		text, query = opts.Fold(text), opts.Fold(query)
+		opts.CaseSensitive = true
+	}
+	if opts.FoldAccents {
+		text, query = stripAccents(text), stripAccents(query)
+	}
+
+	if opts.Fuzzy {
+		if !opts.CaseSensitive {
+			text, query = strings.ToLower(text), strings.ToLower(query)
+		}
+		return isSubsequence(text, query)
+	}
+	// Wildcard queries are compiled by prepareSearch, which reports an
+	// invalid one, so anything else is plain text
+	if opts.glob != nil {
+		return opts.glob.MatchString(text)
 	}
 
 	if !opts.CaseSensitive {
@@ -147,21 +1394,7 @@ func (db *Database) matchWholeWord(text, query string) bool {
 		}
 		pos += idx
 
-		// Check if it's a whole word
-		// Word boundary: start of string or non-word char before, end of string or non-word char after
-		before := pos == 0
-		if !before {
-			r, _ := utf8.DecodeLastRuneInString(text[:pos])
-			before = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
-		}
-
-		after := pos+len(query) == len(text)
-		if !after {
-			r, _ := utf8.DecodeRuneInString(text[pos+len(query):])
-			after = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
-		}
-
-		if before && after {
+		if isWholeWord(text, pos, pos+len(query)) {
 			return true
 		}
 
@@ -169,77 +1402,237 @@ func (db *Database) matchWholeWord(text, query string) bool {
 	}
 }
 
+// isWholeWord reports whether text[start:end] is a whole word: at the start
+// of text or after a non-word character, and at its end or before one
+func isWholeWord(text string, start, end int) bool {
+	before := start == 0
+	if !before {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		before = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}
+
+	after := end == len(text)
+	if !after {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		after = !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}
+	return before && after
+}
+
+// PathSearchOptions contains options for searching by full path
+type PathSearchOptions struct {
+	Pattern       string
+	CaseSensitive bool
+	// SegmentMatch requires the pattern to cover whole path segments, so
+	// "user" matches /home/user/x but not /home/username/x
+	SegmentMatch bool
+	// Fold is the case folding for case-insensitive matching, and
+	// FoldAccents ignores combining marks, as in SearchOptions
+	Fold        func(string) string
+	FoldAccents bool
+	// NoNormalize compares the pattern and paths as they are, rather than
+	// both in Unicode normalization form C, as in SearchOptions
+	NoNormalize bool
+	// MaxDepth, when set, keeps only entries at most that many levels
+	// below the part of the path the pattern matched, so with "/home" 0
+	// keeps /home itself and 1 also its direct children. A match ending
+	// inside a name counts from the end of that name, and a wildcard
+	// pattern from the end of its text before the first wildcard.
+	MaxDepth *int
+	// MaxRootDepth keeps only entries at most that many levels below the
+	// root, whatever the pattern matched, as SearchOptions.MaxDepth does;
+	// 0 means no limit
+	MaxRootDepth int
+}
+
 // SearchByPath searches for entries matching a path pattern
 // Supports wildcard patterns (* and ?)
-func (db *Database) SearchByPath(pattern string, caseSensitive bool) *SearchResult {
+func (db *Database) SearchByPath(pattern string, caseSensitive bool) (*SearchResult, error) {
+	return db.SearchPath(PathSearchOptions{Pattern: pattern, CaseSensitive: caseSensitive})
+}
+
+// SearchPath searches for entries whose full path matches opts.Pattern.
+// Without wildcards the pattern matches any substring of the path; with
+// wildcards (* and ?) it must match the whole path. In segment mode either
+// form must instead start and end on a "/" boundary. An invalid Pattern is
+// reported as an error along with an empty result.
+func (db *Database) SearchPath(opts PathSearchOptions) (*SearchResult, error) {
+	return db.SearchPathContext(context.Background(), opts)
+}
+
+// SearchPathContext is like SearchPath but stops early when ctx is done,
+// returning the matches found so far as SearchContext does. An invalid
+// Pattern, such as one with a malformed character class, is reported as an
+// error before any entry is matched.
+func (db *Database) SearchPathContext(ctx context.Context, opts PathSearchOptions) (*SearchResult, error) {
 	result := &SearchResult{
 		Files:   make([]*Entry, 0),
 		Folders: make([]*Folder, 0),
+		db:      db,
 	}
 
-	// Check for wildcard patterns (before case conversion)
-	useWildcard := hasWildcards(pattern)
-	var re *regexp.Regexp
-	if useWildcard {
-		// For wildcard patterns, we need to handle case sensitivity in the regex
-		regexPattern := convertWildcardToRegex(pattern)
-		var err error
-		if caseSensitive {
-			re, err = regexp.Compile(regexPattern)
-		} else {
-			// Compile case-insensitive regex
-			re, err = regexp.Compile("(?i)" + regexPattern)
-		}
-		if err != nil {
-			// If regex compilation fails, fall back to substring match
-			useWildcard = false
-		}
+	match, err := pathMatcher(opts)
+	if err != nil {
+		return result, fmt.Errorf("invalid path pattern %q: %w", opts.Pattern, err)
 	}
 
-	if !caseSensitive && !useWildcard {
-		pattern = strings.ToLower(pattern)
+	matches := func(e *Entry) bool {
+		if opts.MaxRootDepth > 0 && e.Depth() > opts.MaxRootDepth {
+			return false
+		}
+		depth, ok := match(db.FullPath(e))
+		return ok && (opts.MaxDepth == nil || depth <= *opts.MaxDepth)
 	}
 
 	// Search files
-	for _, file := range db.Files {
-		path := db.getFullPathCached(file) // Use cached version
-		var matches bool
-		
-		if useWildcard {
-			// Regex handles case sensitivity internally
-			matches = re.MatchString(path)
-		} else {
-			if !caseSensitive {
-				path = strings.ToLower(path)
-			}
-			matches = strings.Contains(path, pattern)
+	for i, file := range db.Files {
+		if canceled(ctx, i) {
+			result.Truncated = true
+			return result, ctx.Err()
 		}
-		
-		if matches {
+		if matches(file) {
 			result.Files = append(result.Files, file)
 		}
 	}
 
 	// Search folders
-	for _, folder := range db.Folders {
-		path := db.getFullPathCached(&folder.Entry) // Use cached version
-		var matches bool
-		
-		if useWildcard {
-			// Regex handles case sensitivity internally
-			matches = re.MatchString(path)
-		} else {
-			if !caseSensitive {
-				path = strings.ToLower(path)
-			}
-			matches = strings.Contains(path, pattern)
+	for i, folder := range db.Folders {
+		if canceled(ctx, i) {
+			result.Truncated = true
+			return result, ctx.Err()
 		}
-		
-		if matches {
+		if matches(&folder.Entry) {
 			result.Folders = append(result.Folders, folder)
 		}
 	}
 
-	return result
+	return result, nil
 }
 
+// pathMatcher compiles opts into a predicate over full paths, which also
+// returns the matched path's depth below the match for MaxDepth
+func pathMatcher(opts PathSearchOptions) (func(string) (int, bool), error) {
+	if !opts.NoNormalize {
+		// Normalize before folding, which expects composed letters
+		opts.Pattern = normalizeNFC(opts.Pattern)
+		opts.NoNormalize = true
+		match, err := pathMatcher(opts)
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) (int, bool) { return match(normalizeNFC(path)) }, nil
+	}
+	if opts.Fold == nil && !opts.CaseSensitive {
+		opts.Fold = foldCase
+	}
+	if opts.Fold != nil && !opts.CaseSensitive {
+		// Match the folded path against the folded pattern exactly
+		fold := opts.Fold
+		opts.Pattern = fold(opts.Pattern)
+		opts.CaseSensitive, opts.Fold = true, nil
+		match, err := pathMatcher(opts)
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) (int, bool) { return match(fold(path)) }, nil
+	}
+	if opts.FoldAccents {
+		opts.Pattern = stripAccents(opts.Pattern)
+		opts.FoldAccents = false
+		match, err := pathMatcher(opts)
+		if err != nil {
+			return nil, err
+		}
+		return func(path string) (int, bool) { return match(stripAccents(path)) }, nil
+	}
+
+	pattern := opts.Pattern
+	var regexPattern string
+	switch {
+	case opts.SegmentMatch:
+		// Surrounding slashes are implied by the segment boundaries
+		pattern = strings.Trim(pattern, "/")
+		body := regexp.QuoteMeta(pattern)
+		if hasWildcards(pattern) {
+			body = wildcardToRegexBody(pattern)
+		}
+		regexPattern = "(^|/)" + body + "(/|$)"
+	case hasWildcards(pattern):
+		regexPattern = convertWildcardToRegex(pattern)
+	default:
+		// Plain substring match
+		if !opts.CaseSensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		return func(path string) (int, bool) {
+			if !opts.CaseSensitive {
+				path = strings.ToLower(path)
+			}
+			i := strings.Index(path, pattern)
+			if i < 0 {
+				return 0, false
+			}
+			return depthBelow(path, i+len(pattern)), true
+		}, nil
+	}
+
+	if !opts.CaseSensitive {
+		regexPattern = "(?i)" + regexPattern
+	}
+	re, err := regexp.Compile(regexPattern)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SegmentMatch {
+		return func(path string) (int, bool) {
+			loc := re.FindStringIndex(path)
+			if loc == nil {
+				return 0, false
+			}
+			return depthBelow(path, loc[1]), true
+		}, nil
+	}
+	// A whole-path wildcard match counts from its literal start
+	prefix := literalPrefixLen(pattern)
+	return func(path string) (int, bool) {
+		if !re.MatchString(path) {
+			return 0, false
+		}
+		return depthBelow(path, min(prefix, len(path))), true
+	}, nil
+}
+
+// literalPrefixLen returns the length in a matching path of the literal
+// text that starts the wildcard pattern, up to its first *, ?, or
+// character class. An escaped \[ or \] stands for a single bracket.
+func literalPrefixLen(pattern string) int {
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' || c == '?':
+			return n
+		case c == '[' && globClassEnd(pattern, i) >= 0:
+			return n
+		case c == '\\' && i+1 < len(pattern) && (pattern[i+1] == '[' || pattern[i+1] == ']'):
+			i++
+		}
+		n++
+	}
+	return n
+}
+
+// depthBelow counts the levels of path below the match ending at end. A
+// match ending inside a name is taken to cover the rest of that name, and a
+// trailing slash in the match is ignored, so "/home" and "/home/" both put
+// /home/user one level down.
+func depthBelow(path string, end int) int {
+	if end > 0 && path[end-1] == '/' {
+		end--
+	} else if i := strings.IndexByte(path[end:], '/'); i >= 0 {
+		end += i
+	} else {
+		end = len(path)
+	}
+	// The root folder's path is "/" itself
+	return strings.Count(strings.TrimSuffix(path[end:], "/"), "/")
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+	"reflect"
 	"testing"
 )
 
@@ -23,6 +25,7 @@ func TestHasWildcards(t *testing.T) {
 		{"?", true},
 		{"**", true},
 		{"??", true},
+		{"Movie \\[2019\\]", true},
 	}
 
 	for _, tt := range tests {
@@ -49,7 +52,15 @@ func TestConvertWildcardToRegex(t *testing.T) {
 		{"test.*", "^test\\..*$"},
 		{"file?.txt", "^file.\\.txt$"},
 		{"*.*", "^.*\\..*$"},
-		{"test[file]", "^test\\[file\\]$"},
+		{"test[file]", "^test[file]$"},
+		{"file[0-9].txt", "^file[0-9]\\.txt$"},
+		{"[a-z]*", "^[a-z].*$"},
+		{"[!x]?", "^[^x].$"},
+		{"[^x]", "^[^x]$"},
+		{"[]a]", "^[\\]a]$"},
+		{"[a\\^]", "^[a\\\\\\^]$"},
+		{"test[", "^test\\[$"},
+		{"test[]", "^test\\[\\]$"},
 		{"test(file)", "^test\\(file\\)$"},
 		{"test{file}", "^test\\{file\\}$"},
 		{"test^file", "^test\\^file$"},
@@ -57,6 +68,8 @@ func TestConvertWildcardToRegex(t *testing.T) {
 		{"test+file", "^test\\+file$"},
 		{"test|file", "^test\\|file$"},
 		{"test\\file", "^test\\\\file$"},
+		{"a\\[b\\]", "^a\\[b\\]$"},
+		{"\\[[ab\\]]", "^\\[[ab\\]]$"},
 	}
 
 	for _, tt := range tests {
@@ -92,6 +105,10 @@ func TestWildcardSearch(t *testing.T) {
 		{"Match files with 'test' anywhere", "*test*", 2},
 		{"Match single char + .txt", "?.txt", 0}, // No single char .txt files
 		{"Match document.pdf", "document.pdf", 1},
+		{"Match class of first letters", "[rt]*.txt", 2},
+		{"Match range of first letters", "[a-e]*", 1}, // document.pdf
+		{"Match negated class", "[!t]*", 3},           // readme.txt, document.pdf, file.zip
+		{"Match class without other wildcards", "test.[gt][ox]", 1},
 	}
 
 	for _, tt := range tests {
@@ -103,7 +120,7 @@ func TestWildcardSearch(t *testing.T) {
 				SearchInFolders: false,
 			}
 
-			result := db.Search(opts)
+			result := mustSearch(t, db, opts)
 			if len(result.Files) != tt.expected {
 				t.Errorf("Query %q: expected %d files, got %d", tt.query, tt.expected, len(result.Files))
 				for _, file := range result.Files {
@@ -114,6 +131,32 @@ func TestWildcardSearch(t *testing.T) {
 	}
 }
 
+func TestWildcardEscapedBrackets(t *testing.T) {
+	db := buildDatabase("/movies/Movie [2019].mkv", "/movies/Movie 2.mkv", "/movies/Movie ].mkv")
+
+	for query, want := range map[string]int{
+		"Movie [2019].mkv":   1, // a class: Movie 2.mkv
+		"Movie \\[2019\\]":   1, // plain text, anywhere in the name
+		"Movie \\[2019\\].*": 1,
+		"Movie [\\]2].mkv":   2,
+	} {
+		result, err := db.SearchContext(context.Background(), SearchOptions{Query: query, SearchInFiles: true})
+		if err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if len(result.Files) != want {
+			t.Errorf("%q: expected %d files, got %d", query, want, len(result.Files))
+		}
+	}
+
+	for _, query := range []string{"[z-a]*", "x OR [z-a]", "{a,[z-a]}"} {
+		_, err := db.SearchContext(context.Background(), SearchOptions{Query: query, Boolean: query == "x OR [z-a]", SearchInFiles: true})
+		if err == nil {
+			t.Errorf("%q: expected an invalid pattern error", query)
+		}
+	}
+}
+
 func TestWildcardSearchFolders(t *testing.T) {
 	// Load test database
 	dbPath := setupTestDB(t)
@@ -144,7 +187,7 @@ func TestWildcardSearchFolders(t *testing.T) {
 				SearchInFolders: true,
 			}
 
-			result := db.Search(opts)
+			result := mustSearch(t, db, opts)
 			if len(result.Folders) != tt.expected {
 				t.Errorf("Query %q: expected %d folders, got %d", tt.query, tt.expected, len(result.Folders))
 				for _, folder := range result.Folders {
@@ -176,7 +219,10 @@ func TestWildcardSearchByPath(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := db.SearchByPath(tt.pattern, false)
+			result, err := db.SearchByPath(tt.pattern, false)
+			if err != nil {
+				t.Fatal(err)
+			}
 			total := len(result.Files) + len(result.Folders)
 			if total != tt.expected {
 				t.Errorf("Pattern %q: expected %d results, got %d (files: %d, folders: %d)",
@@ -184,6 +230,13 @@ func TestWildcardSearchByPath(t *testing.T) {
 			}
 		})
 	}
+
+	for _, pattern := range []string{"[z-a]", "/home/[z-a]*"} {
+		_, err := db.SearchPathContext(context.Background(), PathSearchOptions{Pattern: pattern})
+		if err == nil {
+			t.Errorf("%q: expected an invalid pattern error", pattern)
+		}
+	}
 }
 
 func TestWildcardCaseSensitive(t *testing.T) {
@@ -201,7 +254,7 @@ func TestWildcardCaseSensitive(t *testing.T) {
 		SearchInFiles:   true,
 		SearchInFolders: false,
 	}
-	result1 := db.Search(opts1)
+	result1 := mustSearch(t, db, opts1)
 	if len(result1.Files) != 2 {
 		t.Errorf("Case-insensitive *.TXT: expected 2 files, got %d", len(result1.Files))
 	}
@@ -213,9 +266,122 @@ func TestWildcardCaseSensitive(t *testing.T) {
 		SearchInFiles:   true,
 		SearchInFolders: false,
 	}
-	result2 := db.Search(opts2)
+	result2 := mustSearch(t, db, opts2)
 	if len(result2.Files) != 0 {
 		t.Errorf("Case-sensitive *.TXT: expected 0 files (all are .txt lowercase), got %d", len(result2.Files))
 	}
 }
 
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.{jpg,png,gif}", []string{"*.jpg", "*.png", "*.gif"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"report{,-final}.pdf", []string{"report.pdf", "report-final.pdf"}},
+		{"{a,b,a}", []string{"a", "b"}},
+		{"test{file}", []string{"test{file}"}},
+		{`\{a,b\}`, []string{"{a,b}"}},
+		{`{a\,b,c}`, []string{"a,b", "c"}},
+		{`x\\{a,b}`, []string{`x\\a`, `x\\b`}},
+		{"{a,{b,c}}", []string{"a", "b", "c"}},
+		{"{a,b", []string{"{a,b"}},
+	}
+
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestSearchBraces(t *testing.T) {
+	db := buildDatabase("/a.jpg", "/b.png", "/c.gif", "/d.txt", "/{x,y}.txt", "/notes/")
+
+	tests := []struct {
+		query   string
+		boolean bool
+		want    []string
+	}{
+		{"*.{jpg,png}", false, []string{"a.jpg", "b.png"}},
+		{"{jpg,gif}", false, []string{"a.jpg", "c.gif"}},
+		{`\{x,y\}`, false, []string{"{x,y}.txt"}},
+		{"{jpg,txt} NOT d", true, []string{"a.jpg", "{x,y}.txt"}},
+	}
+
+	for _, tt := range tests {
+		result, err := db.SearchContext(context.Background(), SearchOptions{
+			Query:         tt.query,
+			Boolean:       tt.boolean,
+			SearchInFiles: true,
+		})
+		if err != nil {
+			t.Fatalf("Query %q: %v", tt.query, err)
+		}
+		var got []string
+		for _, f := range result.Files {
+			got = append(got, f.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Query %q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchContains(t *testing.T) {
+	db := buildDatabase("/my-report-2024.pdf", "/report-2024.pdf", "/report.txt", "/old-report.txt")
+
+	tests := []struct {
+		query    string
+		contains bool
+		boolean  bool
+		want     []string
+	}{
+		// Wildcard patterns match the whole name unless Contains is set
+		{"rep*2024", false, false, nil},
+		{"rep*2024", true, false, []string{"my-report-2024.pdf", "report-2024.pdf"}},
+		{"report*", false, false, []string{"report-2024.pdf", "report.txt"}},
+		{"report*", true, false, []string{"my-report-2024.pdf", "report-2024.pdf", "report.txt", "old-report.txt"}},
+		{"*.txt", true, false, []string{"report.txt", "old-report.txt"}},
+		{"r?port.{pdf,txt}", true, false, []string{"report.txt", "old-report.txt"}},
+		// Plain text matches anywhere either way
+		{"report", false, false, []string{"my-report-2024.pdf", "report-2024.pdf", "report.txt", "old-report.txt"}},
+		{"rep*2024 NOT my", true, true, []string{"report-2024.pdf"}},
+	}
+
+	for _, tt := range tests {
+		result, err := db.SearchContext(context.Background(), SearchOptions{
+			Query:         tt.query,
+			Contains:      tt.contains,
+			Boolean:       tt.boolean,
+			SearchInFiles: true,
+		})
+		if err != nil {
+			t.Fatalf("Query %q: %v", tt.query, err)
+		}
+		var got []string
+		for _, f := range result.Files {
+			got = append(got, f.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Query %q, contains %v: got %q, want %q", tt.query, tt.contains, got, tt.want)
+		}
+	}
+}
+
+func TestUnanchored(t *testing.T) {
+	tests := map[string]string{
+		"rep*2024": "*rep*2024*",
+		"*.txt":    "*.txt*",
+		"test*":    "*test*",
+		"*a*":      "*a*",
+		"report":   "report",
+		"[ab]c":    "*[ab]c*",
+	}
+	for query, want := range tests {
+		if got := unanchored(query); got != want {
+			t.Errorf("unanchored(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortedArrayStatus describes one of the database's sorted arrays and
+// whether it can be trusted as an ordering of the entries
+type SortedArrayStatus struct {
+	ID      uint32
+	Key     string // sort key the ID is believed to stand for, "" if unknown
+	Folders int    // length of the folder ordering
+	Files   int    // length of the file ordering
+	Valid   bool
+	Problem string // why the array is not valid
+}
+
+// SortKey returns the sort key a sorted array ID is believed to represent.
+// FSearch numbers its index properties in the same order as the IndexFlags
+// bits, so ID n is taken to be the property of bit n. It returns "" for IDs
+// with no known property.
+func SortKey(id uint32) string {
+	if id >= 64 {
+		return ""
+	}
+	flag := IndexFlags(1) << id
+	for _, n := range indexFlagNames {
+		if n.flag == flag {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// CheckSortedArrays reports on every sorted array in ID order. An array is
+// valid when its folder and file orderings each cover every entry exactly
+// once.
+func (db *Database) CheckSortedArrays() []SortedArrayStatus {
+	ids := make([]uint32, 0, len(db.SortedArrays))
+	for id := range db.SortedArrays {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	statuses := make([]SortedArrayStatus, 0, len(ids))
+	for _, id := range ids {
+		arr := db.SortedArrays[id]
+		status := SortedArrayStatus{
+			ID:      id,
+			Key:     SortKey(id),
+			Folders: len(arr.Folders),
+			Files:   len(arr.Files),
+			Valid:   true,
+		}
+		problem := checkPermutation("folders", arr.Folders, len(db.Folders))
+		if problem == "" {
+			problem = checkPermutation("files", arr.Files, len(db.Files))
+		}
+		if problem != "" {
+			status.Valid, status.Problem = false, problem
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// SortOrder returns the sorted array for a sort key such as "size" or
+// "mtime", as named by SortKey, if the database has one and it orders every
+// folder and file exactly once. Checking the array takes time linear in the
+// size of the database, still far less than sorting it, and is done only
+// the first time, so the array must not change after that.
+func (db *Database) SortOrder(key string) (*SortedArray, bool) {
+	if key == "" {
+		return nil, false
+	}
+	for id, arr := range db.SortedArrays {
+		if SortKey(id) != key {
+			continue
+		}
+		arr.checkOnce.Do(func() {
+			arr.valid = checkPermutation("folders", arr.Folders, len(db.Folders)) == "" &&
+				checkPermutation("files", arr.Files, len(db.Files)) == ""
+		})
+		if !arr.valid {
+			return nil, false
+		}
+		return arr, true
+	}
+	return nil, false
+}
+
+// checkPermutation describes the first reason indices is not an ordering of
+// n entries, or returns ""
+func checkPermutation(kind string, indices []uint32, n int) string {
+	if len(indices) != n {
+		return fmt.Sprintf("%s: %d indices for %d entries", kind, len(indices), n)
+	}
+	seen := make([]bool, n)
+	for i, idx := range indices {
+		if int64(idx) >= int64(n) {
+			return fmt.Sprintf("%s: index %d at position %d out of range", kind, idx, i)
+		}
+		if seen[idx] {
+			return fmt.Sprintf("%s: index %d repeated at position %d", kind, idx, i)
+		}
+		seen[idx] = true
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package db
+
+// largestFilesInStats is how many files Stats lists in Largest
+const largestFilesInStats = 10
+
+// Stats summarises the shape of a database
+type Stats struct {
+	Folders int
+	Files   int
+
+	// Depths counts the folders and files at each level of the hierarchy:
+	// Depths[0] is the root folder, Depths[1] the entries directly in it,
+	// and so on. Entries caught in a parent cycle are left out.
+	Depths []int
+
+	// Largest holds the ten largest files, biggest first; ties keep
+	// database order
+	Largest []*Entry
+}
+
+// Stats walks the database once to gather its Stats
+func (db *Database) Stats() Stats {
+	s := Stats{Folders: len(db.Folders), Files: len(db.Files)}
+	count := func(depth int) {
+		if depth < 0 {
+			return
+		}
+		for len(s.Depths) <= depth {
+			s.Depths = append(s.Depths, 0)
+		}
+		s.Depths[depth]++
+	}
+
+	depths := db.folderDepths()
+	for _, folder := range db.Folders {
+		count(depths[folder])
+	}
+	for _, file := range db.Files {
+		depth := 0
+		if file.Parent != nil {
+			if depth = depths[file.Parent]; depth >= 0 {
+				depth++
+			}
+		}
+		count(depth)
+
+		// Insert into the short list of largest files, keeping it sorted
+		if len(s.Largest) == largestFilesInStats && file.Size <= s.Largest[len(s.Largest)-1].Size {
+			continue
+		}
+		i := len(s.Largest)
+		for i > 0 && s.Largest[i-1].Size < file.Size {
+			i--
+		}
+		if len(s.Largest) < largestFilesInStats {
+			s.Largest = append(s.Largest, nil)
+		}
+		copy(s.Largest[i+1:], s.Largest[i:])
+		s.Largest[i] = file
+	}
+	return s
+}
+
+// folderDepths returns the depth of every folder, as Entry.Depth would
+// give it, computing each from its parent's so the whole database takes
+// linear time. Folders whose parent chain loops get -1.
+func (db *Database) folderDepths() map[*Folder]int {
+	depths := make(map[*Folder]int, len(db.Folders))
+	for _, folder := range db.Folders {
+		// Climb to the first folder of known depth, or above the root
+		var chain []*Folder
+		p := folder
+		for p != nil && len(chain) <= len(db.Folders) {
+			if _, ok := depths[p]; ok {
+				break
+			}
+			chain = append(chain, p)
+			p = p.Parent
+		}
+
+		depth := -1 // of p; nothing above the root
+		cyclic := false
+		if p != nil {
+			d, ok := depths[p]
+			depth, cyclic = d, !ok || d < 0
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			if cyclic {
+				depths[chain[i]] = -1
+				continue
+			}
+			depth++
+			depths[chain[i]] = depth
+		}
+	}
+	return depths
+}
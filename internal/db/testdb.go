@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 )
@@ -148,7 +149,7 @@ func (w *testDBWriter) writeMetadata(indexFlags IndexFlags, numFolders, numFiles
 func (w *testDBWriter) writeFolders(folders []testFolder, indexFlags IndexFlags) error {
 	previousName := ""
 	for i, folder := range folders {
-		// db_index (2 bytes) - currently unused, set to 0
+		// db_index (2 bytes) - every folder belongs to the one index, 0
 		if err := w.writeUint16(0); err != nil {
 			return err
 		}
@@ -217,28 +218,28 @@ func (w *testDBWriter) writeFiles(files []testFile, indexFlags IndexFlags) error
 	return nil
 }
 
+// writeDeltaName writes name as the length of the prefix it shares with
+// previousName and the bytes after it, each length in one byte. Names whose
+// remainder does not fit are an error rather than being truncated.
 func (w *testDBWriter) writeDeltaName(name, previousName string) error {
 	// Calculate name offset (where names start to differ)
-	nameOffset := uint8(0)
-	minLen := len(name)
-	if len(previousName) < minLen {
-		minLen = len(previousName)
-	}
-	for i := 0; i < minLen; i++ {
-		if name[i] != previousName[i] {
-			break
-		}
+	minLen := min(len(name), len(previousName), math.MaxUint8)
+	nameOffset := 0
+	for nameOffset < minLen && name[nameOffset] == previousName[nameOffset] {
 		nameOffset++
 	}
 
 	// Calculate name length (new characters to append)
-	nameLen := uint8(len(name) - int(nameOffset))
+	nameLen := len(name) - nameOffset
+	if nameLen > math.MaxUint8 {
+		return fmt.Errorf("name %.20q... needs %d new bytes, more than the %d the format allows", name, nameLen, math.MaxUint8)
+	}
 
 	// Write offset and length
-	if err := w.writeUint8(nameOffset); err != nil {
+	if err := w.writeUint8(uint8(nameOffset)); err != nil {
 		return err
 	}
-	if err := w.writeUint8(nameLen); err != nil {
+	if err := w.writeUint8(uint8(nameLen)); err != nil {
 		return err
 	}
 
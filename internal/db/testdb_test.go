@@ -1,8 +1,10 @@
 package db
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -71,3 +73,53 @@ func TestCreateTestDatabaseMultipleTimes(t *testing.T) {
 	}
 }
 
+func TestDeltaNameLongNames(t *testing.T) {
+	// Each length is one byte, but a 300-byte name round-trips when it
+	// shares enough of a prefix with the name before it
+	names := []string{
+		strings.Repeat("a", 250) + "1",
+		strings.Repeat("a", 250) + strings.Repeat("é", 25),
+		strings.Repeat("a", 255) + strings.Repeat("b", 45),
+		strings.Repeat("a", 255) + strings.Repeat("c", 200),
+		"short",
+	}
+
+	var buf bytes.Buffer
+	w := &testDBWriter{file: &buf}
+	previous := ""
+	for _, name := range names {
+		if err := w.writeDeltaName(name, previous); err != nil {
+			t.Fatalf("writeDeltaName(%d bytes) failed: %v", len(name), err)
+		}
+		previous = name
+	}
+
+	var db Database
+	block := buf.Bytes()
+	offset := 0
+	previous = ""
+	for _, want := range names {
+		got, next, err := db.readDeltaName(block, offset, previous)
+		if err != nil {
+			t.Fatalf("readDeltaName failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Read %d-byte name %.20q..., want %d-byte %.20q...", len(got), got, len(want), want)
+		}
+		offset, previous = next, got
+	}
+	if offset != len(block) {
+		t.Errorf("Read %d of %d bytes", offset, len(block))
+	}
+
+	// Without a shared prefix the remainder does not fit, which is an
+	// error rather than a wrapped length
+	if err := w.writeDeltaName(strings.Repeat("x", 300), "short"); err == nil {
+		t.Error("Expected an error for a 300-byte name with no shared prefix")
+	}
+
+	// A prefix longer than the previous name is corrupt
+	if _, _, err := db.readDeltaName([]byte{10, 1, 'x'}, 0, "abc"); err == nil {
+		t.Error("Expected an error for a prefix beyond the previous name")
+	}
+}
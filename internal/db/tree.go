@@ -0,0 +1,265 @@
+package db
+
+import (
+	"strings"
+	"sync"
+)
+
+// treeIndex holds parent-to-children links and aggregate folder sizes.
+// It is derived from the Parent pointers and built lazily on first use.
+type treeIndex struct {
+	once        sync.Once
+	folders     map[*Folder][]*Folder
+	files       map[*Folder][]*Entry
+	folderSizes map[*Folder]int64
+}
+
+func (db *Database) buildTree() {
+	db.tree.once.Do(func() {
+		db.tree.folders = make(map[*Folder][]*Folder)
+		db.tree.files = make(map[*Folder][]*Entry)
+		db.tree.folderSizes = make(map[*Folder]int64)
+
+		for _, folder := range db.Folders {
+			if folder.Parent != nil {
+				db.tree.folders[folder.Parent] = append(db.tree.folders[folder.Parent], folder)
+			}
+		}
+		for _, file := range db.Files {
+			if file.Parent == nil {
+				continue
+			}
+			db.tree.files[file.Parent] = append(db.tree.files[file.Parent], file)
+			// Guard against parent cycles in corrupt databases
+			seen := 0
+			for p := file.Parent; p != nil && seen <= len(db.Folders); p = p.Parent {
+				db.tree.folderSizes[p] += file.Size
+				seen++
+			}
+		}
+	})
+}
+
+// Children returns the direct subfolders and files of a folder
+func (db *Database) Children(f *Folder) ([]*Folder, []*Entry) {
+	db.buildTree()
+	return db.tree.folders[f], db.tree.files[f]
+}
+
+// Walk calls fn with every entry of the database, the folders in index
+// order and then the files, until fn returns false. The root folder is
+// visited like any other; isFolder tells folders from files.
+func (db *Database) Walk(fn func(e *Entry, isFolder bool) bool) {
+	for _, folder := range db.Folders {
+		if !fn(&folder.Entry, true) {
+			return
+		}
+	}
+	for _, file := range db.Files {
+		if !fn(file, false) {
+			return
+		}
+	}
+}
+
+// FolderSize returns the combined size of all files beneath a folder,
+// computed from the file entries rather than the folder's own Size field.
+func (db *Database) FolderSize(f *Folder) int64 {
+	db.buildTree()
+	return db.tree.folderSizes[f]
+}
+
+// Depth returns the number of ancestors of an entry; the root folder has
+// depth 0 and its direct children depth 1.
+func (e *Entry) Depth() int {
+	depth := 0
+	for p := e.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// Ancestors returns the folders above an entry, nearest first and ending
+// with the root. The root folder itself has none.
+func (e *Entry) Ancestors() []*Folder {
+	var chain []*Folder
+	seen := make(map[*Folder]bool)
+	for p := e.Parent; p != nil && !seen[p]; p = p.Parent {
+		// Guard against parent cycles in corrupt databases
+		seen[p] = true
+		chain = append(chain, p)
+	}
+	return chain
+}
+
+// EntryByPath returns the folder or file whose full path is p, as printed
+// in results. A trailing slash is ignored; "/" is the root folder.
+func (db *Database) EntryByPath(p string) (*Entry, bool) {
+	if len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	name := p[strings.LastIndexByte(p, '/')+1:]
+	for _, folder := range db.Folders {
+		if folder.Name == name && db.FullPath(&folder.Entry) == p {
+			return &folder.Entry, true
+		}
+	}
+	for _, file := range db.Files {
+		if file.Name == name && db.FullPath(file) == p {
+			return file, true
+		}
+	}
+	return nil, false
+}
+
+// follow appends the descendants of folders matching query to result,
+// walking the children index breadth-first. Matched folders seed the walk
+// even when opts excludes folders from the results, so files beneath a
+// matching folder can be followed on their own. Entries already in result,
+// or reached through more than one matched folder, are added once. The size,
+// time, depth, exclude, and path filters apply to followed entries as they
+// do to matches.
+func (db *Database) follow(result *SearchResult, query string, opts SearchOptions) {
+	inResult := make(map[*Entry]bool, len(result.Files)+len(result.Folders))
+	for _, file := range result.Files {
+		inResult[file] = true
+	}
+	for _, folder := range result.Folders {
+		inResult[&folder.Entry] = true
+	}
+	// fits reports whether one more entry fits under MaxResults and under
+	// limit, the MaxFiles or MaxFolders for its kind, of which there are n
+	// so far. An entry that does not is left out, so the result is then
+	// truncated.
+	fits := func(n, limit int) bool {
+		if opts.MaxResults > 0 && len(result.Files)+len(result.Folders) >= opts.MaxResults || limit > 0 && n >= limit {
+			result.Truncated = true
+			return false
+		}
+		return true
+	}
+	// full reports whether MaxResults has already left an entry out
+	full := func() bool {
+		return result.Truncated && opts.MaxResults > 0 && len(result.Files)+len(result.Folders) >= opts.MaxResults
+	}
+
+	// remaining records how many more levels have been walked below each
+	// folder, so a nested match only re-walks what its ancestor did not
+	const unlimited = -1
+	remaining := make(map[*Folder]int)
+	type visit struct {
+		folder *Folder
+		levels int // levels still to descend, or unlimited
+	}
+
+	for _, seed := range db.Folders {
+		if full() {
+			return
+		}
+		if opts.expr != nil && !opts.expr.eval(db, &seed.Entry) || opts.expr == nil && !db.matches(seed.Name, query, opts) {
+			continue
+		}
+		levels := unlimited
+		if opts.FollowDepth > 0 {
+			levels = opts.FollowDepth
+		}
+		queue := []visit{{seed, levels}}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			if prev, ok := remaining[v.folder]; ok && (prev == unlimited || (v.levels != unlimited && prev >= v.levels)) {
+				continue
+			}
+			remaining[v.folder] = v.levels
+			if v.levels == 0 {
+				continue
+			}
+
+			next := v.levels
+			if next != unlimited {
+				next--
+			}
+			subfolders, files := db.Children(v.folder)
+			for _, sub := range subfolders {
+				if opts.SearchInFolders && opts.ExactSize == nil && !inResult[&sub.Entry] && db.folderSizeMatches(sub, opts) && opts.mtimeMatches(sub.MTime) && opts.depthMatches(&sub.Entry) && !db.excluded(&sub.Entry, opts) && db.pathMatches(&sub.Entry, opts) && fits(len(result.Folders), opts.MaxFolders) {
+					inResult[&sub.Entry] = true
+					result.Folders = append(result.Folders, sub)
+				}
+				queue = append(queue, visit{sub, next})
+			}
+			if opts.SearchInFiles {
+				for _, file := range files {
+					if !inResult[file] && opts.fileSizeMatches(file.Size) && opts.mtimeMatches(file.MTime) && opts.depthMatches(file) && !db.excluded(file, opts) && db.pathMatches(file, opts) && fits(len(result.Files), opts.MaxFiles) {
+						inResult[file] = true
+						result.Files = append(result.Files, file)
+					}
+				}
+			}
+		}
+	}
+}
+
+// CaseCollisions returns groups of entries that share a parent folder and
+// whose names differ only by case, such as README and readme. Such siblings
+// cannot coexist on a case-insensitive filesystem. Groups are returned in
+// folder index order; entries within a group in folder-then-file order.
+func (db *Database) CaseCollisions() [][]*Entry {
+	var groups [][]*Entry
+	for _, folder := range db.Folders {
+		subfolders, files := db.Children(folder)
+		if len(subfolders)+len(files) < 2 {
+			continue
+		}
+
+		byKey := make(map[string][]*Entry)
+		var keys []string
+		add := func(e *Entry) {
+			key := strings.ToLower(e.Name)
+			if _, ok := byKey[key]; !ok {
+				keys = append(keys, key)
+			}
+			byKey[key] = append(byKey[key], e)
+		}
+		for _, sub := range subfolders {
+			add(&sub.Entry)
+		}
+		for _, file := range files {
+			add(file)
+		}
+
+		for _, key := range keys {
+			if group := byKey[key]; hasDistinctNames(group) {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// hasDistinctNames reports whether the entries carry more than one spelling
+func hasDistinctNames(entries []*Entry) bool {
+	for _, e := range entries[1:] {
+		if e.Name != entries[0].Name {
+			return true
+		}
+	}
+	return false
+}
+
+// countChildren sets NumFiles and NumFolders on every folder from the
+// parents of the entries below it
+func (db *Database) countChildren() {
+	for _, folder := range db.Folders {
+		folder.NumFiles, folder.NumFolders = 0, 0
+	}
+	for _, folder := range db.Folders {
+		if folder.Parent != nil {
+			folder.Parent.NumFolders++
+		}
+	}
+	for _, file := range db.Files {
+		if file.Parent != nil {
+			file.Parent.NumFiles++
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package fsearch reads FSearch database files and searches them. It is the
+// importable face of the reader the gsearch-cli command uses: the types
+// below are aliases of that implementation, so a program embedding this
+// package gets exactly the command's behavior.
+//
+// Being aliases, the types carry every exported field and method of the
+// implementation, not only those mentioned here, and all of them are this
+// package's API: they are kept as compatible as the declarations below.
+//
+// A typical use loads a database once and searches it many times:
+//
+//	database, err := fsearch.Load("/home/me/.local/share/fsearch/fsearch.db")
+//	if err != nil {
+//		return err
+//	}
+//	result, err := database.Search(fsearch.SearchOptions{
+//		Query:         "*.pdf",
+//		SearchInFiles: true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	for _, file := range result.Files {
+//		fmt.Println(file.GetFullPath())
+//	}
+//
+// A Database may be shared by concurrent searches: Search, SearchContext,
+// SearchPath, SearchPathContext, FullPath, and SortOrder may run at the
+// same time from several goroutines. SetPathCacheSize and Merge must not
+// run alongside any of them.
+package fsearch
+
+import (
+	"time"
+
+	"github.com/gsearch-cli/internal/db"
+)
+
+// Database is a loaded FSearch database. Its Folders and Files hold every
+// entry; Search, SearchContext, SearchPath, and SearchPathContext query them.
+type Database = db.Database
+
+// Entry is a file or folder in a database. GetFullPath returns its path
+// from the root, such as "/home/me/notes.txt".
+type Entry = db.Entry
+
+// Folder is a folder in a database. It embeds Entry, so GetFullPath and the
+// other Entry fields and methods are available on it too.
+type Folder = db.Folder
+
+// EntryType tells files and folders apart.
+type EntryType = db.EntryType
+
+// The entry types.
+const (
+	EntryTypeFile   = db.EntryTypeFile
+	EntryTypeFolder = db.EntryTypeFolder
+)
+
+// IndexFlags records which properties a database indexes; see
+// Database.IndexFlags.
+type IndexFlags = db.IndexFlags
+
+// The properties a database can index.
+const (
+	IndexFlagName             = db.IndexFlagName
+	IndexFlagPath             = db.IndexFlagPath
+	IndexFlagSize             = db.IndexFlagSize
+	IndexFlagModificationTime = db.IndexFlagModificationTime
+	IndexFlagAccessTime       = db.IndexFlagAccessTime
+	IndexFlagCreationTime     = db.IndexFlagCreationTime
+	IndexFlagStatusChangeTime = db.IndexFlagStatusChangeTime
+)
+
+// SearchOptions controls Database.Search and Database.SearchContext. Query
+// is a substring, a wildcard pattern using *, ?, and [...], or with UseRegex
+// a Go regular expression. Brace groups such as "*.{jpg,png}" expand into
+// alternatives, any of which may match. Set SearchInFiles, SearchInFolders,
+// or both.
+type SearchOptions = db.SearchOptions
+
+// PathSearchOptions controls Database.SearchPath and
+// Database.SearchPathContext, which match against full paths.
+type PathSearchOptions = db.PathSearchOptions
+
+// SearchResult holds the folders and files a search matched.
+type SearchResult = db.SearchResult
+
+// Match is one file or folder passed to the function given to
+// Database.SearchStream, which reports matches as it finds them rather than
+// collecting a SearchResult.
+type Match = db.Match
+
+// Metadata is a database's format header, as read by LoadMetadata.
+type Metadata = db.Metadata
+
+// ErrNoModificationTime is returned by a search that filters on
+// modification time in a database indexed without it.
+var ErrNoModificationTime = db.ErrNoModificationTime
+
+// Load reads the FSearch database at path.
+func Load(path string) (*Database, error) {
+	return db.Load(path)
+}
+
+// LoadWithRetry is like Load but tries again up to retries more times,
+// waiting interval between attempts, while the file is unavailable, as it
+// may briefly be while FSearch rewrites it.
+func LoadWithRetry(path string, retries int, interval time.Duration) (*Database, error) {
+	return db.LoadWithRetry(path, retries, interval)
+}
+
+// LoadMetadata reads only the header of the database at path, without
+// loading any entries.
+func LoadMetadata(path string) (*Metadata, error) {
+	return db.LoadMetadata(path)
+}
+
+// Merge combines databases into one that lists the entries of each. The
+// entries are shared with the sources and keep their full paths.
+func Merge(dbs ...*Database) *Database {
+	return db.Merge(dbs...)
+}
+
+// MergeResults combines the results of searching several databases, taking
+// at most maxPerSource entries from each and maxTotal overall (0 means no
+// limit).
+func MergeResults(results []*SearchResult, maxPerSource, maxTotal int) *SearchResult {
+	return db.MergeResults(results, maxPerSource, maxTotal)
+}
+
+// Extension returns the part of name after its final dot, or "" if it has
+// none. Dotfiles such as ".bashrc" have no extension.
+func Extension(name string) string {
+	return db.Extension(name)
+}
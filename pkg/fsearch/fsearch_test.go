@@ -0,0 +1,57 @@
+package fsearch_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gsearch-cli/internal/db"
+	"github.com/gsearch-cli/pkg/fsearch"
+)
+
+func TestLoadAndSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := db.CreateTestDatabase(path); err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	database, err := fsearch.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	result, err := database.SearchContext(context.Background(), fsearch.SearchOptions{
+		Query:           "test",
+		SearchInFiles:   true,
+		SearchInFolders: true,
+	})
+	if err != nil {
+		t.Fatalf("SearchContext: %v", err)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(result.Files))
+	}
+	var file *fsearch.Entry = result.Files[0]
+	if file.GetFullPath() != "/home/user/test.txt" {
+		t.Errorf("Unexpected path %q", file.GetFullPath())
+	}
+	var parent *fsearch.Folder = file.Parent
+	if parent.GetFullPath() != "/home/user" || parent.Type != fsearch.EntryTypeFolder {
+		t.Errorf("Unexpected parent %q", parent.GetFullPath())
+	}
+
+	byPath, err := database.SearchPath(fsearch.PathSearchOptions{Pattern: "/Documents/*"})
+	if err != nil {
+		t.Fatalf("SearchPath: %v", err)
+	}
+	if len(byPath.Files) != 2 {
+		t.Errorf("Expected 2 files under /Documents, got %d", len(byPath.Files))
+	}
+
+	meta, err := fsearch.LoadMetadata(path)
+	if err != nil {
+		t.Fatalf("LoadMetadata: %v", err)
+	}
+	if meta.IndexFlags&fsearch.IndexFlagName == 0 {
+		t.Errorf("Expected names to be indexed, got %s", meta.IndexFlags)
+	}
+}